@@ -16,6 +16,7 @@
 package client // import "github.com/buraksezer/olric/client"
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -48,6 +49,9 @@ type Client struct {
 	streams      *streams
 	entryFormats map[string]storage.Entry
 	wg           sync.WaitGroup
+
+	nearCachesMtx sync.Mutex
+	nearCaches    map[string]*nearCache
 }
 
 // Config includes configuration parameters for the Client.
@@ -60,6 +64,8 @@ type Config struct {
 	// entry format here. If EntryFormats is empty or you don't set the format manually,
 	// kvstore.Entry will be used by default.
 	EntryFormats map[string]storage.Entry
+	// NearCache enables the near caches of the DMaps, if it's set. See NearCacheConfig.
+	NearCache *NearCacheConfig
 }
 
 // New returns a new Client instance. The second parameter is serializer, it can be nil.
@@ -81,6 +87,14 @@ func New(c *Config) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.NearCache != nil {
+		if err = c.NearCache.Sanitize(); err != nil {
+			return nil, err
+		}
+		if err = c.NearCache.Validate(); err != nil {
+			return nil, err
+		}
+	}
 
 	client := transport.NewClient(c.Client)
 	// About the hack: This looks weird, but I need to mock client.CreateStream function to test streams
@@ -94,6 +108,7 @@ func New(c *Config) (*Client, error) {
 		serializer:   c.Serializer,
 		entryFormats: c.EntryFormats,
 		streams:      &streams{m: make(map[uint64]*stream)},
+		nearCaches:   make(map[string]*nearCache),
 	}, nil
 }
 
@@ -129,8 +144,14 @@ func (c *Client) Ping(addr string) error {
 
 // Request initiates a request-response cycle to randomly selected host.
 func (c *Client) request(req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
+	return c.requestContext(context.Background(), req)
+}
+
+// requestContext initiates a request-response cycle to randomly selected host. The
+// given context cancels the request.
+func (c *Client) requestContext(ctx context.Context, req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
 	addr := c.roundRobin.get()
-	return c.client.RequestTo(addr, req)
+	return c.client.RequestToContext(ctx, addr, req)
 }
 
 // Stats exposes some useful metrics to monitor an Olric node.
@@ -185,16 +206,21 @@ func (c *Client) NewDMap(name string) *DMap {
 		Client:      c,
 		name:        name,
 		entryFormat: c.getEntryFormat(name),
+		nearCache:   c.nearCache(name),
 	}
 }
 
 func checkStatusCode(resp protocol.EncodeDecoder) error {
-	status := resp.Status()
+	return statusToError(resp.Status(), resp.Value())
+}
+
+// statusToError converts a status code to an error. value is the value of the response.
+func statusToError(status protocol.StatusCode, value []byte) error {
 	switch {
 	case status == protocol.StatusOK:
 		return nil
 	case status == protocol.StatusErrInternalFailure:
-		return errors.Wrap(olric.ErrInternalServerError, string(resp.Value()))
+		return errors.Wrap(olric.ErrInternalServerError, string(value))
 	case status == protocol.StatusErrNoSuchLock:
 		return olric.ErrNoSuchLock
 	case status == protocol.StatusErrLockNotAcquired:
@@ -223,8 +249,14 @@ func checkStatusCode(resp protocol.EncodeDecoder) error {
 		return olric.ErrKeyTooLarge
 	case status == protocol.StatusErrNotImplemented:
 		return olric.ErrNotImplemented
+	case status == protocol.StatusErrVersionMismatch:
+		return olric.ErrVersionMismatch
+	case status == protocol.StatusErrTransactionConflict:
+		return olric.ErrTransactionConflict
+	case status == protocol.StatusErrOutOfMemory:
+		return olric.ErrOutOfMemory
 	default:
-		return fmt.Errorf("unknown status: %v", resp.Status())
+		return fmt.Errorf("unknown status: %v", status)
 	}
 }
 
@@ -15,6 +15,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"time"
@@ -22,6 +23,8 @@ import (
 	"github.com/buraksezer/olric"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/buraksezer/olric/query"
+	"github.com/vmihailenco/msgpack"
 )
 
 // DMap provides methods to access distributed maps on Olric cluster.
@@ -29,58 +32,120 @@ type DMap struct {
 	*Client
 	entryFormat storage.Entry
 	name        string
+	nearCache   *nearCache
 }
 
 // Get gets the value for the given key. It returns ErrKeyNotFound if the DB does not contains the key.
 // It's thread-safe. It is safe to modify the contents of the returned value.
 func (d *DMap) Get(key string) (interface{}, error) {
-	req := protocol.NewDMapMessage(protocol.OpGet)
-	req.SetDMap(d.name)
-	req.SetKey(key)
-	resp, err := d.request(req)
+	return d.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get but the given context cancels the network operations.
+func (d *DMap) GetContext(ctx context.Context, key string) (interface{}, error) {
+	entry, err := d.getEntry(ctx, key)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkStatusCode(resp); err != nil {
+	return d.unmarshalValue(entry.Value())
+}
+
+// getEntry fetches the entry for the given key. It serves the entry from the
+// near cache and populates it, if the near cache is enabled.
+func (d *DMap) getEntry(ctx context.Context, key string) (storage.Entry, error) {
+	entry := d.getEntryFormat(d.name)
+	if d.nearCache == nil {
+		raw, err := d.getRaw(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		entry.Decode(raw)
+		return entry, nil
+	}
+
+	if raw, ok := d.nearCache.get(key); ok {
+		entry.Decode(raw)
+		return entry, nil
+	}
+
+	load := d.nearCache.begin(key)
+	raw, err := d.getRaw(ctx, key)
+	if err != nil {
+		if load != nil {
+			d.nearCache.finish(key, load, nil, 0)
+		}
 		return nil, err
 	}
-	entry := d.getEntryFormat(d.name)
-	entry.Decode(resp.Value())
-	return d.unmarshalValue(entry.Value())
+	// The response buffer is reused by the transport layer.
+	raw = append([]byte(nil), raw...)
+	entry.Decode(raw)
+	if load != nil {
+		d.nearCache.finish(key, load, raw, entry.TTL())
+	}
+	return entry, nil
 }
 
-// GetEntry gets the value for the given key. It returns ErrKeyNotFound if the DB does not contains the key.
-// It's thread-safe. It is safe to modify the contents of the returned value.
-func (d *DMap) GetEntry(key string) (*olric.Entry, error) {
+func (d *DMap) getRaw(ctx context.Context, key string) ([]byte, error) {
 	req := protocol.NewDMapMessage(protocol.OpGet)
 	req.SetDMap(d.name)
 	req.SetKey(key)
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	if err := checkStatusCode(resp); err != nil {
 		return nil, err
 	}
+	return resp.Value(), nil
+}
 
-	entry := d.getEntryFormat(d.name)
-	entry.Decode(resp.Value())
+// invalidate removes the given keys from the near cache, if it's enabled.
+func (d *DMap) invalidate(keys ...string) {
+	if d.nearCache == nil {
+		return
+	}
+	for _, key := range keys {
+		d.nearCache.invalidate(key)
+	}
+}
+
+// GetEntry gets the value for the given key. It returns ErrKeyNotFound if the DB does not contains the key.
+// It's thread-safe. It is safe to modify the contents of the returned value.
+func (d *DMap) GetEntry(key string) (*olric.Entry, error) {
+	return d.GetEntryContext(context.Background(), key)
+}
+
+// GetEntryContext is like GetEntry but the given context cancels the network operations.
+func (d *DMap) GetEntryContext(ctx context.Context, key string) (*olric.Entry, error) {
+	entry, err := d.getEntry(ctx, key)
+	if err != nil {
+		return nil, err
+	}
 	value, err := d.unmarshalValue(entry.Value())
 	if err != nil {
 		return nil, err
 	}
 
 	return &olric.Entry{
-		Key:       entry.Key(),
-		TTL:       entry.TTL(),
-		Timestamp: entry.Timestamp(),
-		Value:     value,
+		Key:        entry.Key(),
+		TTL:        entry.TTL(),
+		Timestamp:  entry.Timestamp(),
+		LastAccess: entry.LastAccess(),
+		Version:    entry.Timestamp(),
+		Value:      value,
 	}, nil
 }
 
 // Put sets the value for the given key. It overwrites any previous value for that key and it's thread-safe.
 // It is safe to modify the contents of the arguments after Put returns but not before.
 func (d *DMap) Put(key string, value interface{}) error {
+	return d.PutContext(context.Background(), key, value)
+}
+
+// PutContext is like Put but the given context cancels the network operations.
+func (d *DMap) PutContext(ctx context.Context, key string, value interface{}) error {
+	defer d.invalidate(key)
+
 	data, err := d.serializer.Marshal(value)
 	if err != nil {
 		return err
@@ -92,7 +157,7 @@ func (d *DMap) Put(key string, value interface{}) error {
 	req.SetExtra(protocol.PutExtra{
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -102,6 +167,13 @@ func (d *DMap) Put(key string, value interface{}) error {
 // PutEx sets the value for the given key with TTL. It overwrites any previous value for that key.
 // It's thread-safe. It is safe to modify the contents of the arguments after Put returns but not before.
 func (d *DMap) PutEx(key string, value interface{}, timeout time.Duration) error {
+	return d.PutExContext(context.Background(), key, value, timeout)
+}
+
+// PutExContext is like PutEx but the given context cancels the network operations.
+func (d *DMap) PutExContext(ctx context.Context, key string, value interface{}, timeout time.Duration) error {
+	defer d.invalidate(key)
+
 	data, err := d.serializer.Marshal(value)
 	if err != nil {
 		return err
@@ -114,7 +186,7 @@ func (d *DMap) PutEx(key string, value interface{}, timeout time.Duration) error
 		TTL:       timeout.Nanoseconds(),
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -124,16 +196,218 @@ func (d *DMap) PutEx(key string, value interface{}, timeout time.Duration) error
 // Delete deletes the value for the given key. Delete will not return error if key doesn't exist.
 // It's thread-safe. It is safe to modify the contents of the argument after Delete returns.
 func (d *DMap) Delete(key string) error {
+	return d.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete but the given context cancels the network operations.
+func (d *DMap) DeleteContext(ctx context.Context, key string) error {
+	defer d.invalidate(key)
+
 	req := protocol.NewDMapMessage(protocol.OpDelete)
 	req.SetDMap(d.name)
 	req.SetKey(key)
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
 	return checkStatusCode(resp)
 }
 
+// DeleteByQuery deletes the keys that match the given query and returns the number
+// of the deleted keys. The keys are deleted on the partition owners and the deletions
+// are replicated to the backups, like Delete. $aggregate and $limit are not supported.
+//
+// The deletions are not atomic. If it returns an error, some of the matching keys may
+// already be deleted.
+func (d *DMap) DeleteByQuery(q query.M) (int, error) {
+	return d.DeleteByQueryContext(context.Background(), q)
+}
+
+// DeleteByQueryContext is like DeleteByQuery but the given context cancels the network operations.
+func (d *DMap) DeleteByQueryContext(ctx context.Context, q query.M) (int, error) {
+	req := protocol.NewDMapMessage(protocol.OpDeleteByQuery)
+	return d.mutateByQuery(ctx, req, q)
+}
+
+// ExpireByQuery updates the expiry of the keys that match the given query and returns
+// the number of the updated keys. The keys are updated on the partition owners and the
+// updates are replicated to the backups, like Expire. $aggregate and $limit are not
+// supported.
+func (d *DMap) ExpireByQuery(q query.M, timeout time.Duration) (int, error) {
+	return d.ExpireByQueryContext(context.Background(), q, timeout)
+}
+
+// ExpireByQueryContext is like ExpireByQuery but the given context cancels the network operations.
+func (d *DMap) ExpireByQueryContext(ctx context.Context, q query.M, timeout time.Duration) (int, error) {
+	req := protocol.NewDMapMessage(protocol.OpExpireByQuery)
+	req.SetExtra(protocol.ExpireByQueryExtra{
+		TTL: timeout.Nanoseconds(),
+	})
+	return d.mutateByQuery(ctx, req, q)
+}
+
+// mutateByQuery sends a DeleteByQuery or ExpireByQuery request to a member, it
+// runs the request on the cluster.
+func (d *DMap) mutateByQuery(ctx context.Context, req *protocol.DMapMessage, q query.M) (int, error) {
+	if err := query.Validate(q); err != nil {
+		return 0, err
+	}
+	value, err := msgpack.Marshal(q)
+	if err != nil {
+		return 0, err
+	}
+	if d.nearCache != nil {
+		// The matching keys are unknown.
+		defer d.nearCache.purge()
+	}
+
+	req.SetDMap(d.name)
+	req.SetValue(value)
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkStatusCode(resp); err != nil {
+		return 0, err
+	}
+	var count int
+	if len(resp.Value()) != 0 {
+		err = msgpack.Unmarshal(resp.Value(), &count)
+	}
+	return count, err
+}
+
+func (d *DMap) batch(ctx context.Context, op protocol.OpCode, items []protocol.BatchEntry) ([]protocol.BatchResult, error) {
+	value, err := msgpack.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	req := protocol.NewDMapMessage(protocol.OpBatch)
+	req.SetDMap(d.name)
+	req.SetValue(value)
+	req.SetExtra(protocol.BatchExtra{Op: op})
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatusCode(resp); err != nil {
+		return nil, err
+	}
+	var results []protocol.BatchResult
+	err = msgpack.Unmarshal(resp.Value(), &results)
+	return results, err
+}
+
+func keysToBatchEntries(keys []string) []protocol.BatchEntry {
+	items := make([]protocol.BatchEntry, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, protocol.BatchEntry{Key: key})
+	}
+	return items
+}
+
+// batchErrors returns the same error for all the keys. It's used when the whole request fails.
+func batchErrors(keys []string, err error) map[string]error {
+	errs := make(map[string]error)
+	for _, key := range keys {
+		errs[key] = err
+	}
+	return errs
+}
+
+// MGet gets the values for the given keys. The server groups the keys by their partition owners and
+// queries the owners in parallel. It returns the found values and the errors for the rest of the keys.
+// olric.ErrKeyNotFound is returned for the missing keys. It's thread-safe.
+func (d *DMap) MGet(keys []string) (map[string]interface{}, map[string]error) {
+	return d.MGetContext(context.Background(), keys)
+}
+
+// MGetContext is like MGet but the given context cancels the network operations.
+func (d *DMap) MGetContext(ctx context.Context, keys []string) (map[string]interface{}, map[string]error) {
+	results, err := d.batch(ctx, protocol.OpGet, keysToBatchEntries(keys))
+	if err != nil {
+		return nil, batchErrors(keys, err)
+	}
+
+	values := make(map[string]interface{})
+	errs := make(map[string]error)
+	for _, res := range results {
+		if err := statusToError(res.Status, res.Value); err != nil {
+			errs[res.Key] = err
+			continue
+		}
+		entry := d.getEntryFormat(d.name)
+		entry.Decode(res.Value)
+		value, err := d.unmarshalValue(entry.Value())
+		if err != nil {
+			errs[res.Key] = err
+			continue
+		}
+		values[res.Key] = value
+	}
+	return values, errs
+}
+
+// MPut sets the values for the given keys. The server groups the keys by their partition owners and
+// calls the owners in parallel. It returns the errors for the failed keys. It's thread-safe.
+func (d *DMap) MPut(items map[string]interface{}) map[string]error {
+	return d.MPutContext(context.Background(), items)
+}
+
+// MPutContext is like MPut but the given context cancels the network operations.
+func (d *DMap) MPutContext(ctx context.Context, items map[string]interface{}) map[string]error {
+	errs := make(map[string]error)
+	keys := make([]string, 0, len(items))
+	entries := make([]protocol.BatchEntry, 0, len(items))
+	for key, value := range items {
+		data, err := d.serializer.Marshal(value)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		keys = append(keys, key)
+		entries = append(entries, protocol.BatchEntry{Key: key, Value: data})
+	}
+
+	results, err := d.batch(ctx, protocol.OpPut, entries)
+	d.invalidate(keys...)
+	if err != nil {
+		for key, err := range batchErrors(keys, err) {
+			errs[key] = err
+		}
+		return errs
+	}
+	for _, res := range results {
+		if err := statusToError(res.Status, res.Value); err != nil {
+			errs[res.Key] = err
+		}
+	}
+	return errs
+}
+
+// MDelete deletes the given keys. The server groups the keys by their partition owners and
+// calls the owners in parallel. It returns the errors for the failed keys. It's thread-safe.
+func (d *DMap) MDelete(keys []string) map[string]error {
+	return d.MDeleteContext(context.Background(), keys)
+}
+
+// MDeleteContext is like MDelete but the given context cancels the network operations.
+func (d *DMap) MDeleteContext(ctx context.Context, keys []string) map[string]error {
+	results, err := d.batch(ctx, protocol.OpDelete, keysToBatchEntries(keys))
+	d.invalidate(keys...)
+	if err != nil {
+		return batchErrors(keys, err)
+	}
+
+	errs := make(map[string]error)
+	for _, res := range results {
+		if err := statusToError(res.Status, res.Value); err != nil {
+			errs[res.Key] = err
+		}
+	}
+	return errs
+}
+
 // LockContext is returned by Lock and LockWithTimeout methods.
 // It should be stored in a proper way to release the lock.
 type LockContext struct {
@@ -150,6 +424,12 @@ type LockContext struct {
 //
 // You should know that the locks are approximate, and only to be used for non-critical purposes.
 func (d *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*LockContext, error) {
+	return d.LockWithTimeoutContext(context.Background(), key, timeout, deadline)
+}
+
+// LockWithTimeoutContext is like LockWithTimeout but the given context cancels the request.
+// The server keeps waiting for the lock until the deadline.
+func (d *DMap) LockWithTimeoutContext(ctx context.Context, key string, timeout, deadline time.Duration) (*LockContext, error) {
 	req := protocol.NewDMapMessage(protocol.OpLockWithTimeout)
 	req.SetDMap(d.name)
 	req.SetKey(key)
@@ -157,7 +437,7 @@ func (d *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*Lo
 		Timeout:  timeout.Nanoseconds(),
 		Deadline: deadline.Nanoseconds(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -165,13 +445,13 @@ func (d *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*Lo
 	if err != nil {
 		return nil, err
 	}
-	ctx := &LockContext{
+	lctx := &LockContext{
 		name:  d.name,
 		key:   key,
 		token: resp.Value(),
 		dmap:  d,
 	}
-	return ctx, nil
+	return lctx, nil
 }
 
 // Lock sets a lock for the given key. Acquired lock is only for the key in this dmap.
@@ -180,13 +460,19 @@ func (d *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*Lo
 //
 // You should know that the locks are approximate, and only to be used for non-critical purposes.
 func (d *DMap) Lock(key string, deadline time.Duration) (*LockContext, error) {
+	return d.LockContext(context.Background(), key, deadline)
+}
+
+// LockContext is like Lock but the given context cancels the request.
+// The server keeps waiting for the lock until the deadline.
+func (d *DMap) LockContext(ctx context.Context, key string, deadline time.Duration) (*LockContext, error) {
 	req := protocol.NewDMapMessage(protocol.OpLock)
 	req.SetDMap(d.name)
 	req.SetKey(key)
 	req.SetExtra(protocol.LockExtra{
 		Deadline: deadline.Nanoseconds(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -194,23 +480,28 @@ func (d *DMap) Lock(key string, deadline time.Duration) (*LockContext, error) {
 	if err != nil {
 		return nil, err
 	}
-	ctx := &LockContext{
+	lctx := &LockContext{
 		name:  d.name,
 		key:   key,
 		token: resp.Value(),
 		dmap:  d,
 	}
-	return ctx, nil
+	return lctx, nil
 }
 
 // Unlock releases an acquired lock for the given key.
 // It returns olric.ErrNoSuchLock if there is no lock for the given key.
 func (l *LockContext) Unlock() error {
+	return l.UnlockContext(context.Background())
+}
+
+// UnlockContext is like Unlock but the given context cancels the network operations.
+func (l *LockContext) UnlockContext(ctx context.Context) error {
 	req := protocol.NewDMapMessage(protocol.OpUnlock)
 	req.SetDMap(l.name)
 	req.SetKey(l.key)
 	req.SetValue(l.token)
-	resp, err := l.dmap.request(req)
+	resp, err := l.dmap.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -220,6 +511,11 @@ func (l *LockContext) Unlock() error {
 // Lease update the expiry of an acquired lock for the given key.
 // It returns olric.ErrNoSuchLock if there is no lock or already expired for the given key.
 func (l *LockContext) Lease(timeout time.Duration) error {
+	return l.LeaseContext(context.Background(), timeout)
+}
+
+// LeaseContext is like Lease but the given context cancels the network operations.
+func (l *LockContext) LeaseContext(ctx context.Context, timeout time.Duration) error {
 	req := protocol.NewDMapMessage(protocol.OpLockLease)
 	req.SetDMap(l.name)
 	req.SetKey(l.key)
@@ -227,7 +523,7 @@ func (l *LockContext) Lease(timeout time.Duration) error {
 	req.SetExtra(protocol.LockLeaseExtra{
 		Timeout: int64(timeout),
 	})
-	resp, err := l.dmap.request(req)
+	resp, err := l.dmap.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -238,15 +534,47 @@ func (l *LockContext) Lease(timeout time.Duration) error {
 // So if you call Put/PutEx/PutIf/PutIfEx and Destroy methods concurrently on the cluster,
 // those calls may set new values to the dmap.
 func (d *DMap) Destroy() error {
+	return d.DestroyContext(context.Background())
+}
+
+// DestroyContext is like Destroy but the given context cancels the network operations.
+func (d *DMap) DestroyContext(ctx context.Context) error {
+	if d.nearCache != nil {
+		defer d.nearCache.purge()
+	}
+
 	req := protocol.NewDMapMessage(protocol.OpDestroy)
 	req.SetDMap(d.name)
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
 	return checkStatusCode(resp)
 }
 
+// Len returns the number of the keys in the DMap. The primary owners of the partitions
+// count their keys, so the backups are not counted. Expired keys are not counted,
+// even if they are not evicted yet.
+func (d *DMap) Len() (int, error) {
+	return d.LenContext(context.Background())
+}
+
+// LenContext is like Len but the given context cancels the network operations.
+func (d *DMap) LenContext(ctx context.Context) (int, error) {
+	req := protocol.NewDMapMessage(protocol.OpLen)
+	req.SetDMap(d.name)
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkStatusCode(resp); err != nil {
+		return 0, err
+	}
+	var count int
+	err = msgpack.Unmarshal(resp.Value(), &count)
+	return count, err
+}
+
 func valueToInt(delta interface{}) (int, error) {
 	switch value := delta.(type) {
 	case int:
@@ -275,7 +603,7 @@ func (c *Client) processIncrDecrResponse(resp protocol.EncodeDecoder) (int, erro
 	return valueToInt(value)
 }
 
-func (c *Client) incrDecr(op protocol.OpCode, name, key string, delta int) (int, error) {
+func (c *Client) incrDecr(ctx context.Context, op protocol.OpCode, name, key string, delta int) (int, error) {
 	value, err := c.serializer.Marshal(delta)
 	if err != nil {
 		fmt.Println(delta, err)
@@ -288,7 +616,7 @@ func (c *Client) incrDecr(op protocol.OpCode, name, key string, delta int) (int,
 	req.SetExtra(protocol.AtomicExtra{
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := c.request(req)
+	resp, err := c.requestContext(ctx, req)
 	if err != nil {
 		return 0, err
 	}
@@ -297,12 +625,26 @@ func (c *Client) incrDecr(op protocol.OpCode, name, key string, delta int) (int,
 
 // Incr atomically increments key by delta. The return value is the new value after being incremented or an error.
 func (d *DMap) Incr(key string, delta int) (int, error) {
-	return d.incrDecr(protocol.OpIncr, d.name, key, delta)
+	return d.IncrContext(context.Background(), key, delta)
+}
+
+// IncrContext is like Incr but the given context cancels the network operations.
+func (d *DMap) IncrContext(ctx context.Context, key string, delta int) (int, error) {
+	defer d.invalidate(key)
+
+	return d.incrDecr(ctx, protocol.OpIncr, d.name, key, delta)
 }
 
 // Decr atomically decrements key by delta. The return value is the new value after being decremented or an error.
 func (d *DMap) Decr(key string, delta int) (int, error) {
-	return d.incrDecr(protocol.OpDecr, d.name, key, delta)
+	return d.DecrContext(context.Background(), key, delta)
+}
+
+// DecrContext is like Decr but the given context cancels the network operations.
+func (d *DMap) DecrContext(ctx context.Context, key string, delta int) (int, error) {
+	defer d.invalidate(key)
+
+	return d.incrDecr(ctx, protocol.OpDecr, d.name, key, delta)
 }
 
 func (c *Client) processGetPutResponse(resp protocol.EncodeDecoder) (interface{}, error) {
@@ -321,6 +663,13 @@ func (c *Client) processGetPutResponse(resp protocol.EncodeDecoder) (interface{}
 
 // GetPut atomically sets key to value and returns the old value stored at key.
 func (d *DMap) GetPut(key string, value interface{}) (interface{}, error) {
+	return d.GetPutContext(context.Background(), key, value)
+}
+
+// GetPutContext is like GetPut but the given context cancels the network operations.
+func (d *DMap) GetPutContext(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	defer d.invalidate(key)
+
 	data, err := d.serializer.Marshal(value)
 	if err != nil {
 		return nil, err
@@ -332,7 +681,7 @@ func (d *DMap) GetPut(key string, value interface{}) (interface{}, error) {
 	req.SetExtra(protocol.AtomicExtra{
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -340,8 +689,15 @@ func (d *DMap) GetPut(key string, value interface{}) (interface{}, error) {
 }
 
 // Expire updates the expiry for the given key. It returns ErrKeyNotFound if the
-// DB does not contains the key. It's thread-safe.
+// DB does not contains the key, or the key is already expired or idle. It's thread-safe.
 func (d *DMap) Expire(key string, timeout time.Duration) error {
+	return d.ExpireContext(context.Background(), key, timeout)
+}
+
+// ExpireContext is like Expire but the given context cancels the network operations.
+func (d *DMap) ExpireContext(ctx context.Context, key string, timeout time.Duration) error {
+	defer d.invalidate(key)
+
 	req := protocol.NewDMapMessage(protocol.OpExpire)
 	req.SetDMap(d.name)
 	req.SetKey(key)
@@ -349,13 +705,82 @@ func (d *DMap) Expire(key string, timeout time.Duration) error {
 		TTL:       timeout.Nanoseconds(),
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
 	return checkStatusCode(resp)
 }
 
+// TTL returns the remaining time to live of the given key. It returns zero, if the
+// key has no expiry. It returns ErrKeyNotFound if the DB does not contain the key.
+// The value is not transferred.
+func (d *DMap) TTL(key string) (time.Duration, error) {
+	return d.TTLContext(context.Background(), key)
+}
+
+// TTLContext is like TTL but the given context cancels the network operations.
+func (d *DMap) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	req := protocol.NewDMapMessage(protocol.OpTTL)
+	req.SetDMap(d.name)
+	req.SetKey(key)
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return d.processTTLResponse(resp)
+}
+
+func (c *Client) processTTLResponse(resp protocol.EncodeDecoder) (time.Duration, error) {
+	if err := checkStatusCode(resp); err != nil {
+		return 0, err
+	}
+	var ttl int64
+	err := msgpack.Unmarshal(resp.Value(), &ttl)
+	return time.Duration(ttl), err
+}
+
+// Persist removes the expiry of the given key. It returns ErrKeyNotFound if the DB
+// does not contain the key. It's thread-safe.
+func (d *DMap) Persist(key string) error {
+	return d.PersistContext(context.Background(), key)
+}
+
+// PersistContext is like Persist but the given context cancels the network operations.
+func (d *DMap) PersistContext(ctx context.Context, key string) error {
+	// A zero timeout removes the expiry.
+	return d.ExpireContext(ctx, key, 0)
+}
+
+// GetEx gets the value for the given key and updates its expiry in one round trip.
+// A zero timeout removes the expiry, like Persist. It returns ErrKeyNotFound if the
+// DB does not contain the key.
+func (d *DMap) GetEx(key string, timeout time.Duration) (interface{}, error) {
+	return d.GetExContext(context.Background(), key, timeout)
+}
+
+// GetExContext is like GetEx but the given context cancels the network operations.
+func (d *DMap) GetExContext(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	defer d.invalidate(key)
+
+	req := protocol.NewDMapMessage(protocol.OpGetEx)
+	req.SetDMap(d.name)
+	req.SetKey(key)
+	req.SetExtra(protocol.GetExExtra{
+		TTL: timeout.Nanoseconds(),
+	})
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatusCode(resp); err != nil {
+		return nil, err
+	}
+	entry := d.getEntryFormat(d.name)
+	entry.Decode(resp.Value())
+	return d.unmarshalValue(entry.Value())
+}
+
 // PutIf sets the value for the given key. It overwrites any previous value for that key and it's thread-safe.
 // It is safe to modify the contents of the arguments after PutIf returns but not before.
 // Flag argument currently has two different options:
@@ -366,6 +791,13 @@ func (d *DMap) Expire(key string, timeout time.Duration) error {
 // olric.IfFound: Only set the key if it already exist.
 // It returns olric.ErrKeyNotFound if the key does not exist.
 func (d *DMap) PutIf(key string, value interface{}, flags int16) error {
+	return d.PutIfContext(context.Background(), key, value, flags)
+}
+
+// PutIfContext is like PutIf but the given context cancels the network operations.
+func (d *DMap) PutIfContext(ctx context.Context, key string, value interface{}, flags int16) error {
+	defer d.invalidate(key)
+
 	data, err := d.serializer.Marshal(value)
 	if err != nil {
 		return err
@@ -378,7 +810,37 @@ func (d *DMap) PutIf(key string, value interface{}, flags int16) error {
 		Flags:     flags,
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return err
+	}
+	return checkStatusCode(resp)
+}
+
+// CompareAndSwap sets the value for the given key, if the current version of the key is still the expected one.
+// The version of an entry is returned by GetEntry. Zero version means that the key must not exist.
+// It returns olric.ErrVersionMismatch if the key has been modified since the version was read. It's thread-safe.
+func (d *DMap) CompareAndSwap(key string, version int64, value interface{}) error {
+	return d.CompareAndSwapContext(context.Background(), key, version, value)
+}
+
+// CompareAndSwapContext is like CompareAndSwap but the given context cancels the network operations.
+func (d *DMap) CompareAndSwapContext(ctx context.Context, key string, version int64, value interface{}) error {
+	defer d.invalidate(key)
+
+	data, err := d.serializer.Marshal(value)
+	if err != nil {
+		return err
+	}
+	req := protocol.NewDMapMessage(protocol.OpCompareAndSwap)
+	req.SetDMap(d.name)
+	req.SetKey(key)
+	req.SetValue(data)
+	req.SetExtra(protocol.CompareAndSwapExtra{
+		Version:   version,
+		Timestamp: time.Now().UnixNano(),
+	})
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -395,6 +857,13 @@ func (d *DMap) PutIf(key string, value interface{}, flags int16) error {
 // olric.IfFound: Only set the key if it already exist.
 // It returns olric.ErrKeyNotFound if the key does not exist.
 func (d *DMap) PutIfEx(key string, value interface{}, timeout time.Duration, flags int16) error {
+	return d.PutIfExContext(context.Background(), key, value, timeout, flags)
+}
+
+// PutIfExContext is like PutIfEx but the given context cancels the network operations.
+func (d *DMap) PutIfExContext(ctx context.Context, key string, value interface{}, timeout time.Duration, flags int16) error {
+	defer d.invalidate(key)
+
 	data, err := d.serializer.Marshal(value)
 	if err != nil {
 		return err
@@ -408,7 +877,7 @@ func (d *DMap) PutIfEx(key string, value interface{}, timeout time.Duration, fla
 		TTL:       timeout.Nanoseconds(),
 		Timestamp: time.Now().UnixNano(),
 	})
-	resp, err := d.request(req)
+	resp, err := d.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
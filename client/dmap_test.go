@@ -15,7 +15,9 @@
 package client
 
 import (
+	"context"
 	"log"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -204,6 +206,98 @@ func TestClient_Delete(t *testing.T) {
 	}
 }
 
+func TestClient_Len(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	for i := 0; i < 10; i++ {
+		err = dm.Put(strconv.Itoa(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+	err = dm.Delete("0")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	count, err := dm.Len()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 9 {
+		t.Fatalf("Expected count is 9. Got: %d", count)
+	}
+}
+
+func TestClient_TTL_Persist_GetEx(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	err = dm.PutEx("my-key", "my-value", time.Hour)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	ttl, err := dm.TTL("my-key")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("Unexpected TTL: %v", ttl)
+	}
+
+	value, err := dm.GetEx("my-key", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if value.(string) != "my-value" {
+		t.Fatalf("Expected my-value. Got: %v", value)
+	}
+	ttl, err = dm.TTL("my-key")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Unexpected TTL: %v", ttl)
+	}
+
+	err = dm.Persist("my-key")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	ttl, err = dm.TTL("my-key")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if ttl != 0 {
+		t.Fatalf("Expected no expiry. Got: %v", ttl)
+	}
+
+	_, err = dm.TTL("missing-key")
+	if err != olric.ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound. Got: %v", err)
+	}
+}
+
 func TestClient_LockWithTimeout(t *testing.T) {
 	srv, err := testolric.New(t)
 	if err != nil {
@@ -282,6 +376,41 @@ func TestClient_Lock(t *testing.T) {
 	}
 }
 
+func TestClient_GetContext_Canceled(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mydmap")
+	err = dm.Put("mykey", "myvalue")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = dm.GetContext(ctx, "mykey")
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled. Got: %v", err)
+	}
+
+	// The connection pool is still usable.
+	value, err := dm.GetContext(context.Background(), "mykey")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if value != "myvalue" {
+		t.Fatalf("Expected myvalue. Got: %v", value)
+	}
+}
+
 func TestClient_LockAwaitOtherLock(t *testing.T) {
 	srv, err := testolric.New(t)
 	if err != nil {
@@ -591,3 +720,96 @@ func TestClient_PutIfEx(t *testing.T) {
 		t.Fatalf("Expected nil. Got: %v", v)
 	}
 }
+
+func TestClient_CompareAndSwap(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	key := "my-key"
+	err = dm.Put(key, "value-1")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	entry, err := dm.GetEntry(key)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	err = dm.CompareAndSwap(key, entry.Version, "value-2")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	err = dm.CompareAndSwap(key, entry.Version, "value-3")
+	if err != olric.ErrVersionMismatch {
+		t.Fatalf("Expected olric.ErrVersionMismatch. Got: %v", err)
+	}
+
+	val, err := dm.Get(key)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if val.(string) != "value-2" {
+		t.Fatalf("Expected value-2. Got: %v", val)
+	}
+}
+
+func TestClient_MPut_MGet_MDelete(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	items := make(map[string]interface{})
+	var keys []string
+	for i := 0; i < 10; i++ {
+		key := "key-" + strconv.Itoa(i)
+		items[key] = i
+		keys = append(keys, key)
+	}
+	errs := dm.MPut(items)
+	if len(errs) != 0 {
+		t.Fatalf("Expected no error. Got: %v", errs)
+	}
+
+	values, errs := dm.MGet(append(keys, "missing-key"))
+	if len(errs) != 1 {
+		t.Fatalf("Expected one error. Got: %v", errs)
+	}
+	if errs["missing-key"] != olric.ErrKeyNotFound {
+		t.Fatalf("Expected olric.ErrKeyNotFound. Got: %v", errs["missing-key"])
+	}
+	for i, key := range keys {
+		if values[key] != i {
+			t.Fatalf("Expected %d. Got: %v", i, values[key])
+		}
+	}
+
+	errs = dm.MDelete(keys)
+	if len(errs) != 0 {
+		t.Fatalf("Expected no error. Got: %v", errs)
+	}
+	for _, key := range keys {
+		_, err = dm.Get(key)
+		if err != olric.ErrKeyNotFound {
+			t.Fatalf("Expected olric.ErrKeyNotFound. Got: %v", err)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"sync"
@@ -62,6 +63,11 @@ func (c *Client) NewDTopic(name string, concurrency int, flag int16) (*DTopic, e
 
 // Publish sends a message to the given topic. It accepts any serializable type as message.
 func (dt *DTopic) Publish(msg interface{}) error {
+	return dt.PublishContext(context.Background(), msg)
+}
+
+// PublishContext is like Publish but the given context cancels the network operations.
+func (dt *DTopic) PublishContext(ctx context.Context, msg interface{}) error {
 	value, err := dt.serializer.Marshal(msg)
 	if err != nil {
 		return err
@@ -69,7 +75,7 @@ func (dt *DTopic) Publish(msg interface{}) error {
 	req := protocol.NewDTopicMessage(protocol.OpDTopicPublish)
 	req.SetDTopic(dt.name)
 	req.SetValue(value)
-	resp, err := dt.request(req)
+	resp, err := dt.requestContext(ctx, req)
 	if err != nil {
 		return err
 	}
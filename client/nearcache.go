@@ -0,0 +1,285 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric"
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/nearcache"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack"
+)
+
+// DefaultNearCacheMaxKeys is the maximum number of keys in the near cache of a
+// DMap, if NearCacheConfig.MaxKeys is not set.
+const DefaultNearCacheMaxKeys = 65536
+
+// DefaultNearCacheTTL is the maximum time to keep an entry in the near cache, if
+// NearCacheConfig.TTL is not set.
+const DefaultNearCacheTTL = time.Minute
+
+// nearCacheRetryInterval is the delay between the attempts to subscribe to the
+// keyspace notifications of a DMap.
+const nearCacheRetryInterval = time.Second
+
+// NearCacheConfig is the configuration of the near caches. A near cache keeps
+// the recently read entries of a DMap in the client's memory.
+//
+// The near cache is kept coherent by the keyspace notifications of the DMap, so
+// KeyspaceNotifications has to be enabled for the DMap on the cluster. A key is
+// removed from the near cache when it's put, deleted, expired or evicted on the
+// cluster. The notifications are delivered on a best-effort basis, they are
+// lost if KeyspaceNotifications is disabled or the notification queue of the
+// cluster is full. TTL limits the staleness of the entries in that case.
+type NearCacheConfig struct {
+	// MaxKeys is the maximum number of keys in the near cache of a DMap. It's
+	// DefaultNearCacheMaxKeys by default.
+	MaxKeys int
+
+	// TTL is the maximum time to keep an entry in the near cache. The entries are
+	// never kept longer than their TTL on the cluster. It's DefaultNearCacheTTL
+	// by default.
+	TTL time.Duration
+
+	// EvictionPolicy decides the key to evict when MaxKeys is reached.
+	// config.LRUEviction evicts the least recently used key. Otherwise, the
+	// oldest key is evicted.
+	EvictionPolicy config.EvictionPolicy
+}
+
+// Sanitize sets default values to empty configuration variables, if it's possible.
+func (n *NearCacheConfig) Sanitize() error {
+	if n.MaxKeys == 0 {
+		n.MaxKeys = DefaultNearCacheMaxKeys
+	}
+	if n.TTL == 0 {
+		n.TTL = DefaultNearCacheTTL
+	}
+	if n.EvictionPolicy == "" {
+		n.EvictionPolicy = "NONE"
+	}
+	return nil
+}
+
+// Validate finds errors in the current configuration.
+func (n *NearCacheConfig) Validate() error {
+	if n.MaxKeys < 0 {
+		return fmt.Errorf("MaxKeys cannot be negative: %w", olric.ErrInvalidArgument)
+	}
+	if n.TTL < 0 {
+		return fmt.Errorf("TTL cannot be negative: %w", olric.ErrInvalidArgument)
+	}
+	return nil
+}
+
+// nearCache is the near cache of a DMap. The entries are kept in a
+// nearcache.NearCache, nearCache adds the subscription to the keyspace
+// notifications. It's thread-safe.
+type nearCache struct {
+	mtx sync.Mutex
+
+	c      *Client
+	name   string
+	config *NearCacheConfig
+	cache  *nearcache.NearCache
+
+	listenerID  uint64
+	subscribing bool
+	retryAt     time.Time
+}
+
+func newNearCache(c *Client, name string) *nearCache {
+	return &nearCache{
+		c:      c,
+		name:   name,
+		config: c.config.NearCache,
+		cache: nearcache.New(&config.NearCache{
+			MaxKeys:        c.config.NearCache.MaxKeys,
+			TTL:            c.config.NearCache.TTL,
+			EvictionPolicy: c.config.NearCache.EvictionPolicy,
+		}),
+	}
+}
+
+// nearCache returns the near cache of the given DMap, it's nil if the near
+// caches are disabled.
+func (c *Client) nearCache(name string) *nearCache {
+	if c.config.NearCache == nil {
+		return nil
+	}
+
+	c.nearCachesMtx.Lock()
+	defer c.nearCachesMtx.Unlock()
+
+	nc, ok := c.nearCaches[name]
+	if !ok {
+		nc = newNearCache(c, name)
+		c.nearCaches[name] = nc
+	}
+	return nc
+}
+
+// get returns the encoded entry for the given key.
+func (nc *nearCache) get(key string) ([]byte, bool) {
+	raw, ok := nc.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return raw.([]byte), true
+}
+
+// begin registers a Get call for the given key. It returns nil, if the response
+// cannot be cached.
+func (nc *nearCache) begin(key string) *nearcache.Load {
+	if !nc.subscribe() {
+		return nil
+	}
+	return nc.cache.Begin(key)
+}
+
+// finish caches the response of a Get call, if the key has not been invalidated
+// in the meantime. ttl is the expiry of the entry in milliseconds. raw is nil,
+// if the Get call has failed.
+func (nc *nearCache) finish(key string, load *nearcache.Load, raw []byte, ttl int64) {
+	nc.mtx.Lock()
+	defer nc.mtx.Unlock()
+
+	if raw == nil || nc.listenerID == 0 {
+		// The listener is gone, the invalidations may be missed.
+		nc.cache.Finish(key, load, nil, 0, 0)
+		return
+	}
+	nc.cache.Finish(key, load, raw, len(raw), ttl*1000000)
+}
+
+// invalidate removes the given key from the near cache.
+func (nc *nearCache) invalidate(key string) {
+	nc.cache.Invalidate(key)
+}
+
+// purge removes all the keys from the near cache.
+func (nc *nearCache) purge() {
+	nc.cache.Purge()
+}
+
+// subscribe adds a listener to the keyspace notifications of the DMap, if it's
+// not added yet. It returns true if the listener is ready.
+func (nc *nearCache) subscribe() bool {
+	nc.mtx.Lock()
+	if nc.listenerID != 0 {
+		nc.mtx.Unlock()
+		return true
+	}
+	if nc.subscribing || time.Now().Before(nc.retryAt) {
+		nc.mtx.Unlock()
+		return false
+	}
+	nc.subscribing = true
+	nc.mtx.Unlock()
+
+	l := newListener()
+	s, listenerID, err := nc.addListener(l)
+
+	nc.mtx.Lock()
+	defer nc.mtx.Unlock()
+
+	nc.subscribing = false
+	if err != nil {
+		logger.Printf("[ERROR] Failed to subscribe to keyspace notifications of DMap: %s: %v\n", nc.name, err)
+		nc.retryAt = time.Now().Add(nearCacheRetryInterval)
+		return false
+	}
+	nc.listenerID = listenerID
+	// The notifications are missed until the listener is added. Drop the entries
+	// and the Get calls that are cached or started before.
+	nc.purge()
+
+	nc.c.wg.Add(1)
+	go nc.listen(s, l)
+	return true
+}
+
+func (nc *nearCache) addListener(l *listener) (*stream, uint64, error) {
+	streamID, listenerID, err := nc.c.addStreamListener(l)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req := protocol.NewDTopicMessage(protocol.OpDTopicAddListener)
+	req.SetDTopic(olric.KeyspaceTopic(nc.name))
+	req.SetExtra(protocol.DTopicAddListenerExtra{
+		ListenerID: listenerID,
+		StreamID:   streamID,
+	})
+	resp, err := nc.c.request(req)
+	if err == nil {
+		err = checkStatusCode(resp)
+	}
+	if err != nil {
+		_ = nc.c.removeStreamListener(listenerID)
+		return nil, 0, err
+	}
+
+	nc.c.streams.mu.RLock()
+	defer nc.c.streams.mu.RUnlock()
+	s, ok := nc.c.streams.m[streamID]
+	if !ok {
+		return nil, 0, fmt.Errorf("no stream found with given ID")
+	}
+	return s, listenerID, nil
+}
+
+// listen removes the keys from the near cache, when the keyspace notifications
+// are received. The near cache is purged if the stream is gone, since the
+// notifications may be missed until the listener is added again.
+func (nc *nearCache) listen(s *stream, l *listener) {
+	defer nc.c.wg.Done()
+
+	for {
+		select {
+		case req := <-l.read:
+			var msg olric.DTopicMessage
+			err := msgpack.Unmarshal(req.Value(), &msg)
+			if err != nil {
+				logger.Printf("[ERROR] Failed to unmarshal keyspace notification: %v\n", err)
+				nc.purge()
+				continue
+			}
+			event, ok := msg.Message.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if key, ok := event["key"].(string); ok {
+				nc.invalidate(key)
+			}
+		case <-s.ctx.Done():
+			nc.mtx.Lock()
+			nc.listenerID = 0
+			nc.retryAt = time.Now().Add(nearCacheRetryInterval)
+			nc.purge()
+			nc.mtx.Unlock()
+			// The stream is gone with its listeners. Client.Close holds the lock of
+			// the streams while waiting for this goroutine, don't try to remove it.
+			l.cancel()
+			return
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric"
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testolric"
+)
+
+func newTestNearCache(t *testing.T, nc *NearCacheConfig) *nearCache {
+	c, err := New(&Config{
+		Servers:   []string{"127.0.0.1:0"},
+		Client:    &config.Client{},
+		NearCache: nc,
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	cache := c.nearCache("mydmap")
+	// Pretend that the listener has been added.
+	cache.listenerID = 1
+	return cache
+}
+
+func TestClient_NearCache_Eviction(t *testing.T) {
+	nc := newTestNearCache(t, &NearCacheConfig{
+		MaxKeys:        2,
+		EvictionPolicy: config.LRUEviction,
+	})
+	for _, key := range []string{"a", "b"} {
+		nc.finish(key, nc.begin(key), []byte(key), 0)
+	}
+	if _, ok := nc.get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+	// b is the least recently used key.
+	nc.finish("c", nc.begin("c"), []byte("c"), 0)
+	if _, ok := nc.get("b"); ok {
+		t.Fatalf("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := nc.get(key); !ok {
+			t.Fatalf("Expected %s to be cached", key)
+		}
+	}
+}
+
+func TestClient_NearCache_TTL(t *testing.T) {
+	nc := newTestNearCache(t, &NearCacheConfig{TTL: time.Millisecond})
+	nc.finish("mykey", nc.begin("mykey"), []byte("myvalue"), 0)
+	<-time.After(5 * time.Millisecond)
+	if _, ok := nc.get("mykey"); ok {
+		t.Fatalf("Expected mykey to be expired")
+	}
+
+	// The entries are never kept forever.
+	nc = newTestNearCache(t, &NearCacheConfig{})
+	if nc.config.TTL != DefaultNearCacheTTL {
+		t.Fatalf("Expected TTL: %v. Got: %v", DefaultNearCacheTTL, nc.config.TTL)
+	}
+
+	// The expiry of the entry on the cluster is in milliseconds.
+	ttl := (time.Now().UnixNano() / 1000000) - 1
+	nc.finish("mykey", nc.begin("mykey"), []byte("myvalue"), ttl)
+	if _, ok := nc.get("mykey"); ok {
+		t.Fatalf("Expected mykey to be expired")
+	}
+}
+
+func TestClient_NearCache_Invalidate_During_Get(t *testing.T) {
+	nc := newTestNearCache(t, &NearCacheConfig{})
+	load := nc.begin("mykey")
+	nc.invalidate("mykey")
+	nc.finish("mykey", load, []byte("myvalue"), 0)
+	if _, ok := nc.get("mykey"); ok {
+		t.Fatalf("Expected mykey not to be cached")
+	}
+}
+
+func TestClient_NearCache(t *testing.T) {
+	srv, err := testolric.New(t, func(c *config.Config) {
+		c.DMaps.KeyspaceNotifications = true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+	tc.NearCache = &NearCacheConfig{}
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	defer c.Close()
+
+	dm := c.NewDMap("mydmap")
+	if err = dm.Put("mykey", "myvalue"); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	value, err := dm.Get("mykey")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if value != "myvalue" {
+		t.Fatalf("Expected myvalue. Got: %v", value)
+	}
+	if _, ok := dm.nearCache.get("mykey"); !ok {
+		t.Fatalf("Expected mykey to be cached")
+	}
+
+	// Modify the key on the cluster, the client receives an invalidation message.
+	odm, err := srv.Olric().NewDMap("mydmap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = odm.Put("mykey", "newvalue"); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		value, err = dm.Get("mykey")
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if value == "newvalue" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected newvalue. Got: %v", value)
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+
+	// The writes of the client invalidate the near cache immediately.
+	if err = dm.Delete("mykey"); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if _, err = dm.Get("mykey"); err != olric.ErrKeyNotFound {
+		t.Fatalf("Expected olric.ErrKeyNotFound. Got: %v", err)
+	}
+}
@@ -16,6 +16,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"sync"
 	"time"
@@ -195,6 +196,30 @@ func (p *Pipeline) PutIf(dmap, key string, value interface{}, flags int16) error
 	return req.Encode()
 }
 
+// CompareAndSwap appends a CompareAndSwap command to the underlying buffer with the given parameters.
+//
+// It returns olric.ErrVersionMismatch if the key has been modified since the version was read.
+func (p *Pipeline) CompareAndSwap(dmap, key string, version int64, value interface{}) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data, err := p.c.serializer.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	req := protocol.NewDMapMessage(protocol.OpCompareAndSwap)
+	req.SetBuffer(p.buf)
+	req.SetDMap(dmap)
+	req.SetKey(key)
+	req.SetValue(data)
+	req.SetExtra(protocol.CompareAndSwapExtra{
+		Version:   version,
+		Timestamp: time.Now().UnixNano(),
+	})
+	return req.Encode()
+}
+
 // PutIfEx appends a PutIfEx command to the underlying buffer.
 //
 // Flag argument currently has two different options:
@@ -243,15 +268,53 @@ func (p *Pipeline) Expire(dmap, key string, timeout time.Duration) error {
 	return req.Encode()
 }
 
+// TTL appends a TTL command to the underlying buffer with the given parameters.
+func (p *Pipeline) TTL(dmap, key string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	req := protocol.NewDMapMessage(protocol.OpTTL)
+	req.SetBuffer(p.buf)
+	req.SetDMap(dmap)
+	req.SetKey(key)
+	return req.Encode()
+}
+
+// Persist appends a Persist command to the underlying buffer with the given parameters.
+// It's an Expire command with zero timeout.
+func (p *Pipeline) Persist(dmap, key string) error {
+	return p.Expire(dmap, key, 0)
+}
+
+// GetEx appends a GetEx command to the underlying buffer with the given parameters.
+func (p *Pipeline) GetEx(dmap, key string, timeout time.Duration) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	req := protocol.NewDMapMessage(protocol.OpGetEx)
+	req.SetBuffer(p.buf)
+	req.SetDMap(dmap)
+	req.SetKey(key)
+	req.SetExtra(protocol.GetExExtra{
+		TTL: timeout.Nanoseconds(),
+	})
+	return req.Encode()
+}
+
 // Flush flushes all the commands to the server using a single write call.
 func (p *Pipeline) Flush() ([]PipelineResponse, error) {
+	return p.FlushContext(context.Background())
+}
+
+// FlushContext is like Flush but the given context cancels the network operations.
+func (p *Pipeline) FlushContext(ctx context.Context) ([]PipelineResponse, error) {
 	p.m.Lock()
 	defer p.m.Unlock()
 	defer p.buf.Reset()
 
 	req := protocol.NewPipelineMessage(protocol.OpPipeline)
 	req.SetValue(p.buf.Bytes())
-	resp, err := p.c.request(req)
+	resp, err := p.c.requestContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -15,6 +15,8 @@
 package client
 
 import (
+	"time"
+
 	"github.com/buraksezer/olric/internal/kvstore/entry"
 	"github.com/buraksezer/olric/internal/protocol"
 )
@@ -34,6 +36,8 @@ func (pr *PipelineResponse) Operation() string {
 		return "PutIf"
 	case pr.response.OpCode() == protocol.OpPutIfEx:
 		return "PutIfEx"
+	case pr.response.OpCode() == protocol.OpCompareAndSwap:
+		return "CompareAndSwap"
 	case pr.response.OpCode() == protocol.OpGet:
 		return "Get"
 	case pr.response.OpCode() == protocol.OpPutEx:
@@ -52,6 +56,12 @@ func (pr *PipelineResponse) Operation() string {
 		return "Unlock"
 	case pr.response.OpCode() == protocol.OpDestroy:
 		return "Destroy"
+	case pr.response.OpCode() == protocol.OpExpire:
+		return "Expire"
+	case pr.response.OpCode() == protocol.OpTTL:
+		return "TTL"
+	case pr.response.OpCode() == protocol.OpGetEx:
+		return "GetEx"
 	default:
 		return "unknown"
 	}
@@ -119,6 +129,24 @@ func (pr *PipelineResponse) Expire() error {
 	return checkStatusCode(pr.response)
 }
 
+// TTL returns the remaining time to live of the requested key. It's zero, if the
+// key has no expiry. It returns ErrKeyNotFound if the DB does not contain the key.
+func (pr *PipelineResponse) TTL() (time.Duration, error) {
+	return pr.processTTLResponse(pr.response)
+}
+
+// Persist removes the expiry of the requested key. It returns ErrKeyNotFound if the
+// DB does not contain the key.
+func (pr *PipelineResponse) Persist() error {
+	return checkStatusCode(pr.response)
+}
+
+// GetEx returns the value for the requested key, its expiry has been updated. It returns
+// ErrKeyNotFound if the DB does not contain the key.
+func (pr *PipelineResponse) GetEx() (interface{}, error) {
+	return pr.Get()
+}
+
 func (pr *PipelineResponse) PutIf() error {
 	return checkStatusCode(pr.response)
 }
@@ -126,3 +154,9 @@ func (pr *PipelineResponse) PutIf() error {
 func (pr *PipelineResponse) PutIfEx() error {
 	return checkStatusCode(pr.response)
 }
+
+// CompareAndSwap returns olric.ErrVersionMismatch if the key has been modified since
+// the version was read.
+func (pr *PipelineResponse) CompareAndSwap() error {
+	return checkStatusCode(pr.response)
+}
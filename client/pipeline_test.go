@@ -609,3 +609,110 @@ func TestPipeline_PutIfEx(t *testing.T) {
 		}
 	}
 }
+
+func TestPipeline_CompareAndSwap(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	p := c.NewPipeline()
+
+	dmap := "mydmap"
+	for i := 0; i < 10; i++ {
+		key := "key-" + strconv.Itoa(i)
+		// Zero version means that the key must not exist.
+		err = p.CompareAndSwap(dmap, key, 0, i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		err = p.CompareAndSwap(dmap, key, 0, i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	responses, err := p.Flush()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i, res := range responses {
+		if res.Operation() != "CompareAndSwap" {
+			t.Fatalf("Expected CompareAndSwap. Got: %v", res.Operation())
+		}
+		err = res.CompareAndSwap()
+		if i%2 == 0 && err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if i%2 == 1 && err != olric.ErrVersionMismatch {
+			t.Fatalf("Expected olric.ErrVersionMismatch. Got: %v", err)
+		}
+	}
+}
+
+func TestPipeline_TTL_Persist_GetEx(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	p := c.NewPipeline()
+
+	dmap := "mydmap"
+	key := "key-" + strconv.Itoa(1)
+	if err = p.PutEx(dmap, key, 1, time.Hour); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = p.GetEx(dmap, key, time.Minute); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = p.TTL(dmap, key); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = p.Persist(dmap, key); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	responses, err := p.Flush()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if len(responses) != 4 {
+		t.Fatalf("Expected 4 responses. Got: %d", len(responses))
+	}
+	value, err := responses[1].GetEx()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if value.(int) != 1 {
+		t.Fatalf("Expected 1. Got: %v", value)
+	}
+	ttl, err := responses[2].TTL()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("Unexpected TTL: %v", ttl)
+	}
+	if err = responses[3].Persist(); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	ttl, err = c.NewDMap(dmap).TTL(key)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if ttl != 0 {
+		t.Fatalf("Expected no expiry. Got: %v", ttl)
+	}
+}
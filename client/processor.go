@@ -0,0 +1,63 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack"
+)
+
+// Execute runs the entry processor that is registered with the given name on
+// the key, under the lock of the key on the partition owner. The entry processors
+// are registered on the cluster members, see olric.RegisterEntryProcessor. It
+// returns the new value of the key, nil if the entry processor has deleted the key.
+func (d *DMap) Execute(key, name string, args interface{}) (interface{}, error) {
+	return d.ExecuteContext(context.Background(), key, name, args)
+}
+
+// ExecuteContext is like Execute but the given context cancels the network operations.
+func (d *DMap) ExecuteContext(ctx context.Context, key, name string, args interface{}) (interface{}, error) {
+	defer d.invalidate(key)
+
+	if args == nil {
+		args = struct{}{}
+	}
+	raw, err := d.serializer.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	value, err := msgpack.Marshal(protocol.EntryProcessorCall{Name: name, Args: raw})
+	if err != nil {
+		return nil, err
+	}
+
+	req := protocol.NewDMapMessage(protocol.OpExecuteOnKey)
+	req.SetDMap(d.name)
+	req.SetKey(key)
+	req.SetValue(value)
+	resp, err := d.requestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatusCode(resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Value()) == 0 {
+		return nil, nil
+	}
+	return d.unmarshalValue(resp.Value())
+}
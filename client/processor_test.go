@@ -0,0 +1,63 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric"
+	"github.com/buraksezer/olric/internal/testolric"
+)
+
+func TestClient_Execute(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	err = srv.Olric().RegisterEntryProcessor("appendItem", func(entry *olric.Entry, args interface{}) (interface{}, error) {
+		if entry == nil {
+			return args, nil
+		}
+		return entry.Value.(string) + "," + args.(string), nil
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mydmap")
+	for _, item := range []string{"a", "b", "c"} {
+		if _, err = dm.Execute("mykey", "appendItem", item); err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+	value, err := dm.Get("mykey")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if value != "a,b,c" {
+		t.Fatalf("Expected a,b,c. Got: %v", value)
+	}
+
+	_, err = dm.Execute("mykey", "unknown", nil)
+	if err != olric.ErrInvalidArgument {
+		t.Fatalf("Expected olric.ErrInvalidArgument. Got: %v", err)
+	}
+}
@@ -17,6 +17,7 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/buraksezer/olric"
@@ -29,10 +30,16 @@ import (
 
 // Cursor implements distributed query on DMaps. Call Cursor.Range to iterate over query results.
 type Cursor struct {
-	dm     *DMap
-	query  []byte
+	dm    *DMap
+	query []byte
+	// aggregateQuery is the query with the aggregation operators. It's nil if
+	// there is no aggregation operator.
+	aggregateQuery []byte
+	// limit is the maximum number of entries to yield, zero means no limit.
+	limit  int
 	mu     sync.Mutex
 	wg     sync.WaitGroup
+	parent context.Context
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -42,30 +49,38 @@ func (c *Cursor) Close() {
 	c.cancel()
 }
 
-func (c *Cursor) runQueryOnPartition(partID uint64) (olric.QueryResponse, error) {
-	req := protocol.NewDMapMessage(protocol.OpQuery)
+func (c *Cursor) requestPartition(op protocol.OpCode, partID uint64, q []byte) ([]byte, error) {
+	req := protocol.NewDMapMessage(op)
 	req.SetDMap(c.dm.name)
-	req.SetValue(c.query)
+	req.SetValue(q)
 	req.SetExtra(protocol.QueryExtra{
 		PartID: partID,
 	})
-	resp, err := c.dm.request(req)
+	resp, err := c.dm.requestContext(c.parent, req)
 	if err != nil {
 		return nil, err
 	}
 	if err := checkStatusCode(resp); err != nil {
 		return nil, err
 	}
+	return resp.Value(), nil
+}
+
+func (c *Cursor) runQueryOnPartition(op protocol.OpCode, partID uint64) (olric.QueryResponse, error) {
+	value, err := c.requestPartition(op, partID, c.query)
+	if err != nil {
+		return nil, err
+	}
 
 	var qr olric.QueryResponse
-	err = msgpack.Unmarshal(resp.Value(), &qr)
+	err = msgpack.Unmarshal(value, &qr)
 	if err != nil {
 		return nil, err
 	}
 	return qr, nil
 }
 
-func (c *Cursor) runQueryOnCluster(results chan olric.QueryResponse, errCh chan error) {
+func (c *Cursor) runQueryOnCluster(op protocol.OpCode, results chan olric.QueryResponse, errCh chan error) {
 	defer c.wg.Done()
 	defer close(results)
 
@@ -79,9 +94,14 @@ func (c *Cursor) runQueryOnCluster(results chan olric.QueryResponse, errCh chan
 		return multierror.Append(e, errs)
 	}
 
+	// The end of the partitions stops sending new requests, the responses in
+	// flight are still delivered to the caller.
+	ctx, stop := context.WithCancel(c.ctx)
+	defer stop()
+
 	sem := semaphore.NewWeighted(olric.NumConcurrentWorkers)
 	for {
-		err := sem.Acquire(c.ctx, 1)
+		err := sem.Acquire(ctx, 1)
 		if errors.Is(err, context.Canceled) {
 			break
 		}
@@ -95,9 +115,9 @@ func (c *Cursor) runQueryOnCluster(results chan olric.QueryResponse, errCh chan
 			defer wg.Done()
 			defer sem.Release(1)
 
-			resp, err := c.runQueryOnPartition(id)
+			resp, err := c.runQueryOnPartition(op, id)
 			if errors.Is(err, olric.ErrEndOfQuery) {
-				c.Close()
+				stop()
 				return
 			}
 			if err != nil {
@@ -110,8 +130,7 @@ func (c *Cursor) runQueryOnCluster(results chan olric.QueryResponse, errCh chan
 			case <-c.ctx.Done():
 				// cursor is gone:
 				return
-			default:
-				results <- resp
+			case results <- resp:
 			}
 		}(partID)
 		partID++
@@ -123,32 +142,180 @@ func (c *Cursor) runQueryOnCluster(results chan olric.QueryResponse, errCh chan
 // Range calls f sequentially for each key and value yielded from the cursor. If f returns false,
 // range stops the iteration.
 func (c *Cursor) Range(f func(key string, value interface{}) bool) error {
+	return c.rangeOnCluster(protocol.OpQuery, func(key string, raw interface{}) (bool, error) {
+		value, err := c.dm.unmarshalValue(raw)
+		if err != nil {
+			return false, err
+		}
+		return f(key, value), nil
+	})
+}
+
+// RangeEntries is like Range but it yields the entries with their metadata.
+func (c *Cursor) RangeEntries(f func(e *olric.Entry) bool) error {
+	return c.rangeOnCluster(protocol.OpQueryEntries, func(key string, raw interface{}) (bool, error) {
+		entry := c.dm.getEntryFormat(c.dm.name)
+		entry.Decode(raw.([]byte))
+		value, err := c.dm.unmarshalValue(entry.Value())
+		if err != nil {
+			return false, err
+		}
+		return f(&olric.Entry{
+			Key:        entry.Key(),
+			Value:      value,
+			TTL:        entry.TTL(),
+			Timestamp:  entry.Timestamp(),
+			LastAccess: entry.LastAccess(),
+			Version:    entry.Timestamp(),
+		}), nil
+	})
+}
+
+// rangeOnCluster calls f sequentially for each key and raw value yielded from
+// the cursor. It stops the iteration after $limit entries.
+func (c *Cursor) rangeOnCluster(op protocol.OpCode, f func(key string, raw interface{}) (bool, error)) error {
 	defer c.Close()
 
 	results := make(chan olric.QueryResponse, olric.NumConcurrentWorkers)
 	errCh := make(chan error, 1)
 
 	c.wg.Add(1)
-	go c.runQueryOnCluster(results, errCh)
+	go c.runQueryOnCluster(op, results, errCh)
 
+	var count int
 	for result := range results {
 		for key, rawval := range result {
-			value, err := c.dm.unmarshalValue(rawval)
+			next, err := f(key, rawval)
 			if err != nil {
 				return err
 			}
-			if !f(key, value) {
+			if !next {
 				// This means "break" on the client-side
 				return nil
 			}
+			count++
+			if c.limit > 0 && count >= c.limit {
+				return nil
+			}
 		}
 	}
-	return <-errCh
+	err := <-errCh
+	if c.parent.Err() != nil {
+		// The caller has given up, the results are incomplete.
+		return c.parent.Err()
+	}
+	return err
+}
+
+type queryPage struct {
+	Entries map[string][]byte
+	Next    string
+}
+
+// Page returns at most limit matching key/value pairs with a continuation token.
+// Pass the token to Page of a cursor with the same query to get the next page.
+// The first page is requested with an empty token, the returned token is empty
+// after the last page. The cursor keeps no state between the pages, so a page
+// can be requested by another cursor or client.
+func (c *Cursor) Page(token string, limit int) (olric.QueryResponse, string, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("%w: limit has to be positive", olric.ErrInvalidArgument)
+	}
+
+	req := protocol.NewDMapMessage(protocol.OpQueryPage)
+	req.SetDMap(c.dm.name)
+	req.SetKey(token)
+	req.SetValue(c.query)
+	req.SetExtra(protocol.QueryPageExtra{
+		Limit: uint64(limit),
+	})
+	resp, err := c.dm.requestContext(c.parent, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkStatusCode(resp); err != nil {
+		return nil, "", err
+	}
+
+	var page queryPage
+	err = msgpack.Unmarshal(resp.Value(), &page)
+	if err != nil {
+		return nil, "", err
+	}
+	result := make(olric.QueryResponse)
+	for key, raw := range page.Entries {
+		value, err := c.dm.unmarshalValue(raw)
+		if err != nil {
+			return nil, "", err
+		}
+		result[key] = value
+	}
+	return result, page.Next, nil
+}
+
+// Aggregate computes the $aggregate operators of the query over the matching
+// entries. The partial results are computed by the partition owners and merged
+// on the client.
+func (c *Cursor) Aggregate() (*query.Aggregation, error) {
+	defer c.Close()
+
+	if c.aggregateQuery == nil {
+		return nil, fmt.Errorf("%w: no aggregation operator", query.ErrInvalidQuery)
+	}
+
+	var wg sync.WaitGroup
+	var errs error
+	result := &query.Aggregation{}
+
+	sem := semaphore.NewWeighted(olric.NumConcurrentWorkers)
+	for partID := uint64(0); ; partID++ {
+		if err := sem.Acquire(c.ctx, 1); err != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(id uint64) {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			value, err := c.requestPartition(protocol.OpQuery, id, c.aggregateQuery)
+			if errors.Is(err, olric.ErrEndOfQuery) {
+				c.Close()
+				return
+			}
+			var aggregation query.Aggregation
+			if err == nil {
+				err = msgpack.Unmarshal(value, &aggregation)
+			}
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				c.Close()
+				return
+			}
+			result.Merge(&aggregation)
+		}(partID)
+	}
+	wg.Wait()
+
+	if c.parent.Err() != nil {
+		// The caller has given up, the result is incomplete.
+		return nil, c.parent.Err()
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	if c.limit > 0 && result.Count > int64(c.limit) {
+		result.Count = int64(c.limit)
+	}
+	return result, nil
 }
 
 // Query runs a distributed query on a dmap instance.
-// Olric supports a very simple query DSL and now, it only scans keys. The query DSL has very
-// few keywords:
+// Olric supports a very simple query DSL to filter the entries by their keys and values.
+// The query DSL has very few keywords:
 //
 // $onKey: Runs the given query on keys or manages options on keys for a given query.
 //
@@ -191,22 +358,108 @@ func (c *Cursor) Range(f func(key string, value interface{}) bool) error {
 // 	  },
 //   }
 //
-// Query function returns a cursor which has Range and Close methods. Please take look at the Range
-// function for further info.
+// Keywords for $onValue:
+//
+// $eq, $gt, $lt: Compares the value with the given one. Numbers are compared by value and
+// strings lexicographically.
+//
+// $in: Checks that the value is equal to one of the given values.
+//
+// $exists: Checks that the value or the field exists.
+//
+// The other keys in $onValue are field paths into the decoded maps, the elements are
+// separated by dots. The values are decoded by the configured serializer on the partition
+// owners:
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^user:",
+// 	  },
+// 	  "$onValue": query.M{
+// 		  "address.city": query.M{"$eq": "Istanbul"},
+// 		  "age":          query.M{"$gt": 30},
+// 	  },
+//   }
+//
+// $onKey can be omitted to run the predicates on all the keys.
+//
+// $aggregate: Computes the given aggregation operators over the matching entries. Call
+// Cursor.Aggregate to run it. The operators are computed on the partition owners, so the
+// values are not sent to the caller.
+//
+// Keywords for $aggregate:
+//
+// $count: Counts the matching entries.
+//
+// $sum, $min, $max, $avg: Computes the sum, the minimum, the maximum and the average of
+// the numeric values. The other values are ignored.
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^even:",
+// 	  },
+// 	  "$aggregate": query.M{
+// 		  "$count": true,
+// 		  "$sum":   true,
+// 	  },
+//   }
+//
+// $limit: Stops the query after the given number of entries. The partition owners stop
+// scanning after $limit matching entries. It can be used with the $count operator to count
+// up to a limit, the other aggregation operators don't support it.
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^even:",
+// 	  },
+// 	  "$limit": 10,
+//   }
+//
+// Query function returns a cursor which has Range, RangeEntries, Page, Aggregate and Close
+// methods. Please take look at the Range function for further info.
 func (d *DMap) Query(q query.M) (*Cursor, error) {
+	return d.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query but the given context cancels the requests of the
+// cursor. Range returns the error of the context, if it's done.
+func (d *DMap) QueryContext(parent context.Context, q query.M) (*Cursor, error) {
 	if err := query.Validate(q); err != nil {
 		return nil, err
 	}
+	var aggregateQuery []byte
+	if aggregates := query.Aggregates(q); aggregates != nil {
+		if query.Limit(q) > 0 && query.NeedsValues(aggregates) {
+			return nil, fmt.Errorf("%w: $limit can only be used with $count", query.ErrInvalidQuery)
+		}
+		aq, err := msgpack.Marshal(q)
+		if err != nil {
+			return nil, err
+		}
+		aggregateQuery = aq
+
+		// Range iterates over the matching entries.
+		tmp := make(query.M)
+		for keyword, value := range q {
+			if keyword != "$aggregate" {
+				tmp[keyword] = value
+			}
+		}
+		q = tmp
+	}
 	qr, err := msgpack.Marshal(q)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	return &Cursor{
-		dm:     d,
-		query:  qr,
-		ctx:    ctx,
-		cancel: cancel,
+		dm:             d,
+		query:          qr,
+		aggregateQuery: aggregateQuery,
+		limit:          query.Limit(q),
+		parent:         parent,
+		ctx:            ctx,
+		cancel:         cancel,
 	}, nil
 }
@@ -15,10 +15,13 @@
 package client
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/buraksezer/olric"
 	"github.com/buraksezer/olric/internal/testolric"
 	"github.com/buraksezer/olric/query"
 )
@@ -64,3 +67,297 @@ func TestClient_Query(t *testing.T) {
 		t.Fatalf("Expected nil. Got: %v", err)
 	}
 }
+
+func TestClient_QueryAggregate(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	var key string
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			key = "even:" + strconv.Itoa(i)
+		} else {
+			key = "odd:" + strconv.Itoa(i)
+		}
+		err = dm.Put(key, i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	q, err := dm.Query(query.M{
+		"$onKey":     query.M{"$regexMatch": "even:"},
+		"$aggregate": query.M{"$count": true, "$sum": true, "$max": true},
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	result, err := q.Aggregate()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if result.Count != 50 {
+		t.Fatalf("Expected count is 50. Got: %d", result.Count)
+	}
+	if result.Sum != 2450 {
+		t.Fatalf("Expected sum is 2450. Got: %v", result.Sum)
+	}
+	if result.Max != 98 {
+		t.Fatalf("Expected max is 98. Got: %v", result.Max)
+	}
+}
+
+func TestClient_QueryOnValue(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	for i := 0; i < 100; i++ {
+		err = dm.Put(strconv.Itoa(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	q, err := dm.Query(query.M{"$onValue": query.M{"$in": []int{3, 5, 7, 1000}}})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	count := 0
+	err = q.Range(func(key string, value interface{}) bool {
+		count++
+		if key != strconv.Itoa(value.(int)) {
+			t.Fatalf("Unexpected key/value pair: %s/%v", key, value)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected count is 3. Got: %d", count)
+	}
+}
+
+func TestClient_QueryOnValue_Predicates(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	for i := 0; i < 100; i++ {
+		err = dm.Put(strconv.Itoa(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	predicates := []struct {
+		name     string
+		onValue  query.M
+		expected int
+	}{
+		{"eq", query.M{"$eq": 50}, 1},
+		{"gt", query.M{"$gt": 89}, 10},
+		{"lt", query.M{"$lt": 10}, 10},
+		{"range", query.M{"$gt": 10, "$lt": 20}, 9},
+		{"in", query.M{"$in": []int{3, 5, 7, 1000}}, 3},
+		{"no match", query.M{"$gt": 1000}, 0},
+	}
+	for _, p := range predicates {
+		q, err := dm.Query(query.M{"$onValue": p.onValue})
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		count := 0
+		err = q.Range(func(key string, value interface{}) bool {
+			count++
+			return true
+		})
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if count != p.expected {
+			t.Fatalf("%s: expected count is %d. Got: %d", p.name, p.expected, count)
+		}
+	}
+}
+
+func TestClient_QueryPage(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	for i := 0; i < 100; i++ {
+		err = dm.Put(strconv.Itoa(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	keys := make(map[string]struct{})
+	var token string
+	for {
+		q, err := dm.Query(query.M{"$onKey": query.M{"$regexMatch": ""}})
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		page, next, err := q.Page(token, 7)
+		q.Close()
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if len(page) > 7 {
+			t.Fatalf("Expected at most 7 entries. Got: %d", len(page))
+		}
+		for key, value := range page {
+			if key != strconv.Itoa(value.(int)) {
+				t.Fatalf("Unexpected key/value pair: %s/%v", key, value)
+			}
+			keys[key] = struct{}{}
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if len(keys) != 100 {
+		t.Fatalf("Expected key count is 100. Got: %d", len(keys))
+	}
+
+	q, err := dm.Query(query.M{"$onKey": query.M{"$regexMatch": ""}})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	_, _, err = q.Page("foobar", 7)
+	if !errors.Is(err, olric.ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument. Got: %v", err)
+	}
+}
+
+func TestClient_QueryRangeEntries_Limit(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	for i := 0; i < 100; i++ {
+		err = dm.PutEx(strconv.Itoa(i), i, time.Hour)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	q, err := dm.Query(query.M{"$onKey": query.M{"$regexMatch": ""}, "$limit": 10})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	count := 0
+	err = q.RangeEntries(func(e *olric.Entry) bool {
+		count++
+		if e.Key != strconv.Itoa(e.Value.(int)) {
+			t.Fatalf("Unexpected key/value pair: %s/%v", e.Key, e.Value)
+		}
+		if e.TTL == 0 || e.Timestamp == 0 || e.LastAccess == 0 {
+			t.Fatalf("Expected metadata is set. Got: %+v", e)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 10 {
+		t.Fatalf("Expected count is 10. Got: %d", count)
+	}
+}
+
+func TestClient_DeleteByQuery(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm := c.NewDMap("mymap")
+	for i := 0; i < 100; i++ {
+		err = dm.Put(strconv.Itoa(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	count, err := dm.ExpireByQuery(query.M{"$onValue": query.M{"$lt": 10}}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 10 {
+		t.Fatalf("Expected count is 10. Got: %d", count)
+	}
+
+	count, err = dm.DeleteByQuery(query.M{"$onValue": query.M{"$gt": 49}})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 50 {
+		t.Fatalf("Expected count is 50. Got: %d", count)
+	}
+
+	<-time.After(10 * time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Get(strconv.Itoa(i))
+		if i < 10 || i > 49 {
+			if err != olric.ErrKeyNotFound {
+				t.Fatalf("Expected olric.ErrKeyNotFound. Got: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+}
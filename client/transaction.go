@@ -0,0 +1,173 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/buraksezer/olric"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack"
+)
+
+// Transaction implements optimistic transactions on a DMap. It watches a set
+// of keys, queues the commands and runs them atomically on the partition owner
+// if none of the watched keys has been modified in the meantime. All keys of a
+// transaction have to be in the same partition, use hash tags to co-locate them.
+// All methods are thread-safe.
+type Transaction struct {
+	c        *Client
+	m        sync.Mutex
+	dmap     string
+	watches  []protocol.TxWatch
+	commands []protocol.TxCommand
+}
+
+// NewTransaction returns a new Transaction on the given DMap.
+func (c *Client) NewTransaction(dmap string) *Transaction {
+	return &Transaction{
+		c:    c,
+		dmap: dmap,
+	}
+}
+
+// Watch marks the given key to be watched for the conditional execution of the
+// transaction. Exec fails with olric.ErrTransactionConflict if the key has been
+// modified after Watch returns.
+func (tx *Transaction) Watch(key string) error {
+	return tx.WatchContext(context.Background(), key)
+}
+
+// WatchContext is like Watch but the given context cancels the network operations.
+func (tx *Transaction) WatchContext(ctx context.Context, key string) error {
+	var version int64
+	entry, err := tx.c.NewDMap(tx.dmap).GetEntryContext(ctx, key)
+	if err != nil && !errors.Is(err, olric.ErrKeyNotFound) {
+		return err
+	}
+	if err == nil {
+		version = entry.Version
+	}
+
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	tx.watches = append(tx.watches, protocol.TxWatch{Key: key, Version: version})
+	return nil
+}
+
+func (tx *Transaction) appendCommand(op protocol.OpCode, key string, value interface{}) error {
+	cmd := protocol.TxCommand{Op: op, Key: key}
+	if value != nil {
+		data, err := tx.c.serializer.Marshal(value)
+		if err != nil {
+			return err
+		}
+		cmd.Value = data
+	}
+
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	tx.commands = append(tx.commands, cmd)
+	return nil
+}
+
+// Put queues a Put command with the given parameters.
+func (tx *Transaction) Put(key string, value interface{}) error {
+	if value == nil {
+		value = struct{}{}
+	}
+	return tx.appendCommand(protocol.OpPut, key, value)
+}
+
+// Delete queues a Delete command for the given key.
+func (tx *Transaction) Delete(key string) error {
+	return tx.appendCommand(protocol.OpDelete, key, nil)
+}
+
+// Incr queues an Incr command with the given parameters.
+func (tx *Transaction) Incr(key string, delta int) error {
+	return tx.appendCommand(protocol.OpIncr, key, delta)
+}
+
+// Exec runs the queued commands atomically. It returns the results in the order
+// of the commands. The result of an Incr command is the new value, it's nil for
+// the others. Exec returns olric.ErrTransactionConflict if one of the watched keys
+// has been modified, none of the commands is run in that case. The watched keys
+// and the queued commands are discarded after Exec returns.
+func (tx *Transaction) Exec() ([]interface{}, error) {
+	return tx.ExecContext(context.Background())
+}
+
+// ExecContext is like Exec but the given context cancels the network operations.
+func (tx *Transaction) ExecContext(ctx context.Context) ([]interface{}, error) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	defer func() {
+		tx.watches = nil
+		tx.commands = nil
+	}()
+
+	value, err := msgpack.Marshal(protocol.Transaction{
+		Watches:  tx.watches,
+		Commands: tx.commands,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req := protocol.NewDMapMessage(protocol.OpExec)
+	req.SetDMap(tx.dmap)
+	req.SetValue(value)
+	resp, err := tx.c.requestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatusCode(resp); err != nil {
+		return nil, err
+	}
+
+	var results []protocol.BatchResult
+	if err = msgpack.Unmarshal(resp.Value(), &results); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(tx.commands))
+	for i, cmd := range tx.commands {
+		if cmd.Op != protocol.OpIncr || i >= len(results) {
+			continue
+		}
+		raw, err := tx.c.unmarshalValue(results[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		if values[i], err = valueToInt(raw); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Discard drops the watched keys and the queued commands.
+func (tx *Transaction) Discard() {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+
+	tx.watches = nil
+	tx.commands = nil
+}
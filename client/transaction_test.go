@@ -0,0 +1,83 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric"
+	"github.com/buraksezer/olric/internal/testolric"
+)
+
+func TestTransaction_Exec(t *testing.T) {
+	srv, err := testolric.New(t)
+	if err != nil {
+		t.Fatalf("Expected nil. Got %v", err)
+	}
+	tc := newTestConfig(srv)
+
+	c, err := New(tc)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dmap := "mydmap"
+	key := "counter"
+	tx := c.NewTransaction(dmap)
+	if err = tx.Watch(key); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = tx.Incr(key, 10); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = tx.Incr(key, 5); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	results, err := tx.Exec()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected length of results is 2. Got: %d", len(results))
+	}
+	if results[1].(int) != 15 {
+		t.Fatalf("Expected 15. Got: %v", results[1])
+	}
+
+	if err = tx.Watch(key); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = tx.Delete(key); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	// Modify the watched key before committing the transaction.
+	dm := c.NewDMap(dmap)
+	if err = dm.Put(key, 100); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	_, err = tx.Exec()
+	if err != olric.ErrTransactionConflict {
+		t.Fatalf("Expected olric.ErrTransactionConflict. Got: %v", err)
+	}
+
+	val, err := dm.Get(key)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if val.(int) != 100 {
+		t.Fatalf("Expected 100. Got: %v", val)
+	}
+}
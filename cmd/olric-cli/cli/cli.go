@@ -50,6 +50,10 @@ var completer = readline.NewPrefixCompleter(
 	readline.PcItem("decr"),
 	readline.PcItem("expire"),
 	readline.PcItem("getentry"),
+	readline.PcItem("len"),
+	readline.PcItem("ttl"),
+	readline.PcItem("persist"),
+	readline.PcItem("getex"),
 )
 
 // CLI defines the command line client for Olric.
@@ -38,6 +38,10 @@ const (
 	cmdDecr     string = "decr"
 	cmdGetPut   string = "getput"
 	cmdGetEntry string = "getentry"
+	cmdLen      string = "len"
+	cmdTTL      string = "ttl"
+	cmdPersist  string = "persist"
+	cmdGetEx    string = "getex"
 )
 
 func (c *CLI) evalGetEntry(dm *client.DMap, fields []string) error {
@@ -159,6 +163,53 @@ func (c *CLI) evalExpire(dm *client.DMap, fields []string) error {
 	return dm.Expire(key, ttl)
 }
 
+func (c *CLI) evalLen(dm *client.DMap) error {
+	count, err := dm.Len()
+	if err != nil {
+		return err
+	}
+	c.print(fmt.Sprintf("%d\n", count))
+	return nil
+}
+
+func (c *CLI) evalTTL(dm *client.DMap, fields []string) error {
+	key := strings.Join(fields, " ")
+	ttl, err := dm.TTL(key)
+	if err != nil {
+		return err
+	}
+	if ttl == 0 {
+		c.print("no expiry\n")
+		return nil
+	}
+	c.print(fmt.Sprintf("%v\n", ttl))
+	return nil
+}
+
+func (c *CLI) evalPersist(dm *client.DMap, fields []string) error {
+	key := strings.Join(fields, " ")
+	return dm.Persist(key)
+}
+
+func (c *CLI) evalGetEx(dm *client.DMap, fields []string) error {
+	if len(fields) <= 1 {
+		return errInvalidCommand
+	}
+
+	ttlRaw := fields[len(fields)-1]
+	ttl, err := time.ParseDuration(ttlRaw)
+	if err != nil {
+		return err
+	}
+	key := strings.Join(fields[:len(fields)-1], " ")
+	value, err := dm.GetEx(key, ttl)
+	if err != nil {
+		return err
+	}
+	c.print(fmt.Sprintf("%v\n", value))
+	return nil
+}
+
 func (c *CLI) evalGetPut(dm *client.DMap, fields []string) error {
 	if len(fields) <= 1 {
 		return errInvalidCommand
@@ -223,7 +274,7 @@ func (c *CLI) evaluate(dmap, line string) error {
 	line = strings.TrimSpace(line)
 	fields := strings.Fields(line)
 	cmd, fields := fields[0], fields[1:]
-	if len(fields) < 1 {
+	if len(fields) < 1 && cmd != cmdLen {
 		return errInvalidCommand
 	}
 
@@ -253,6 +304,14 @@ func (c *CLI) evaluate(dmap, line string) error {
 		return c.evalPutIfEx(dm, fields)
 	case cmd == cmdGetEntry:
 		return c.evalGetEntry(dm, fields)
+	case cmd == cmdLen:
+		return c.evalLen(dm)
+	case cmd == cmdTTL:
+		return c.evalTTL(dm, fields)
+	case cmd == cmdPersist:
+		return c.evalPersist(dm, fields)
+	case cmd == cmdGetEx:
+		return c.evalGetEx(dm, fields)
 	default:
 		return fmt.Errorf("invalid command")
 	}
@@ -289,4 +289,43 @@ func TestOlric_CLI_Evaluate(t *testing.T) {
 			t.Fatalf("Expected nil, Got: %v", err)
 		}
 	})
+
+	t.Run("run evalGetEx", func(t *testing.T) {
+		_ = dm.Put("evalGetEx-test", "evalGetEx-test")
+		fields := []string{
+			"evalGetEx-test",
+			"1h",
+		}
+		err := c.evalGetEx(dm, fields)
+		if err != nil {
+			t.Fatalf("Expected nil, Got: %v", err)
+		}
+	})
+
+	t.Run("run evalTTL", func(t *testing.T) {
+		fields := []string{
+			"evalGetEx-test",
+		}
+		err := c.evalTTL(dm, fields)
+		if err != nil {
+			t.Fatalf("Expected nil, Got: %v", err)
+		}
+	})
+
+	t.Run("run evalPersist", func(t *testing.T) {
+		fields := []string{
+			"evalGetEx-test",
+		}
+		err := c.evalPersist(dm, fields)
+		if err != nil {
+			t.Fatalf("Expected nil, Got: %v", err)
+		}
+	})
+
+	t.Run("run evalLen", func(t *testing.T) {
+		err := c.evaluate("my-dmap", "len")
+		if err != nil {
+			t.Fatalf("Expected nil, Got: %v", err)
+		}
+	})
 }
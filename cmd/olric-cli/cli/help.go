@@ -105,6 +105,32 @@ func (c *CLI) helpGetEntry() {
 	c.print("GetEntry gets the value for the given key. It returns ErrKeyNotFound if the DB does not contains the key. It's thread-safe.\n")
 }
 
+func (c *CLI) helpLen() {
+	c.print(fmt.Sprintf("%s\n\n", Colorize("* Len", Green)))
+	c.print(fmt.Sprintf("%s len\n\n", Colorize(">>", Red)))
+	c.print("Len returns the number of the keys in the DMap. Expired keys and the backups are not counted.\n")
+}
+
+func (c *CLI) helpTTL() {
+	c.print(fmt.Sprintf("%s\n\n", Colorize("* TTL", Green)))
+	c.print(fmt.Sprintf("%s ttl <key>\n\n", Colorize(">>", Red)))
+	c.print("TTL returns the remaining time to live of the given key. It returns 'key not found' if the cluster does not contains the key.\n")
+}
+
+func (c *CLI) helpPersist() {
+	c.print(fmt.Sprintf("%s\n\n", Colorize("* Persist", Green)))
+	c.print(fmt.Sprintf("%s persist <key>\n\n", Colorize(">>", Red)))
+	c.print("Persist removes the expiry of the given key. It returns 'key not found' if the cluster does not contains the key.\n")
+}
+
+func (c *CLI) helpGetEx() {
+	c.print(fmt.Sprintf("%s\n\n", Colorize("* GetEx", Green)))
+	c.print(fmt.Sprintf("%s getex <key> <ttl>\n\n", Colorize(">>", Red)))
+	c.print("GetEx gets the value for the given key and updates its expiry. It returns 'key not found' if the cluster does not contains the key.\n\n")
+	c.print("\"ttl\" is a duration string. A duration string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as \"300ms\", \"-1.5h\" or \"2h45m\".\n")
+	c.print("Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\".\n")
+}
+
 func (c *CLI) help(cmd string) error {
 	var commands = map[string]func(){
 		"put":      c.helpPut,
@@ -119,6 +145,10 @@ func (c *CLI) help(cmd string) error {
 		"decr":     c.helpDecr,
 		"getput":   c.helpGetPut,
 		"getentry": c.helpGetEntry,
+		"len":      c.helpLen,
+		"ttl":      c.helpTTL,
+		"persist":  c.helpPersist,
+		"getex":    c.helpGetEx,
 	}
 
 	if cmd != "" {
@@ -125,14 +125,50 @@ const (
 	// in approximate LRU implementation. It's 5.
 	DefaultLRUSamples int = 5
 
+	// DefaultWriteBatchSize is the default maximum number of keys that are flushed
+	// to a MapStore at once in write-behind mode.
+	DefaultWriteBatchSize = 1000
+
 	// LRUEviction assigns this as EvictionPolicy in order to enable LRU eviction
 	// algorithm.
 	LRUEviction EvictionPolicy = "LRU"
 
+	// LFUEviction assigns this as EvictionPolicy in order to enable LFU eviction
+	// algorithm.
+	LFUEviction EvictionPolicy = "LFU"
+
+	// VolatileTTLEviction assigns this as MaxMemoryPolicy in order to evict the
+	// keys with the nearest expiry first. Only the keys with an expiry are evicted.
+	VolatileTTLEviction EvictionPolicy = "volatile-ttl"
+
+	// VolatileLRUEviction assigns this as MaxMemoryPolicy in order to evict the
+	// least recently used keys. Only the keys with an expiry are evicted.
+	VolatileLRUEviction EvictionPolicy = "volatile-lru"
+
+	// VolatileRandomEviction assigns this as MaxMemoryPolicy in order to evict
+	// random keys. Only the keys with an expiry are evicted.
+	VolatileRandomEviction EvictionPolicy = "volatile-random"
+
+	// AllKeysLRUEviction assigns this as MaxMemoryPolicy in order to evict the
+	// least recently used keys.
+	AllKeysLRUEviction EvictionPolicy = "allkeys-lru"
+
+	// AllKeysRandomEviction assigns this as MaxMemoryPolicy in order to evict
+	// random keys.
+	AllKeysRandomEviction EvictionPolicy = "allkeys-random"
+
+	// DefaultLFUDecayTime is a sane default for the period to decrement the access
+	// counter of an idle key in LFU implementation. It's one minute.
+	DefaultLFUDecayTime = time.Minute
+
 	// DefaultStorageEngine denotes the storage engine implementation provided by
 	// Olric project.
 	DefaultStorageEngine = "kvstore"
 
+	// DiskStorageEngine denotes the persistent storage engine implementation provided
+	// by Olric project. It requires dataDir in the engine configuration.
+	DiskStorageEngine = "diskstore"
+
 	// DefaultRoutingTablePushInterval is interval between routing table push events.
 	DefaultRoutingTablePushInterval = time.Minute
 
@@ -147,6 +183,10 @@ const (
 	// two sequential call of compaction workers. The compaction worker works until
 	// its work is done. It's 10 minutes by default.
 	DefaultTriggerCompactionInterval = 10 * time.Minute
+
+	// DefaultTombstoneGracePeriod is the default value of the period to keep the
+	// tombstones of deleted keys. It's one hour by default.
+	DefaultTombstoneGracePeriod = time.Hour
 )
 
 // Config is the configuration to create a Olric instance.
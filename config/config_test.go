@@ -85,14 +85,19 @@ dmaps:
   maxKeys: 100000
   maxInuse: 1000000
   lruSamples: 10
+  lfuDecayTime: "30s"
   evictionPolicy: "LRU"
+  maxMemory: 1073741824
+  maxMemoryPolicy: "volatile-ttl"
   custom:
     foobar:
       maxIdleDuration: "60s"
       ttlDuration: "300s"
       maxKeys: 500000
       lruSamples: 20
+      lfuDecayTime: "10s"
       evictionPolicy: "NONE"
+      hashTags: true
 
 serviceDiscovery:
   path: "/usr/lib/olric-consul-plugin.so"
@@ -178,6 +183,9 @@ func TestConfig(t *testing.T) {
 	c.DMaps.MaxKeys = 100000
 	c.DMaps.MaxInuse = 1000000
 	c.DMaps.LRUSamples = 10
+	c.DMaps.LFUDecayTime = 30 * time.Second
+	c.DMaps.MaxMemory = 1073741824
+	c.DMaps.MaxMemoryPolicy = VolatileTTLEviction
 	c.DMaps.EvictionPolicy = LRUEviction
 	c.DMaps.Engine.Name = DefaultStorageEngine
 
@@ -186,7 +194,9 @@ func TestConfig(t *testing.T) {
 		TTLDuration:     300 * time.Second,
 		MaxKeys:         500000,
 		LRUSamples:      20,
+		LFUDecayTime:    10 * time.Second,
 		EvictionPolicy:  "NONE",
+		HashTags:        true,
 	}}
 
 	c.ServiceDiscovery = make(map[string]interface{})
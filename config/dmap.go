@@ -15,13 +15,45 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
-// EvictionPolicy denotes eviction policy. Currently: LRU or NONE.
+// EvictionPolicy denotes eviction policy. Currently: LRU, LFU or NONE.
 type EvictionPolicy string
 
+// MapLoader loads the missing keys of a DMap from a backing store, such as a
+// database. It's called by the partition owner on a Get miss.
+type MapLoader interface {
+	// Load returns the value of the given key. It returns a nil value and a nil
+	// error if the key doesn't exist in the backing store.
+	Load(ctx context.Context, key string) (interface{}, error)
+}
+
+// MapStore persists the writes and deletes on a DMap to a backing store. It's
+// called by the partition owner before acknowledging the operation.
+type MapStore interface {
+	// Store persists the given key/value pair.
+	Store(ctx context.Context, key string, value interface{}) error
+
+	// Delete deletes the given key from the backing store.
+	Delete(ctx context.Context, key string) error
+}
+
+// BatchMapStore is a MapStore that persists the batches of write-behind mode at
+// once. Store and Delete are called for every key if the MapStore doesn't
+// implement BatchMapStore.
+type BatchMapStore interface {
+	MapStore
+
+	// StoreAll persists the given key/value pairs.
+	StoreAll(ctx context.Context, entries map[string]interface{}) error
+
+	// DeleteAll deletes the given keys from the backing store.
+	DeleteAll(ctx context.Context, keys []string) error
+}
+
 // Important note on DMap and DMaps structs:
 // Golang does not provide the typical notion of inheritance.
 // because of that I preferred to define the types explicitly.
@@ -54,13 +86,57 @@ type DMap struct {
 	MaxInuse int
 
 	// LRUSamples denotes amount of randomly selected key count by the approximate
-	// LRU implementation. Lower values are better for high performance. It's 5
-	// by default.
+	// LRU and LFU implementations. Lower values are better for high performance.
+	// It's 5 by default.
 	LRUSamples int
 
+	// LFUDecayTime denotes the period to decrement the access counter of an idle
+	// key by one in LFU implementation. It's one minute by default.
+	LFUDecayTime time.Duration
+
 	// EvictionPolicy determines the eviction policy in use. It's NONE by default.
-	// Set as LRU to enable LRU eviction policy.
+	// Set as LRU or LFU to enable LRU or LFU eviction policy.
 	EvictionPolicy EvictionPolicy
+
+	// HashTags enables Redis-style hash tags. If a key contains a non-empty
+	// substring between the first "{" and the following "}", only that substring
+	// is used to find the partition of the key. So "user:{42}:profile" and
+	// "user:{42}:cart" are stored on the same partition.
+	HashTags bool
+
+	// KeyspaceNotifications enables the keyspace notifications. The partition
+	// owner publishes an event to the DTopic named "__keyspace__:<dmap name>"
+	// whenever a key is put, deleted, expired or evicted. An event is a map with
+	// "key", "op", "timestamp" and "value" keys. "op" is one of "put", "delete",
+	// "expire" and "evict", "value" is only set for "put". The notifications are
+	// delivered on a best-effort basis.
+	KeyspaceNotifications bool
+
+	// NearCache enables the near cache on the members. A member caches the
+	// entries that are owned by the other members after a Get call. The
+	// partition owners publish invalidations like KeyspaceNotifications. The
+	// cached entries are dropped when the ownership of their partition changes.
+	NearCache *NearCache
+
+	// Loader loads the missing keys from a backing store. The loaded values are
+	// stored with TTLDuration. Concurrent Get calls for the same missing key
+	// share a single Load call.
+	Loader MapLoader
+
+	// Store persists the writes and deletes to a backing store. A write or a
+	// delete fails if Store fails, the DMap is not modified in that case. See
+	// WriteDelay for the asynchronous mode.
+	Store MapStore
+
+	// WriteDelay enables write-behind mode if it's greater than zero. The
+	// partition owner queues the writes and deletes, and flushes them to Store
+	// at this interval. The repeated writes on a key are coalesced. Failed
+	// batches are retried with exponential backoff.
+	WriteDelay time.Duration
+
+	// WriteBatchSize denotes maximum number of keys that are flushed to Store
+	// at once in write-behind mode. It's 1000 by default.
+	WriteBatchSize int
 }
 
 // Sanitize sets default values to empty configuration variables, if it's possible.
@@ -71,6 +147,9 @@ func (dm *DMap) Sanitize() error {
 	if dm.LRUSamples <= 0 {
 		dm.LRUSamples = DefaultLRUSamples
 	}
+	if dm.LFUDecayTime <= 0 {
+		dm.LFUDecayTime = DefaultLFUDecayTime
+	}
 	if dm.MaxInuse < 0 {
 		dm.MaxInuse = 0
 	}
@@ -90,6 +169,12 @@ func (dm *DMap) Sanitize() error {
 		return fmt.Errorf("failed to sanitize storage engine configuration: %w", err)
 	}
 
+	if dm.NearCache != nil {
+		if err := dm.NearCache.Sanitize(); err != nil {
+			return fmt.Errorf("failed to sanitize near cache configuration: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -99,6 +184,12 @@ func (dm *DMap) Validate() error {
 		return fmt.Errorf("failed to validate storage engine configuration: %w", err)
 	}
 
+	if dm.NearCache != nil {
+		if err := dm.NearCache.Validate(); err != nil {
+			return fmt.Errorf("failed to validate near cache configuration: %w", err)
+		}
+	}
+
 	return nil
 }
 
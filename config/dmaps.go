@@ -55,14 +55,32 @@ type DMaps struct {
 	MaxInuse int
 
 	// LRUSamples denotes amount of randomly selected key count by the approximate
-	// LRU implementation. Lower values are better for high performance. It's
-	// 5 by default.
+	// LRU and LFU implementations. Lower values are better for high performance.
+	// It's 5 by default.
 	LRUSamples int
 
+	// LFUDecayTime denotes the period to decrement the access counter of an idle
+	// key by one in LFU implementation. It's one minute by default.
+	LFUDecayTime time.Duration
+
 	// EvictionPolicy determines the eviction policy in use. It's NONE by default.
-	// Set as LRU to enable LRU eviction policy.
+	// Set as LRU or LFU to enable LRU or LFU eviction policy.
 	EvictionPolicy EvictionPolicy
 
+	// MaxMemory denotes the memory budget of all the DMaps on a node, in bytes.
+	// The in-use memory of the primary fragments is counted, the backups and the
+	// DMaps on the disk storage engine are not. If it's exceeded, the keys are
+	// evicted by MaxMemoryPolicy, even if the limits of the DMaps are not reached.
+	// It's disabled if it's zero.
+	MaxMemory int
+
+	// MaxMemoryPolicy determines the keys to evict if MaxMemory is exceeded. It's
+	// one of volatile-ttl, volatile-lru, volatile-random, allkeys-lru and
+	// allkeys-random. It's NONE by default, the writes fail with ErrOutOfMemory
+	// if MaxMemory is exceeded. The writes also fail if there is no key to evict,
+	// for example, no key has an expiry with a volatile policy.
+	MaxMemoryPolicy EvictionPolicy
+
 	// CheckEmptyFragmentsInterval is interval between two sequential call of empty
 	// fragment cleaner.
 	CheckEmptyFragmentsInterval time.Duration
@@ -70,6 +88,26 @@ type DMaps struct {
 	// TriggerCompactionInterval is interval between two sequential call of compaction worker.
 	TriggerCompactionInterval time.Duration
 
+	// TombstoneGracePeriod is the period to keep the tombstones of deleted keys.
+	// A tombstone prevents a stale replica or a previous owner from resurrecting
+	// a deleted key. The janitor removes the tombstones after this period.
+	TombstoneGracePeriod time.Duration
+
+	// SnapshotDir is the directory to dump DMap fragments on graceful shutdown.
+	// The fragments are loaded again before the node bootstraps. Snapshots are
+	// disabled if it's empty. Every node should have its own directory.
+	SnapshotDir string
+
+	// HashTags enables hash tags for all DMaps. See DMap.HashTags for details.
+	HashTags bool
+
+	// KeyspaceNotifications enables the keyspace notifications for all DMaps.
+	// See DMap.KeyspaceNotifications for details.
+	KeyspaceNotifications bool
+
+	// NearCache enables the near cache for all DMaps. See DMap.NearCache for details.
+	NearCache *NearCache
+
 	// Custom is useful to set custom cache config per DMap instance.
 	Custom map[string]DMap
 }
@@ -92,6 +130,18 @@ func (dm *DMaps) Sanitize() error {
 		dm.LRUSamples = DefaultLRUSamples
 	}
 
+	if dm.LFUDecayTime <= 0 {
+		dm.LFUDecayTime = DefaultLFUDecayTime
+	}
+
+	if dm.MaxMemory < 0 {
+		dm.MaxMemory = 0
+	}
+
+	if dm.MaxMemoryPolicy == "" {
+		dm.MaxMemoryPolicy = "NONE"
+	}
+
 	if dm.MaxInuse < 0 {
 		dm.MaxInuse = 0
 	}
@@ -112,6 +162,10 @@ func (dm *DMaps) Sanitize() error {
 		dm.TriggerCompactionInterval = DefaultTriggerCompactionInterval
 	}
 
+	if dm.TombstoneGracePeriod.Microseconds() == 0 {
+		dm.TombstoneGracePeriod = DefaultTombstoneGracePeriod
+	}
+
 	for _, d := range dm.Custom {
 		if err := d.Sanitize(); err != nil {
 			return err
@@ -126,6 +180,12 @@ func (dm *DMaps) Sanitize() error {
 		return fmt.Errorf("failed to sanitize storage engine configuration: %w", err)
 	}
 
+	if dm.NearCache != nil {
+		if err := dm.NearCache.Sanitize(); err != nil {
+			return fmt.Errorf("failed to sanitize near cache configuration: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -133,6 +193,17 @@ func (dm *DMaps) Validate() error {
 	if err := dm.Engine.Validate(); err != nil {
 		return fmt.Errorf("failed to validate storage engine configuration: %w", err)
 	}
+	switch dm.MaxMemoryPolicy {
+	case "", "NONE", VolatileTTLEviction, VolatileLRUEviction, VolatileRandomEviction,
+		AllKeysLRUEviction, AllKeysRandomEviction:
+	default:
+		return fmt.Errorf("invalid MaxMemoryPolicy: %s", dm.MaxMemoryPolicy)
+	}
+	if dm.NearCache != nil {
+		if err := dm.NearCache.Validate(); err != nil {
+			return fmt.Errorf("failed to validate near cache configuration: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/buraksezer/olric/internal/diskstore"
 	"github.com/buraksezer/olric/internal/kvstore"
 	"github.com/buraksezer/olric/pkg/storage"
 )
@@ -90,6 +91,13 @@ func (s *Engine) Sanitize() error {
 		case DefaultStorageEngine:
 			s.Implementation = &kvstore.KVStore{}
 			s.Config = kvstore.DefaultConfig().ToMap()
+		case DiskStorageEngine:
+			s.Implementation = &diskstore.DiskStore{}
+			c := diskstore.DefaultConfig()
+			for key, value := range s.Config {
+				c.Add(key, value)
+			}
+			s.Config = c.ToMap()
 		default:
 			return fmt.Errorf("unknown storage engine: %s", s.Name)
 		}
@@ -90,14 +90,25 @@ type engine struct {
 	Config map[string]interface{} `yaml:"config"`
 }
 
+type nearCache struct {
+	MaxKeys        int    `yaml:"maxKeys"`
+	MaxInuse       int    `yaml:"maxInuse"`
+	TTL            string `yaml:"ttl"`
+	EvictionPolicy string `yaml:"evictionPolicy"`
+}
+
 type dmap struct {
-	Engine          *engine `yaml:"engine"`
-	MaxIdleDuration string  `yaml:"maxIdleDuration"`
-	TTLDuration     string  `yaml:"ttlDuration"`
-	MaxKeys         int     `yaml:"maxKeys"`
-	MaxInuse        int     `yaml:"maxInuse"`
-	LRUSamples      int     `yaml:"lruSamples"`
-	EvictionPolicy  string  `yaml:"evictionPolicy"`
+	Engine                *engine    `yaml:"engine"`
+	MaxIdleDuration       string     `yaml:"maxIdleDuration"`
+	TTLDuration           string     `yaml:"ttlDuration"`
+	MaxKeys               int        `yaml:"maxKeys"`
+	MaxInuse              int        `yaml:"maxInuse"`
+	LRUSamples            int        `yaml:"lruSamples"`
+	LFUDecayTime          string     `yaml:"lfuDecayTime"`
+	EvictionPolicy        string     `yaml:"evictionPolicy"`
+	HashTags              bool       `yaml:"hashTags"`
+	KeyspaceNotifications bool       `yaml:"keyspaceNotifications"`
+	NearCache             *nearCache `yaml:"nearCache"`
 }
 
 type dmaps struct {
@@ -108,9 +119,17 @@ type dmaps struct {
 	MaxKeys                     int             `yaml:"maxKeys"`
 	MaxInuse                    int             `yaml:"maxInuse"`
 	LRUSamples                  int             `yaml:"lruSamples"`
+	LFUDecayTime                string          `yaml:"lfuDecayTime"`
 	EvictionPolicy              string          `yaml:"evictionPolicy"`
+	MaxMemory                   int             `yaml:"maxMemory"`
+	MaxMemoryPolicy             string          `yaml:"maxMemoryPolicy"`
 	CheckEmptyFragmentsInterval string          `yaml:"checkEmptyFragmentsInterval"`
 	TriggerCompactionInterval   string          `yaml:"triggerCompactionInterval"`
+	TombstoneGracePeriod        string          `yaml:"tombstoneGracePeriod"`
+	SnapshotDir                 string          `yaml:"snapshotDir"`
+	HashTags                    bool            `yaml:"hashTags"`
+	KeyspaceNotifications       bool            `yaml:"keyspaceNotifications"`
+	NearCache                   *nearCache      `yaml:"nearCache"`
 	Custom                      map[string]dmap `yaml:"custom"`
 }
 
@@ -88,6 +88,14 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.TTLDuration = ttlDuration
 	}
 
+	if c.DMaps.LFUDecayTime != "" {
+		lfuDecayTime, err := time.ParseDuration(c.DMaps.LFUDecayTime)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.lfuDecayTime")
+		}
+		res.LFUDecayTime = lfuDecayTime
+	}
+
 	if c.DMaps.CheckEmptyFragmentsInterval != "" {
 		checkEmptyFragmentsInterval, err := time.ParseDuration(c.DMaps.CheckEmptyFragmentsInterval)
 		if err != nil {
@@ -104,11 +112,40 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.TriggerCompactionInterval = triggerCompactionInterval
 	}
 
+	if c.DMaps.TombstoneGracePeriod != "" {
+		tombstoneGracePeriod, err := time.ParseDuration(c.DMaps.TombstoneGracePeriod)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.tombstoneGracePeriod")
+		}
+		res.TombstoneGracePeriod = tombstoneGracePeriod
+	}
+
 	res.NumEvictionWorkers = c.DMaps.NumEvictionWorkers
 	res.MaxKeys = c.DMaps.MaxKeys
 	res.MaxInuse = c.DMaps.MaxInuse
 	res.EvictionPolicy = EvictionPolicy(c.DMaps.EvictionPolicy)
 	res.LRUSamples = c.DMaps.LRUSamples
+	res.MaxMemory = c.DMaps.MaxMemory
+	res.MaxMemoryPolicy = EvictionPolicy(c.DMaps.MaxMemoryPolicy)
+	res.SnapshotDir = c.DMaps.SnapshotDir
+	res.HashTags = c.DMaps.HashTags
+	res.KeyspaceNotifications = c.DMaps.KeyspaceNotifications
+
+	if c.DMaps.NearCache != nil {
+		nc := &NearCache{
+			MaxKeys:        c.DMaps.NearCache.MaxKeys,
+			MaxInuse:       c.DMaps.NearCache.MaxInuse,
+			EvictionPolicy: EvictionPolicy(c.DMaps.NearCache.EvictionPolicy),
+		}
+		if c.DMaps.NearCache.TTL != "" {
+			ttl, err := time.ParseDuration(c.DMaps.NearCache.TTL)
+			if err != nil {
+				return nil, errors.WithMessage(err, "failed to parse dmaps.nearCache.ttl")
+			}
+			nc.TTL = ttl
+		}
+		res.NearCache = nc
+	}
 
 	if c.DMaps.Engine != nil {
 		e := NewEngine()
@@ -122,10 +159,12 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.Custom = make(map[string]DMap)
 		for name, dc := range c.DMaps.Custom {
 			cc := DMap{
-				MaxInuse:       dc.MaxInuse,
-				MaxKeys:        dc.MaxKeys,
-				EvictionPolicy: EvictionPolicy(dc.EvictionPolicy),
-				LRUSamples:     dc.LRUSamples,
+				MaxInuse:              dc.MaxInuse,
+				MaxKeys:               dc.MaxKeys,
+				EvictionPolicy:        EvictionPolicy(dc.EvictionPolicy),
+				LRUSamples:            dc.LRUSamples,
+				HashTags:              dc.HashTags,
+				KeyspaceNotifications: dc.KeyspaceNotifications,
 			}
 			if dc.Engine != nil {
 				e := NewEngine()
@@ -134,6 +173,21 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 				e.Config = dc.Engine.Config
 				cc.Engine = e
 			}
+			if dc.NearCache != nil {
+				nc := &NearCache{
+					MaxKeys:        dc.NearCache.MaxKeys,
+					MaxInuse:       dc.NearCache.MaxInuse,
+					EvictionPolicy: EvictionPolicy(dc.NearCache.EvictionPolicy),
+				}
+				if dc.NearCache.TTL != "" {
+					ttl, err := time.ParseDuration(dc.NearCache.TTL)
+					if err != nil {
+						return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.nearCache.ttl", name)
+					}
+					nc.TTL = ttl
+				}
+				cc.NearCache = nc
+			}
 			if dc.MaxIdleDuration != "" {
 				maxIdleDuration, err := time.ParseDuration(dc.MaxIdleDuration)
 				if err != nil {
@@ -141,6 +195,13 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 				}
 				cc.MaxIdleDuration = maxIdleDuration
 			}
+			if dc.LFUDecayTime != "" {
+				lfuDecayTime, err := time.ParseDuration(dc.LFUDecayTime)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.lfuDecayTime", name)
+				}
+				cc.LFUDecayTime = lfuDecayTime
+			}
 			if dc.TTLDuration != "" {
 				ttlDuration, err := time.ParseDuration(dc.TTLDuration)
 				if err != nil {
@@ -0,0 +1,82 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultNearCacheMaxKeys is the maximum number of keys in a near cache, if
+// neither MaxKeys nor MaxInuse is set.
+const DefaultNearCacheMaxKeys = 65536
+
+// DefaultNearCacheTTL is the maximum time to keep an entry in a near cache, if
+// TTL is not set.
+const DefaultNearCacheTTL = time.Minute
+
+// NearCache denotes configuration for near caches. A near cache keeps the
+// recently read entries of a DMap in local memory, instead of asking the
+// partition owner every time. The cached keys are removed when they are put,
+// deleted, expired or evicted on the partition owner.
+type NearCache struct {
+	// MaxKeys denotes maximum key count in a near cache.
+	MaxKeys int
+
+	// MaxInuse denotes maximum amount of memory in bytes for the keys and the
+	// values in a near cache.
+	MaxInuse int
+
+	// TTL is the maximum time to keep an entry in a near cache. The entries are
+	// never kept longer than their TTL on the cluster. The invalidations are
+	// delivered on a best-effort basis, TTL limits the staleness of the entries.
+	// It's DefaultNearCacheTTL by default.
+	TTL time.Duration
+
+	// EvictionPolicy decides the key to evict when MaxKeys or MaxInuse is
+	// reached. LRUEviction evicts the least recently used key. Otherwise, the
+	// oldest key is evicted.
+	EvictionPolicy EvictionPolicy
+}
+
+// Sanitize sets default values to empty configuration variables, if it's possible.
+func (n *NearCache) Sanitize() error {
+	if n.MaxKeys <= 0 && n.MaxInuse <= 0 {
+		n.MaxKeys = DefaultNearCacheMaxKeys
+	}
+	if n.TTL == 0 {
+		n.TTL = DefaultNearCacheTTL
+	}
+	if n.EvictionPolicy == "" {
+		n.EvictionPolicy = "NONE"
+	}
+	return nil
+}
+
+// Validate finds errors in the current configuration.
+func (n *NearCache) Validate() error {
+	if n.MaxKeys < 0 {
+		return errors.New("MaxKeys cannot be negative")
+	}
+	if n.MaxInuse < 0 {
+		return errors.New("MaxInuse cannot be negative")
+	}
+	if n.TTL < 0 {
+		return errors.New("TTL cannot be negative")
+	}
+	return nil
+}
+
+var _ IConfig = (*NearCache)(nil)
@@ -15,10 +15,12 @@
 package olric
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/pkg/neterrors"
 	"github.com/buraksezer/olric/query"
 )
 
@@ -56,6 +58,15 @@ var (
 	// ErrKeyTooLarge means that the given key is too large to process.
 	// Maximum length of a key is 256 bytes.
 	ErrKeyTooLarge = errors.New("key too large")
+
+	// ErrVersionMismatch means that the key has been modified since its version was read.
+	ErrVersionMismatch = errors.New("version mismatch")
+
+	// ErrTransactionConflict means that a watched key has been modified before the transaction is committed.
+	ErrTransactionConflict = errors.New("transaction conflict")
+
+	// ErrOutOfMemory means that the memory budget of the node is exceeded and no key can be evicted.
+	ErrOutOfMemory = errors.New("out of memory")
 )
 
 // NumConcurrentWorkers is the number of concurrent workers to run a query on the cluster.
@@ -84,6 +95,14 @@ func convertDMapError(err error) error {
 		return ErrWriteQuorum
 	case errors.Is(err, dmap.ErrServerGone):
 		return ErrServerGone
+	case errors.Is(err, dmap.ErrVersionMismatch):
+		return ErrVersionMismatch
+	case errors.Is(err, dmap.ErrTransactionConflict):
+		return ErrTransactionConflict
+	case errors.Is(err, dmap.ErrOutOfMemory):
+		return ErrOutOfMemory
+	case errors.Is(err, neterrors.ErrInvalidArgument):
+		return ErrInvalidArgument
 	default:
 		return convertClusterError(err)
 	}
@@ -91,10 +110,32 @@ func convertDMapError(err error) error {
 
 // Entry is a DMap entry with its metadata.
 type Entry struct {
-	Key       string
-	Value     interface{}
-	TTL       int64
-	Timestamp int64
+	Key        string
+	Value      interface{}
+	TTL        int64
+	Timestamp  int64
+	LastAccess int64
+	// Version is an opaque token for CompareAndSwap.
+	Version int64
+}
+
+// Reasons of the eviction events.
+const (
+	// EvictionReasonEvicted means that the entry is evicted by LRU, LFU or MaxMemoryPolicy.
+	EvictionReasonEvicted = dmap.EvictionReasonEvicted
+
+	// EvictionReasonExpired means that the TTL of the entry is exceeded.
+	EvictionReasonExpired = dmap.EvictionReasonExpired
+
+	// EvictionReasonIdle means that the entry is idle longer than MaxIdleDuration.
+	EvictionReasonIdle = dmap.EvictionReasonIdle
+)
+
+// EvictionEvent is passed to the eviction listeners. Value is the last value of the entry.
+type EvictionEvent struct {
+	Key    string
+	Value  interface{}
+	Reason string
 }
 
 // LockContext is returned by Lock and LockWithTimeout methods.
@@ -133,7 +174,12 @@ func (dm *DMap) Name() string {
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value.
 func (dm *DMap) Get(key string) (interface{}, error) {
-	value, err := dm.dm.Get(key)
+	return dm.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get but the given context cancels the network operations.
+func (dm *DMap) GetContext(ctx context.Context, key string) (interface{}, error) {
+	value, err := dm.dm.GetContext(ctx, key)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
@@ -144,16 +190,23 @@ func (dm *DMap) Get(key string) (interface{}, error) {
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value.
 func (dm *DMap) GetEntry(key string) (*Entry, error) {
-	e, err := dm.dm.GetEntry(key)
+	return dm.GetEntryContext(context.Background(), key)
+}
+
+// GetEntryContext is like GetEntry but the given context cancels the network operations.
+func (dm *DMap) GetEntryContext(ctx context.Context, key string) (*Entry, error) {
+	e, err := dm.dm.GetEntryContext(ctx, key)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
 
 	return &Entry{
-		Key:       e.Key,
-		Value:     e.Value,
-		TTL:       e.TTL,
-		Timestamp: e.Timestamp,
+		Key:        e.Key,
+		Value:      e.Value,
+		TTL:        e.TTL,
+		Timestamp:  e.Timestamp,
+		LastAccess: e.LastAccess,
+		Version:    e.Version,
 	}, nil
 }
 
@@ -164,11 +217,17 @@ func (dm *DMap) GetEntry(key string) (*Entry, error) {
 //
 // You should know that the locks are approximate, and only to be used for non-critical purposes.
 func (dm *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*LockContext, error) {
-	ctx, err := dm.dm.LockWithTimeout(key, timeout, deadline)
+	return dm.LockWithTimeoutContext(context.Background(), key, timeout, deadline)
+}
+
+// LockWithTimeoutContext is like LockWithTimeout but the given context cancels the network operations
+// and waiting for the lock.
+func (dm *DMap) LockWithTimeoutContext(ctx context.Context, key string, timeout, deadline time.Duration) (*LockContext, error) {
+	lctx, err := dm.dm.LockWithTimeoutContext(ctx, key, timeout, deadline)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
-	return &LockContext{ctx: ctx}, nil
+	return &LockContext{ctx: lctx}, nil
 }
 
 // Lock sets a lock for the given key. Acquired lock is only for the key in this dmap.
@@ -177,16 +236,27 @@ func (dm *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*L
 //
 // You should know that the locks are approximate, and only to be used for non-critical purposes.
 func (dm *DMap) Lock(key string, deadline time.Duration) (*LockContext, error) {
-	ctx, err := dm.dm.Lock(key, deadline)
+	return dm.LockContext(context.Background(), key, deadline)
+}
+
+// LockContext is like Lock but the given context cancels the network operations
+// and waiting for the lock.
+func (dm *DMap) LockContext(ctx context.Context, key string, deadline time.Duration) (*LockContext, error) {
+	lctx, err := dm.dm.LockContext(ctx, key, deadline)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
-	return &LockContext{ctx: ctx}, nil
+	return &LockContext{ctx: lctx}, nil
 }
 
 // Unlock releases the lock.
 func (l *LockContext) Unlock() error {
-	err := l.ctx.Unlock()
+	return l.UnlockContext(context.Background())
+}
+
+// UnlockContext is like Unlock but the given context cancels the network operations.
+func (l *LockContext) UnlockContext(ctx context.Context) error {
+	err := l.ctx.UnlockContext(ctx)
 	return convertDMapError(err)
 }
 
@@ -195,7 +265,12 @@ func (l *LockContext) Unlock() error {
 // is arbitrary. It is safe to modify the contents of the arguments after
 // Put returns but not before.
 func (dm *DMap) PutEx(key string, value interface{}, timeout time.Duration) error {
-	err := dm.dm.PutEx(key, value, timeout)
+	return dm.PutExContext(context.Background(), key, value, timeout)
+}
+
+// PutExContext is like PutEx but the given context cancels the network operations.
+func (dm *DMap) PutExContext(ctx context.Context, key string, value interface{}, timeout time.Duration) error {
+	err := dm.dm.PutExContext(ctx, key, value, timeout)
 	return convertDMapError(err)
 }
 
@@ -204,7 +279,12 @@ func (dm *DMap) PutEx(key string, value interface{}, timeout time.Duration) erro
 // is arbitrary. It is safe to modify the contents of the arguments after
 // Put returns but not before.
 func (dm *DMap) Put(key string, value interface{}) error {
-	err := dm.dm.Put(key, value)
+	return dm.PutContext(context.Background(), key, value)
+}
+
+// PutContext is like Put but the given context cancels the network operations.
+func (dm *DMap) PutContext(ctx context.Context, key string, value interface{}) error {
+	err := dm.dm.PutContext(ctx, key, value)
 	return convertDMapError(err)
 }
 
@@ -220,7 +300,12 @@ func (dm *DMap) Put(key string, value interface{}) error {
 // IfFound: Only set the key if it already exist.
 // It returns ErrKeyNotFound if the key does not exist.
 func (dm *DMap) PutIf(key string, value interface{}, flags int16) error {
-	err := dm.dm.PutIf(key, value, flags)
+	return dm.PutIfContext(context.Background(), key, value, flags)
+}
+
+// PutIfContext is like PutIf but the given context cancels the network operations.
+func (dm *DMap) PutIfContext(ctx context.Context, key string, value interface{}, flags int16) error {
+	err := dm.dm.PutIfContext(ctx, key, value, flags)
 	return convertDMapError(err)
 }
 
@@ -236,20 +321,86 @@ func (dm *DMap) PutIf(key string, value interface{}, flags int16) error {
 // IfFound: Only set the key if it already exist.
 // It returns ErrKeyNotFound if the key does not exist.
 func (dm *DMap) PutIfEx(key string, value interface{}, timeout time.Duration, flags int16) error {
-	err := dm.dm.PutIfEx(key, value, timeout, flags)
+	return dm.PutIfExContext(context.Background(), key, value, timeout, flags)
+}
+
+// PutIfExContext is like PutIfEx but the given context cancels the network operations.
+func (dm *DMap) PutIfExContext(ctx context.Context, key string, value interface{}, timeout time.Duration, flags int16) error {
+	err := dm.dm.PutIfExContext(ctx, key, value, timeout, flags)
+	return convertDMapError(err)
+}
+
+// CompareAndSwap sets the value for the given key, if the current version of
+// the key is still the expected one. The version of an entry is returned by
+// GetEntry. Zero version means that the key must not exist. It returns
+// ErrVersionMismatch if the key has been modified since the version was read.
+// It's thread-safe.
+func (dm *DMap) CompareAndSwap(key string, version int64, value interface{}) error {
+	return dm.CompareAndSwapContext(context.Background(), key, version, value)
+}
+
+// CompareAndSwapContext is like CompareAndSwap but the given context cancels the network operations.
+func (dm *DMap) CompareAndSwapContext(ctx context.Context, key string, version int64, value interface{}) error {
+	err := dm.dm.CompareAndSwapContext(ctx, key, version, value)
 	return convertDMapError(err)
 }
 
 // Expire updates the expiry for the given key. It returns ErrKeyNotFound if the
-// DB does not contain the key. It's thread-safe.
+// DB does not contain the key, or the key is already expired or idle. It's thread-safe.
 func (dm *DMap) Expire(key string, timeout time.Duration) error {
-	err := dm.dm.Expire(key, timeout)
+	return dm.ExpireContext(context.Background(), key, timeout)
+}
+
+// ExpireContext is like Expire but the given context cancels the network operations.
+func (dm *DMap) ExpireContext(ctx context.Context, key string, timeout time.Duration) error {
+	err := dm.dm.ExpireContext(ctx, key, timeout)
 	return convertDMapError(err)
 }
 
+// TTL returns the remaining time to live of the given key. It returns zero, if the
+// key has no expiry. It returns ErrKeyNotFound if the DB does not contain the key.
+// The value is not read.
+func (dm *DMap) TTL(key string) (time.Duration, error) {
+	return dm.TTLContext(context.Background(), key)
+}
+
+// TTLContext is like TTL but the given context cancels the network operations.
+func (dm *DMap) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := dm.dm.TTLContext(ctx, key)
+	return ttl, convertDMapError(err)
+}
+
+// Persist removes the expiry of the given key. It returns ErrKeyNotFound if the DB
+// does not contain the key. It's thread-safe.
+func (dm *DMap) Persist(key string) error {
+	return dm.PersistContext(context.Background(), key)
+}
+
+// PersistContext is like Persist but the given context cancels the network operations.
+func (dm *DMap) PersistContext(ctx context.Context, key string) error {
+	err := dm.dm.PersistContext(ctx, key)
+	return convertDMapError(err)
+}
+
+// GetEx gets the value for the given key and updates its expiry in one round trip.
+// A zero timeout removes the expiry, like Persist. It returns ErrKeyNotFound if the
+// DB does not contain the key.
+func (dm *DMap) GetEx(key string, timeout time.Duration) (interface{}, error) {
+	return dm.GetExContext(context.Background(), key, timeout)
+}
+
+// GetExContext is like GetEx but the given context cancels the network operations.
+func (dm *DMap) GetExContext(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	value, err := dm.dm.GetExContext(ctx, key, timeout)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return value, nil
+}
+
 // Query runs a distributed query on a dmap instance.
-// Olric supports a very simple query DSL and now, it only scans keys. The query DSL has very
-// few keywords:
+// Olric supports a very simple query DSL to filter the entries by their keys and values.
+// The query DSL has very few keywords:
 //
 // $onKey: Runs the given query on keys or manages options on keys for a given query.
 //
@@ -291,10 +442,73 @@ func (dm *DMap) Expire(key string, timeout time.Duration) error {
 // 	  },
 //   }
 //
-// Query function returns a cursor which has Range and Close methods. Please take look at the Range
-// function for further info.
+// Keywords for $onValue:
+//
+// $eq, $gt, $lt: Compares the value with the given one. Numbers are compared by value and
+// strings lexicographically.
+//
+// $in: Checks that the value is equal to one of the given values.
+//
+// $exists: Checks that the value or the field exists.
+//
+// The other keys in $onValue are field paths into the decoded maps, the elements are
+// separated by dots. The values are decoded by the configured serializer on the partition
+// owners:
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^user:",
+// 	  },
+// 	  "$onValue": query.M{
+// 		  "address.city": query.M{"$eq": "Istanbul"},
+// 		  "age":          query.M{"$gt": 30},
+// 	  },
+//   }
+//
+// $onKey can be omitted to run the predicates on all the keys.
+//
+// $aggregate: Computes the given aggregation operators over the matching entries. Call
+// Cursor.Aggregate to run it. The operators are computed on the partition owners, so the
+// values are not sent to the caller.
+//
+// Keywords for $aggregate:
+//
+// $count: Counts the matching entries.
+//
+// $sum, $min, $max, $avg: Computes the sum, the minimum, the maximum and the average of
+// the numeric values. The other values are ignored.
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^even:",
+// 	  },
+// 	  "$aggregate": query.M{
+// 		  "$count": true,
+// 		  "$sum":   true,
+// 	  },
+//   }
+//
+// $limit: Stops the query after the given number of entries. The partition owners stop
+// scanning after $limit matching entries. It can be used with the $count operator to count
+// up to a limit, the other aggregation operators don't support it.
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^even:",
+// 	  },
+// 	  "$limit": 10,
+//   }
+//
+// Query function returns a cursor which has Range, RangeEntries, Page, Aggregate and Close
+// methods. Please take look at the Range function for further info.
 func (dm *DMap) Query(q query.M) (*Cursor, error) {
-	c, err := dm.dm.Query(q)
+	return dm.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query but the given context cancels the network operations
+// of the cursor. Range returns the error of the context, if it's done.
+func (dm *DMap) QueryContext(ctx context.Context, q query.M) (*Cursor, error) {
+	c, err := dm.dm.QueryContext(ctx, q)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
@@ -308,6 +522,42 @@ func (c *Cursor) Range(f func(key string, value interface{}) bool) error {
 	return convertDMapError(err)
 }
 
+// RangeEntries is like Range but it yields the entries with their metadata.
+func (c *Cursor) RangeEntries(f func(e *Entry) bool) error {
+	err := c.cursor.RangeEntries(func(e *dmap.Entry) bool {
+		return f(&Entry{
+			Key:        e.Key,
+			Value:      e.Value,
+			TTL:        e.TTL,
+			Timestamp:  e.Timestamp,
+			LastAccess: e.LastAccess,
+			Version:    e.Version,
+		})
+	})
+	return convertDMapError(err)
+}
+
+// Page returns at most limit matching key/value pairs with a continuation token.
+// Pass the token to Page of a cursor with the same query to get the next page.
+// The first page is requested with an empty token, the returned token is empty
+// after the last page. The cursor keeps no state between the pages, so a page
+// can be requested by another cursor or another member.
+func (c *Cursor) Page(token string, limit int) (QueryResponse, string, error) {
+	result, next, err := c.cursor.Page(token, limit)
+	if err != nil {
+		return nil, "", convertDMapError(err)
+	}
+	return QueryResponse(result), next, nil
+}
+
+// Aggregate computes the $aggregate operators of the query over the matching
+// entries. The partial results are computed by the partition owners and merged
+// on this node.
+func (c *Cursor) Aggregate() (*query.Aggregation, error) {
+	a, err := c.cursor.Aggregate()
+	return a, convertDMapError(err)
+}
+
 // Close cancels the underlying context and background goroutines stops running.
 func (c *Cursor) Close() {
 	c.cursor.Close()
@@ -316,13 +566,100 @@ func (c *Cursor) Close() {
 // Delete deletes the value for the given key. Delete will not return error if key doesn't exist. It's thread-safe.
 // It is safe to modify the contents of the argument after Delete returns.
 func (dm *DMap) Delete(key string) error {
-	err := dm.dm.Delete(key)
+	return dm.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete but the given context cancels the network operations.
+func (dm *DMap) DeleteContext(ctx context.Context, key string) error {
+	err := dm.dm.DeleteContext(ctx, key)
 	return convertDMapError(err)
 }
 
+// DeleteByQuery deletes the keys that match the given query and returns the number
+// of the deleted keys. The keys are deleted on the partition owners and the deletions
+// are replicated to the backups, like Delete. $aggregate and $limit are not supported.
+//
+// The deletions are not atomic. If it returns an error, some of the matching keys may
+// already be deleted.
+func (dm *DMap) DeleteByQuery(q query.M) (int, error) {
+	return dm.DeleteByQueryContext(context.Background(), q)
+}
+
+// DeleteByQueryContext is like DeleteByQuery but the given context cancels the network operations.
+func (dm *DMap) DeleteByQueryContext(ctx context.Context, q query.M) (int, error) {
+	count, err := dm.dm.DeleteByQueryContext(ctx, q)
+	return count, convertDMapError(err)
+}
+
+// ExpireByQuery updates the expiry of the keys that match the given query and returns
+// the number of the updated keys. The keys are updated on the partition owners and the
+// updates are replicated to the backups, like Expire. $aggregate and $limit are not
+// supported.
+func (dm *DMap) ExpireByQuery(q query.M, timeout time.Duration) (int, error) {
+	return dm.ExpireByQueryContext(context.Background(), q, timeout)
+}
+
+// ExpireByQueryContext is like ExpireByQuery but the given context cancels the network operations.
+func (dm *DMap) ExpireByQueryContext(ctx context.Context, q query.M, timeout time.Duration) (int, error) {
+	count, err := dm.dm.ExpireByQueryContext(ctx, q, timeout)
+	return count, convertDMapError(err)
+}
+
+func convertDMapErrors(errs map[string]error) map[string]error {
+	for key, err := range errs {
+		errs[key] = convertDMapError(err)
+	}
+	return errs
+}
+
+// MGet gets the values for the given keys. Keys are grouped by their partition
+// owners, and the owners are queried in parallel. It returns the found values
+// and the errors for the rest of the keys. ErrKeyNotFound is returned for the
+// missing keys. It's thread-safe.
+func (dm *DMap) MGet(keys []string) (map[string]interface{}, map[string]error) {
+	return dm.MGetContext(context.Background(), keys)
+}
+
+// MGetContext is like MGet but the given context cancels the network operations.
+func (dm *DMap) MGetContext(ctx context.Context, keys []string) (map[string]interface{}, map[string]error) {
+	values, errs := dm.dm.MGetContext(ctx, keys)
+	return values, convertDMapErrors(errs)
+}
+
+// MPut sets the values for the given keys. Keys are grouped by their partition
+// owners, and the owners are called in parallel. It returns the errors for the
+// failed keys. It's thread-safe.
+func (dm *DMap) MPut(items map[string]interface{}) map[string]error {
+	return dm.MPutContext(context.Background(), items)
+}
+
+// MPutContext is like MPut but the given context cancels the network operations.
+func (dm *DMap) MPutContext(ctx context.Context, items map[string]interface{}) map[string]error {
+	errs := dm.dm.MPutContext(ctx, items)
+	return convertDMapErrors(errs)
+}
+
+// MDelete deletes the given keys. Keys are grouped by their partition owners,
+// and the owners are called in parallel. It returns the errors for the failed
+// keys. It's thread-safe.
+func (dm *DMap) MDelete(keys []string) map[string]error {
+	return dm.MDeleteContext(context.Background(), keys)
+}
+
+// MDeleteContext is like MDelete but the given context cancels the network operations.
+func (dm *DMap) MDeleteContext(ctx context.Context, keys []string) map[string]error {
+	errs := dm.dm.MDeleteContext(ctx, keys)
+	return convertDMapErrors(errs)
+}
+
 // Incr atomically increments key by delta. The return value is the new value after being incremented or an error.
 func (dm *DMap) Incr(key string, delta int) (int, error) {
-	value, err := dm.dm.Incr(key, delta)
+	return dm.IncrContext(context.Background(), key, delta)
+}
+
+// IncrContext is like Incr but the given context cancels the network operations.
+func (dm *DMap) IncrContext(ctx context.Context, key string, delta int) (int, error) {
+	value, err := dm.dm.IncrContext(ctx, key, delta)
 	if err != nil {
 		return 0, convertDMapError(err)
 	}
@@ -331,7 +668,12 @@ func (dm *DMap) Incr(key string, delta int) (int, error) {
 
 // Decr atomically decrements key by delta. The return value is the new value after being decremented or an error.
 func (dm *DMap) Decr(key string, delta int) (int, error) {
-	value, err := dm.dm.Decr(key, delta)
+	return dm.DecrContext(context.Background(), key, delta)
+}
+
+// DecrContext is like Decr but the given context cancels the network operations.
+func (dm *DMap) DecrContext(ctx context.Context, key string, delta int) (int, error) {
+	value, err := dm.dm.DecrContext(ctx, key, delta)
 	if err != nil {
 		return 0, convertDMapError(err)
 	}
@@ -340,7 +682,12 @@ func (dm *DMap) Decr(key string, delta int) (int, error) {
 
 // GetPut atomically sets key to value and returns the old value stored at key.
 func (dm *DMap) GetPut(key string, value interface{}) (interface{}, error) {
-	prev, err := dm.dm.GetPut(key, value)
+	return dm.GetPutContext(context.Background(), key, value)
+}
+
+// GetPutContext is like GetPut but the given context cancels the network operations.
+func (dm *DMap) GetPutContext(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	prev, err := dm.dm.GetPutContext(ctx, key, value)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
@@ -351,6 +698,45 @@ func (dm *DMap) GetPut(key string, value interface{}) (interface{}, error) {
 // is no global lock on DMaps. So if you call Put/PutEx and Destroy methods
 // concurrently on the cluster, Put/PutEx calls may set new values to the dmap.
 func (dm *DMap) Destroy() error {
-	err := dm.dm.Destroy()
+	return dm.DestroyContext(context.Background())
+}
+
+// DestroyContext is like Destroy but the given context cancels the network operations.
+func (dm *DMap) DestroyContext(ctx context.Context) error {
+	err := dm.dm.DestroyContext(ctx)
 	return convertDMapError(err)
 }
+
+// Len returns the number of the keys in the DMap. The primary owners of the partitions
+// count their keys, so the backups are not counted. Expired keys are not counted,
+// even if they are not evicted yet.
+func (dm *DMap) Len() (int, error) {
+	return dm.LenContext(context.Background())
+}
+
+// LenContext is like Len but the given context cancels the network operations.
+func (dm *DMap) LenContext(ctx context.Context) (int, error) {
+	count, err := dm.dm.LenContext(ctx)
+	return count, convertDMapError(err)
+}
+
+// AddEvictionListener registers a function that's called whenever an entry of the DMap
+// is evicted, expired or removed by MaxIdleDuration on this member. Only the partition
+// owner calls the listeners, so register them on every member to receive all the events.
+// The functions are called sequentially by a background goroutine. Returns a registration ID.
+func (dm *DMap) AddEvictionListener(f func(EvictionEvent)) uint64 {
+	return dm.dm.AddEvictionListener(func(event dmap.EvictionEvent) {
+		f(EvictionEvent(event))
+	})
+}
+
+// RemoveEvictionListener removes an eviction listener with the given listenerID.
+func (dm *DMap) RemoveEvictionListener(listenerID uint64) {
+	dm.dm.RemoveEvictionListener(listenerID)
+}
+
+// DroppedEvictionEvents returns the number of the eviction events that are dropped
+// on this member, because the listeners couldn't keep up with the evictions.
+func (dm *DMap) DroppedEvictionEvents() uint64 {
+	return dm.dm.DroppedEvictionEvents()
+}
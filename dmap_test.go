@@ -16,6 +16,7 @@ package olric
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -238,6 +239,36 @@ func TestOlric_DMap_Delete(t *testing.T) {
 	require.ErrorIs(t, err, ErrKeyNotFound)
 }
 
+func TestOlric_DMap_MPut_MGet_MDelete(t *testing.T) {
+	db := newTestOlric(t)
+
+	dm, err := db.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	items := make(map[string]interface{})
+	var keys []string
+	for i := 0; i < 10; i++ {
+		items[testutil.ToKey(i)] = i
+		keys = append(keys, testutil.ToKey(i))
+	}
+	errs := dm.MPut(items)
+	require.Len(t, errs, 0)
+
+	values, errs := dm.MGet(append(keys, "missing-key"))
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs["missing-key"], ErrKeyNotFound)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, i, values[testutil.ToKey(i)])
+	}
+
+	errs = dm.MDelete(keys)
+	require.Len(t, errs, 0)
+
+	values, errs = dm.MGet(keys)
+	require.Len(t, values, 0)
+	require.Len(t, errs, 10)
+}
+
 func TestOlric_DMap_Incr(t *testing.T) {
 	db := newTestOlric(t)
 
@@ -357,3 +388,38 @@ func TestOlric_DMap_Lock_Deadline(t *testing.T) {
 	_, errTwo := dm.Lock("mykey", time.Millisecond)
 	require.ErrorIs(t, ErrLockNotAcquired, errTwo)
 }
+
+func TestOlric_DMap_EvictionListener(t *testing.T) {
+	db := newTestOlric(t)
+
+	dm, err := db.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var mtx sync.Mutex
+	events := make(map[string]EvictionEvent)
+	dm.AddEvictionListener(func(event EvictionEvent) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		events[event.Key] = event
+	})
+
+	for i := 0; i < 10; i++ {
+		err = dm.PutEx(testutil.ToKey(i), testutil.ToVal(i), 10*time.Millisecond)
+		require.NoError(t, err)
+	}
+
+	// The janitor removes the expired keys at background.
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(events) == 10
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	for i := 0; i < 10; i++ {
+		event := events[testutil.ToKey(i)]
+		require.Equal(t, EvictionReasonExpired, event.Reason)
+		require.Equal(t, testutil.ToVal(i), event.Value)
+	}
+}
@@ -15,6 +15,7 @@
 package olric
 
 import (
+	"context"
 	"errors"
 
 	"github.com/buraksezer/olric/internal/dtopic"
@@ -84,7 +85,12 @@ func (db *Olric) NewDTopic(name string, concurrency int, flag int16) (*DTopic, e
 // Publish publishes the given message to listeners of the topic. Message order
 // and delivery are not guaranteed.
 func (dt *DTopic) Publish(msg interface{}) error {
-	err := dt.dt.Publish(msg)
+	return dt.PublishContext(context.Background(), msg)
+}
+
+// PublishContext is like Publish but the given context cancels the network operations.
+func (dt *DTopic) PublishContext(ctx context.Context, msg interface{}) error {
+	err := dt.dt.PublishContext(ctx, msg)
 	return convertDTopicError(err)
 }
 
@@ -15,6 +15,8 @@
 package partitions
 
 import (
+	"math"
+	"strings"
 	"sync"
 	"unsafe"
 
@@ -36,3 +38,37 @@ func HKey(name, key string) uint64 {
 	tmp := name + key
 	return hashFunc.Sum64(*(*[]byte)(unsafe.Pointer(&tmp)))
 }
+
+// HashTag returns the non-empty substring between the first "{" and the
+// following "}" in key. It returns false if there is no such substring.
+func HashTag(key string) (string, bool) {
+	start := strings.IndexByte(key, '{')
+	if start == -1 {
+		return "", false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return "", false
+	}
+	return key[start+1 : start+1+end], true
+}
+
+// HKeyWithHashTag calculates HKey of key, but the result lands on the same
+// partition with the other keys that have the same hash tag. Only the partition
+// part of HKey is taken from the hash tag, so the keys still have different
+// HKeys.
+func HKeyWithHashTag(name, key string, count uint64) uint64 {
+	hkey := HKey(name, key)
+	tag, ok := HashTag(key)
+	if !ok {
+		return hkey
+	}
+
+	base := hkey - hkey%count
+	partID := HKey(name, tag) % count
+	if partID > math.MaxUint64-base {
+		// Overflow, take the previous one. It has the same remainder.
+		base -= count
+	}
+	return base + partID
+}
@@ -26,3 +26,33 @@ func TestPartitions_HKey(t *testing.T) {
 	hkey := HKey("storage-unit-name", "some-key")
 	require.NotEqualf(t, 0, hkey, "HKey is zero. This shouldn't be normal")
 }
+
+func TestPartitions_HashTag(t *testing.T) {
+	cases := map[string]string{
+		"user:{42}:profile": "42",
+		"{user}:cart":       "user",
+		"{}:{user}":         "",
+		"user:42}{":         "",
+		"user:{42":          "",
+		"user:{{42}}":       "{42",
+	}
+	for key, expected := range cases {
+		tag, ok := HashTag(key)
+		require.Equal(t, expected != "", ok, key)
+		require.Equal(t, expected, tag, key)
+	}
+}
+
+func TestPartitions_HKeyWithHashTag(t *testing.T) {
+	SetHashFunc(hasher.NewDefaultHasher())
+	count := uint64(271)
+
+	profile := HKeyWithHashTag("mymap", "user:{42}:profile", count)
+	cart := HKeyWithHashTag("mymap", "user:{42}:cart", count)
+	require.NotEqual(t, profile, cart)
+	require.Equal(t, profile%count, cart%count)
+	require.Equal(t, HKey("mymap", "42")%count, cart%count)
+
+	// Keys without a hash tag
+	require.Equal(t, HKey("mymap", "user:42:cart"), HKeyWithHashTag("mymap", "user:42:cart", count))
+}
@@ -0,0 +1,133 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"fmt"
+)
+
+// maxRecordsPerCompaction limits the work done by a single Compaction call.
+const maxRecordsPerCompaction = 1000
+
+func (d *DiskStore) isCompactionOK(s *segment) bool {
+	if s.size == 0 {
+		return false
+	}
+	return float64(s.garbage) >= float64(s.size)*maxGarbageRatio
+}
+
+// hasOlderSegments returns true if there is a segment that's older than s.
+// Delete records of s have to be carried forward in that case, otherwise
+// an older put record resurrects the key after a restart.
+func (d *DiskStore) hasOlderSegments(s *segment) bool {
+	return len(d.segments) != 0 && d.segments[0] != s
+}
+
+// compactSegment moves the live records of s into the active segment. It returns
+// true if the whole segment has been processed.
+func (d *DiskStore) compactSegment(s *segment) (bool, error) {
+	var total int
+	var compactErr error
+	offset, err := s.scan(s.compacted, func(offset int64, op uint8, hkey uint64, payload []byte) bool {
+		switch op {
+		case opPut:
+			loc, ok := d.index[hkey]
+			if !ok || loc.segment != s || loc.offset != offset {
+				// Stale record
+				return true
+			}
+			target, newOffset, err := d.appendRecord(opPut, hkey, payload)
+			if err != nil {
+				compactErr = fmt.Errorf("put command failed: HKey: %d: %w", hkey, err)
+				return false
+			}
+			s.inuse -= loc.size()
+			s.garbage += loc.size()
+			loc.segment = target
+			loc.offset = newOffset
+			target.inuse += loc.size()
+		case opDelete:
+			if _, ok := d.index[hkey]; ok || !d.hasOlderSegments(s) {
+				return true
+			}
+			target, _, err := d.appendRecord(opDelete, hkey, nil)
+			if err != nil {
+				compactErr = fmt.Errorf("delete command failed: HKey: %d: %w", hkey, err)
+				return false
+			}
+			// The record is still required, don't count it as garbage. Otherwise
+			// the same delete records are carried forward again and again.
+			target.inuse += recordHeaderSize
+		}
+		total++
+		return total < maxRecordsPerCompaction
+	})
+	if compactErr != nil {
+		return false, compactErr
+	}
+	if err != nil {
+		return false, err
+	}
+	s.compacted = offset
+	return s.compacted >= s.size, nil
+}
+
+func (d *DiskStore) removeSegment(s *segment) error {
+	for i, item := range d.segments {
+		if item == s {
+			d.segments = append(d.segments[:i], d.segments[i+1:]...)
+			break
+		}
+	}
+	return s.remove()
+}
+
+// Compaction moves the live records of fragmented segments into the active one and
+// deletes the old segment files. It returns true if there is nothing to compact.
+func (d *DiskStore) Compaction() (bool, error) {
+	if len(d.segments) == 0 {
+		return true, nil
+	}
+
+	active := d.segments[len(d.segments)-1]
+	if d.isCompactionOK(active) {
+		// Seal the active segment to compact it.
+		s, err := openSegment(d.dir, d.nextID)
+		if err != nil {
+			return false, err
+		}
+		d.nextID++
+		d.segments = append(d.segments, s)
+	}
+
+	for _, s := range d.segments[:len(d.segments)-1] {
+		if !d.isCompactionOK(s) {
+			continue
+		}
+		done, err := d.compactSegment(s)
+		if err != nil {
+			return false, err
+		}
+		if done {
+			if err = d.removeSegment(s); err != nil {
+				return false, err
+			}
+		}
+		// Continue scanning
+		return false, nil
+	}
+
+	return true, nil
+}
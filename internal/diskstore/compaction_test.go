@@ -0,0 +1,68 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/cespare/xxhash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskStore_Compaction(t *testing.T) {
+	c := testConfig(t)
+	c.Add("maxSegmentSize", 4096)
+	s, err := testDiskStore(c)
+	require.NoError(t, err)
+
+	timestamp := time.Now().UnixNano()
+	putEntries(t, s, timestamp, 1000)
+	require.Greater(t, s.Stats().NumTables, 1)
+
+	for i := 0; i < 900; i++ {
+		require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+	}
+
+	before := s.Stats()
+	for {
+		done, err := s.Compaction()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+	after := s.Stats()
+	require.Less(t, after.Allocated, before.Allocated)
+	require.Equal(t, 100, after.Length)
+
+	// Deleted keys must not come back after a restart.
+	require.NoError(t, s.Close())
+	s, err = testDiskStore(c)
+	require.NoError(t, err)
+	require.Equal(t, 100, s.Stats().Length)
+
+	for i := 0; i < 1000; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		e, err := s.Get(hkey)
+		if i < 900 {
+			require.ErrorIs(t, err, storage.ErrKeyNotFound)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, bval(i), e.Value())
+	}
+}
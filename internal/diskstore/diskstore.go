@@ -0,0 +1,605 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package diskstore implements a persistent storage engine. Every engine instance
+keeps an append-only log of segment files in its own directory and an in-memory
+index of hkeys. The index is rebuilt by replaying the segments on Start, so the
+data survives restarts. Fragmented segments are compacted in the background.
+*/
+package diskstore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/internal/kvstore/entry"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+const (
+	maxGarbageRatio = 0.40
+
+	// maxKeyLen is the limit of the entry format.
+	maxKeyLen = 256
+
+	// 64MB
+	defaultMaxSegmentSize = int64(1 << 26)
+)
+
+// location points to the latest record of an hkey on disk.
+type location struct {
+	segment    *segment
+	offset     int64
+	length     uint32
+	lastAccess int64
+}
+
+func (l *location) size() int64 {
+	return recordHeaderSize + int64(l.length)
+}
+
+// DiskStore implements a persistent storage engine.
+type DiskStore struct {
+	config         *storage.Config
+	log            *log.Logger
+	dir            string
+	syncWrites     bool
+	maxSegmentSize int64
+	nextID         uint64
+	segments       []*segment
+	index          map[uint64]*location
+	accessCounter  bool
+	ordered        orderedIndex
+}
+
+// orderedIndex keeps the HKeys in ascending order for RangeFrom. It's built by
+// the first RangeFrom call and rebuilt after a new key is added. The deleted
+// keys are skipped until the next rebuild.
+type orderedIndex struct {
+	mtx   sync.Mutex
+	hkeys []uint64
+	stale bool
+}
+
+var (
+	_ storage.Engine            = (*DiskStore)(nil)
+	_ storage.LastAccessUpdater = (*DiskStore)(nil)
+	_ storage.OrderedRanger     = (*DiskStore)(nil)
+)
+
+// DefaultConfig returns the default configuration of diskstore. It has no
+// dataDir, the root directory of the engine has to be set by the user. Every
+// engine instance creates its own directory under it.
+func DefaultConfig() *storage.Config {
+	options := storage.NewConfig(nil)
+	options.Add("maxSegmentSize", defaultMaxSegmentSize)
+	options.Add("syncWrites", false)
+	return options
+}
+
+func (d *DiskStore) SetConfig(c *storage.Config) {
+	d.config = c
+}
+
+func (d *DiskStore) SetLogger(l *log.Logger) {
+	d.log = l
+}
+
+func (d *DiskStore) Name() string {
+	return "diskstore"
+}
+
+func (d *DiskStore) NewEntry() storage.Entry {
+	return entry.New()
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("invalid integer: %v", value)
+	}
+}
+
+// getDataDir returns the root directory of the engine. It's required.
+func getDataDir(c *storage.Config) (string, error) {
+	raw, err := c.Get("dataDir")
+	if err != nil {
+		return "", fmt.Errorf("dataDir is required: %w", err)
+	}
+	dataDir, ok := raw.(string)
+	if !ok || dataDir == "" {
+		return "", fmt.Errorf("invalid dataDir: %v", raw)
+	}
+	return dataDir, nil
+}
+
+func randomName() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Fork creates a new DiskStore instance. The directory of the new instance is
+// derived from the "fragment" key, if the configuration has it. A stable fragment
+// name is required to find the same data again after a restart.
+func (d *DiskStore) Fork(c *storage.Config) (storage.Engine, error) {
+	if c == nil {
+		c = d.config.Copy()
+	}
+	dataDir, err := getDataDir(c)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMaxSegmentSize, err := c.Get("maxSegmentSize")
+	if err != nil {
+		return nil, err
+	}
+	maxSegmentSize, err := toInt64(rawMaxSegmentSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse maxSegmentSize: %w", err)
+	}
+
+	var syncWrites bool
+	if raw, err := c.Get("syncWrites"); err == nil {
+		syncWrites, _ = raw.(bool)
+	}
+
+	var accessCounter bool
+	if raw, err := c.Get("accessCounter"); err == nil {
+		accessCounter, _ = raw.(bool)
+	}
+
+	var name string
+	if raw, err := c.Get("fragment"); err == nil {
+		name, _ = raw.(string)
+	}
+	if name == "" {
+		name, err = randomName()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	child := &DiskStore{
+		config:         c,
+		log:            d.log,
+		dir:            filepath.Join(dataDir, url.PathEscape(name)),
+		syncWrites:     syncWrites,
+		maxSegmentSize: maxSegmentSize,
+		index:          make(map[uint64]*location),
+		accessCounter:  accessCounter,
+	}
+	return child, nil
+}
+
+// Start opens the segments on disk and rebuilds the index.
+func (d *DiskStore) Start() error {
+	if d.config == nil {
+		return errors.New("config cannot be nil")
+	}
+	if _, err := getDataDir(d.config); err != nil {
+		return err
+	}
+	if d.dir == "" {
+		// Not forked yet. There is nothing on disk to load.
+		return nil
+	}
+
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	var ids []uint64
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), segmentFileExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), segmentFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		s, err := openSegment(d.dir, id)
+		if err != nil {
+			return err
+		}
+		d.segments = append(d.segments, s)
+		if err = d.replay(s); err != nil {
+			return err
+		}
+		d.nextID = id + 1
+	}
+	return nil
+}
+
+func (d *DiskStore) replay(s *segment) error {
+	offset, err := s.scan(0, func(offset int64, op uint8, hkey uint64, payload []byte) bool {
+		size := recordHeaderSize + int64(len(payload))
+		switch op {
+		case opPut:
+			d.markAsGarbage(hkey)
+			e := entry.New()
+			e.Decode(payload)
+			d.index[hkey] = &location{
+				segment:    s,
+				offset:     offset,
+				length:     uint32(len(payload)),
+				lastAccess: e.LastAccess(),
+			}
+			s.inuse += size
+		case opDelete:
+			d.markAsGarbage(hkey)
+			delete(d.index, hkey)
+			s.garbage += size
+		}
+		return true
+	})
+	if errors.Is(err, errCorruptedRecord) {
+		// The node probably crashed during a write. Drop the torn record.
+		if d.log != nil {
+			d.log.Printf("[WARN] Corrupted record found in %s at offset %d. Truncating the segment",
+				s.file.Name(), offset)
+		}
+		return s.truncate(offset)
+	}
+	return err
+}
+
+// markAsGarbage moves the size of the current record of hkey from inuse to garbage.
+func (d *DiskStore) markAsGarbage(hkey uint64) {
+	loc, ok := d.index[hkey]
+	if !ok {
+		return
+	}
+	loc.segment.inuse -= loc.size()
+	loc.segment.garbage += loc.size()
+}
+
+// activeSegment returns the segment that accepts new records.
+func (d *DiskStore) activeSegment() (*segment, error) {
+	if len(d.segments) != 0 {
+		s := d.segments[len(d.segments)-1]
+		if s.size < d.maxSegmentSize {
+			return s, nil
+		}
+	}
+	s, err := openSegment(d.dir, d.nextID)
+	if err != nil {
+		return nil, err
+	}
+	d.nextID++
+	d.segments = append(d.segments, s)
+	return s, nil
+}
+
+func (d *DiskStore) appendRecord(op uint8, hkey uint64, payload []byte) (*segment, int64, error) {
+	s, err := d.activeSegment()
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, err := s.append(op, hkey, payload, d.syncWrites)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s, offset, nil
+}
+
+// PutRaw sets the raw value for the given key.
+func (d *DiskStore) PutRaw(hkey uint64, value []byte) error {
+	if _, ok := d.index[hkey]; !ok {
+		d.ordered.mtx.Lock()
+		d.ordered.stale = true
+		d.ordered.mtx.Unlock()
+	}
+	s, offset, err := d.appendRecord(opPut, hkey, value)
+	if err != nil {
+		return err
+	}
+	d.markAsGarbage(hkey)
+	lastAccess := time.Now().UnixNano()
+	if d.accessCounter {
+		e := entry.New()
+		e.Decode(value)
+		lastAccess = e.LastAccess()
+	}
+	loc := &location{
+		segment:    s,
+		offset:     offset,
+		length:     uint32(len(value)),
+		lastAccess: lastAccess,
+	}
+	s.inuse += loc.size()
+	d.index[hkey] = loc
+	return nil
+}
+
+// Put sets the value for the given key. It overwrites any previous value for that key.
+func (d *DiskStore) Put(hkey uint64, value storage.Entry) error {
+	if len(value.Key()) >= maxKeyLen {
+		return storage.ErrKeyTooLarge
+	}
+	if !d.accessCounter {
+		value.SetLastAccess(time.Now().UnixNano())
+	}
+	return d.PutRaw(hkey, value.Encode())
+}
+
+// GetRaw extracts encoded value for the given hkey.
+func (d *DiskStore) GetRaw(hkey uint64) ([]byte, error) {
+	loc, ok := d.index[hkey]
+	if !ok {
+		return nil, storage.ErrKeyNotFound
+	}
+	return loc.segment.readPayload(loc.offset, loc.length)
+}
+
+func (d *DiskStore) load(hkey uint64) (*entry.Entry, *location, error) {
+	loc, ok := d.index[hkey]
+	if !ok {
+		return nil, nil, storage.ErrKeyNotFound
+	}
+	payload, err := loc.segment.readPayload(loc.offset, loc.length)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := entry.New()
+	e.Decode(payload)
+	e.SetLastAccess(atomic.LoadInt64(&loc.lastAccess))
+	return e, loc, nil
+}
+
+// Get gets the value for the given key. It returns storage.ErrKeyNotFound if the DB
+// does not contain the key. The returned Entry is its own copy,
+// it is safe to modify the contents of the returned slice.
+func (d *DiskStore) Get(hkey uint64) (storage.Entry, error) {
+	e, loc, err := d.load(hkey)
+	if err != nil {
+		return nil, err
+	}
+	if !d.accessCounter {
+		// Update the last access field
+		atomic.StoreInt64(&loc.lastAccess, time.Now().UnixNano())
+	}
+	return e, nil
+}
+
+// GetTTL gets the timeout for the given key. It returns storage.ErrKeyNotFound if the DB
+// does not contain the key.
+func (d *DiskStore) GetTTL(hkey uint64) (int64, error) {
+	e, _, err := d.load(hkey)
+	if err != nil {
+		return 0, err
+	}
+	return e.TTL(), nil
+}
+
+// GetLastAccess gets the last access time for the given key. It returns
+// storage.ErrKeyNotFound if the DB does not contain the key.
+func (d *DiskStore) GetLastAccess(hkey uint64) (int64, error) {
+	loc, ok := d.index[hkey]
+	if !ok {
+		return 0, storage.ErrKeyNotFound
+	}
+	return atomic.LoadInt64(&loc.lastAccess), nil
+}
+
+// GetKey gets the key for the given hkey. It returns storage.ErrKeyNotFound if the DB
+// does not contain the key.
+func (d *DiskStore) GetKey(hkey uint64) (string, error) {
+	e, _, err := d.load(hkey)
+	if err != nil {
+		return "", err
+	}
+	return e.Key(), nil
+}
+
+// Delete deletes the value for the given key. Delete will not return error if key doesn't exist.
+func (d *DiskStore) Delete(hkey uint64) error {
+	if _, ok := d.index[hkey]; !ok {
+		return nil
+	}
+	s, _, err := d.appendRecord(opDelete, hkey, nil)
+	if err != nil {
+		return err
+	}
+	d.markAsGarbage(hkey)
+	delete(d.index, hkey)
+	s.garbage += recordHeaderSize
+	return nil
+}
+
+// UpdateTTL updates the expiry for the given key.
+func (d *DiskStore) UpdateTTL(hkey uint64, data storage.Entry) error {
+	e, _, err := d.load(hkey)
+	if err != nil {
+		return err
+	}
+	e.SetTTL(data.TTL())
+	e.SetTimestamp(data.Timestamp())
+	return d.Put(hkey, e)
+}
+
+// UpdateLastAccess sets LastAccess of the given key. It's kept in memory and
+// persisted by the next write on the key.
+func (d *DiskStore) UpdateLastAccess(hkey uint64, lastAccess int64) error {
+	loc, ok := d.index[hkey]
+	if !ok {
+		return storage.ErrKeyNotFound
+	}
+	atomic.StoreInt64(&loc.lastAccess, lastAccess)
+	return nil
+}
+
+// Stats is a function which provides disk usage and garbage ratio of a storage instance.
+func (d *DiskStore) Stats() storage.Stats {
+	stats := storage.Stats{
+		NumTables: len(d.segments),
+		Length:    len(d.index),
+	}
+	for _, s := range d.segments {
+		stats.Allocated += int(s.size)
+		stats.Inuse += int(s.inuse)
+		stats.Garbage += int(s.garbage)
+	}
+	return stats
+}
+
+// Check checks the key existence.
+func (d *DiskStore) Check(hkey uint64) bool {
+	_, ok := d.index[hkey]
+	return ok
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+func (d *DiskStore) Range(f func(hkey uint64, e storage.Entry) bool) {
+	for hkey := range d.index {
+		e, err := d.Get(hkey)
+		if err != nil {
+			if d.log != nil {
+				d.log.Printf("[ERROR] Failed to read HKey: %d from %s: %v", hkey, d.dir, err)
+			}
+			continue
+		}
+		if !f(hkey, e) {
+			break
+		}
+	}
+}
+
+// RegexMatchOnKeys calls a regular expression on keys and provides an iterator.
+func (d *DiskStore) RegexMatchOnKeys(expr string, f func(hkey uint64, e storage.Entry) bool) error {
+	if len(d.index) == 0 {
+		// There is nothing to do
+		return nil
+	}
+
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+
+	d.Range(func(hkey uint64, e storage.Entry) bool {
+		if !r.MatchString(e.Key()) {
+			return true
+		}
+		return f(hkey, e)
+	})
+	return nil
+}
+
+func (d *DiskStore) orderedHKeys() []uint64 {
+	d.ordered.mtx.Lock()
+	defer d.ordered.mtx.Unlock()
+
+	if d.ordered.hkeys != nil && !d.ordered.stale {
+		return d.ordered.hkeys
+	}
+
+	hkeys := make([]uint64, 0, len(d.index))
+	for hkey := range d.index {
+		hkeys = append(hkeys, hkey)
+	}
+	sort.Slice(hkeys, func(i, j int) bool { return hkeys[i] < hkeys[j] })
+	// The readers may still use the previous slice, never modify it in place.
+	d.ordered.hkeys = hkeys
+	d.ordered.stale = false
+	return hkeys
+}
+
+// RangeFrom calls f on the entries in ascending HKey order, starting from the given HKey.
+func (d *DiskStore) RangeFrom(from uint64, f func(hkey uint64, e storage.Entry) bool) {
+	hkeys := d.orderedHKeys()
+	i := sort.Search(len(hkeys), func(i int) bool { return hkeys[i] >= from })
+	for ; i < len(hkeys); i++ {
+		e, err := d.Get(hkeys[i])
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			// The key has been deleted after the index was built.
+			continue
+		}
+		if err != nil {
+			if d.log != nil {
+				d.log.Printf("[ERROR] Failed to read HKey: %d from %s: %v", hkeys[i], d.dir, err)
+			}
+			continue
+		}
+		if !f(hkeys[i], e) {
+			break
+		}
+	}
+}
+
+// Close closes the segment files. The data stays on disk.
+func (d *DiskStore) Close() error {
+	var latestError error
+	for _, s := range d.segments {
+		if err := s.close(); err != nil {
+			latestError = err
+		}
+	}
+	return latestError
+}
+
+// Destroy deletes the directory of the engine instance with its content.
+func (d *DiskStore) Destroy() error {
+	if err := d.Close(); err != nil {
+		return err
+	}
+	d.index = make(map[uint64]*location)
+	d.segments = nil
+	if d.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(d.dir)
+}
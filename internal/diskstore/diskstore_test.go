@@ -0,0 +1,311 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/kvstore/entry"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/cespare/xxhash"
+	"github.com/stretchr/testify/require"
+)
+
+func bkey(i int) string {
+	return fmt.Sprintf("%09d", i)
+}
+
+func bval(i int) []byte {
+	return []byte(fmt.Sprintf("%025d", i))
+}
+
+func testConfig(t *testing.T) *storage.Config {
+	c := DefaultConfig()
+	c.Add("dataDir", t.TempDir())
+	c.Add("fragment", "test-fragment")
+	return c
+}
+
+func testDiskStore(c *storage.Config) (storage.Engine, error) {
+	d := &DiskStore{}
+	d.SetConfig(c)
+	child, err := d.Fork(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = child.Start()
+	if err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+func putEntries(t *testing.T, s storage.Engine, timestamp int64, count int) {
+	for i := 0; i < count; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetTTL(int64(i))
+		e.SetValue(bval(i))
+		e.SetTimestamp(timestamp)
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		err := s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+}
+
+func TestDiskStore_Get(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	timestamp := time.Now().UnixNano()
+	putEntries(t, s, timestamp, 100)
+
+	for i := 0; i < 100; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		e, err := s.Get(hkey)
+		require.NoError(t, err)
+
+		require.Equal(t, bkey(i), e.Key())
+		require.Equal(t, int64(i), e.TTL())
+		require.Equal(t, bval(i), e.Value())
+		require.Equal(t, timestamp, e.Timestamp())
+	}
+}
+
+func TestDiskStore_Put_KeyTooLarge(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	e := entry.New()
+	e.SetKey(string(make([]byte, maxKeyLen)))
+	err = s.Put(1, e)
+	require.ErrorIs(t, err, storage.ErrKeyTooLarge)
+}
+
+func TestDiskStore_Delete(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 100)
+
+	for i := 0; i < 100; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		require.NoError(t, s.Delete(hkey))
+
+		_, err := s.Get(hkey)
+		require.ErrorIs(t, err, storage.ErrKeyNotFound)
+	}
+
+	stats := s.Stats()
+	require.Equal(t, 0, stats.Length)
+	require.Equal(t, 0, stats.Inuse)
+	require.Equal(t, stats.Allocated, stats.Garbage)
+}
+
+func TestDiskStore_Reopen(t *testing.T) {
+	c := testConfig(t)
+	s, err := testDiskStore(c)
+	require.NoError(t, err)
+
+	timestamp := time.Now().UnixNano()
+	putEntries(t, s, timestamp, 100)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+	}
+	require.NoError(t, s.Close())
+
+	s, err = testDiskStore(c)
+	require.NoError(t, err)
+	require.Equal(t, 90, s.Stats().Length)
+
+	for i := 0; i < 100; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		e, err := s.Get(hkey)
+		if i < 10 {
+			require.ErrorIs(t, err, storage.ErrKeyNotFound)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, bval(i), e.Value())
+		require.Equal(t, timestamp, e.Timestamp())
+	}
+}
+
+func TestDiskStore_Reopen_CorruptedTail(t *testing.T) {
+	c := testConfig(t)
+	s, err := testDiskStore(c)
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 10)
+	d := s.(*DiskStore)
+	size := d.segments[0].size
+	require.NoError(t, d.segments[0].truncate(size-5))
+	require.NoError(t, s.Close())
+
+	s, err = testDiskStore(c)
+	require.NoError(t, err)
+	require.Equal(t, 9, s.Stats().Length)
+
+	// The segment accepts new records after the torn one is dropped.
+	putEntries(t, s, time.Now().UnixNano(), 10)
+	require.NoError(t, s.Close())
+
+	s, err = testDiskStore(c)
+	require.NoError(t, err)
+	require.Equal(t, 10, s.Stats().Length)
+}
+
+func TestDiskStore_UpdateTTL(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 100)
+
+	for i := 0; i < 100; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetTTL(10)
+		e.SetTimestamp(time.Now().UnixNano())
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		require.NoError(t, s.UpdateTTL(hkey, e))
+	}
+
+	for i := 0; i < 100; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		e, err := s.Get(hkey)
+		require.NoError(t, err)
+		require.Equal(t, int64(10), e.TTL())
+		require.Equal(t, bval(i), e.Value())
+	}
+
+	err = s.UpdateTTL(xxhash.Sum64([]byte("missing")), entry.New())
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+}
+
+func TestDiskStore_GetLastAccess(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 1)
+	hkey := xxhash.Sum64([]byte(bkey(0)))
+
+	before, err := s.GetLastAccess(hkey)
+	require.NoError(t, err)
+	<-time.After(time.Millisecond)
+
+	_, err = s.Get(hkey)
+	require.NoError(t, err)
+
+	after, err := s.GetLastAccess(hkey)
+	require.NoError(t, err)
+	require.Greater(t, after, before)
+}
+
+func TestDiskStore_RegexMatchOnKeys(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 100)
+
+	var keys []string
+	err = s.RegexMatchOnKeys("^00000001", func(hkey uint64, e storage.Entry) bool {
+		keys = append(keys, e.Key())
+		return true
+	})
+	require.NoError(t, err)
+	require.Len(t, keys, 10)
+}
+
+func TestDiskStore_ExportImport(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	timestamp := time.Now().UnixNano()
+	putEntries(t, s, timestamp, 100)
+
+	fresh, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	ti := s.TransferIterator()
+	for ti.Next() {
+		data, err := ti.Export()
+		require.NoError(t, err)
+		require.NoError(t, fresh.Import(data, func(u uint64, e storage.Entry) error {
+			return fresh.Put(u, e)
+		}))
+		require.NoError(t, ti.Pop())
+	}
+	require.Equal(t, 0, s.Stats().Length)
+
+	for i := 0; i < 100; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		e, err := fresh.Get(hkey)
+		require.NoError(t, err)
+		require.Equal(t, bval(i), e.Value())
+		require.Equal(t, timestamp, e.Timestamp())
+	}
+}
+
+func TestDiskStore_Fork(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("dataDir", t.TempDir())
+	s, err := testDiskStore(c)
+	require.NoError(t, err)
+
+	child, err := s.Fork(nil)
+	require.NoError(t, err)
+	require.NoError(t, child.Start())
+
+	// Instances without a fragment name get their own directory.
+	require.NotEqual(t, s.(*DiskStore).dir, child.(*DiskStore).dir)
+}
+
+func TestDiskStore_CloseDestroy(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 10)
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Destroy())
+
+	_, err = os.Stat(s.(*DiskStore).dir)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDiskStore_Start_Without_DataDir(t *testing.T) {
+	d := &DiskStore{}
+	d.SetConfig(DefaultConfig())
+	require.Error(t, d.Start())
+
+	_, err := d.Fork(nil)
+	require.Error(t, err)
+}
+
+func TestDiskStore_Destroy_Closes_Segments(t *testing.T) {
+	s, err := testDiskStore(testConfig(t))
+	require.NoError(t, err)
+
+	putEntries(t, s, time.Now().UnixNano(), 10)
+	segments := s.(*DiskStore).segments
+	require.NoError(t, s.Destroy())
+
+	for _, sg := range segments {
+		require.ErrorIs(t, sg.file.Close(), os.ErrClosed)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// On-disk layout for a record:
+//
+// OP(uint8) | HKEY(uint64) | CHECKSUM(uint32) | PAYLOAD-LENGTH(uint32) | PAYLOAD(bytes)
+//
+// CHECKSUM is the CRC32 (IEEE) of the payload. The payload of a put record is
+// an encoded entry. Delete records have no payload.
+const recordHeaderSize = 17
+
+const (
+	opPut = uint8(iota + 1)
+	opDelete
+)
+
+const segmentFileExt = ".seg"
+
+var errCorruptedRecord = errors.New("corrupted record")
+
+// segment is an append-only log file on disk.
+type segment struct {
+	id      uint64
+	file    *os.File
+	size    int64
+	inuse   int64
+	garbage int64
+
+	// compacted is the offset where the compaction continues.
+	compacted int64
+}
+
+func segmentFileName(id uint64) string {
+	return fmt.Sprintf("%020d%s", id, segmentFileExt)
+}
+
+func openSegment(dir string, id uint64) (*segment, error) {
+	f, err := os.OpenFile(filepath.Join(dir, segmentFileName(id)), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &segment{
+		id:   id,
+		file: f,
+		size: info.Size(),
+	}, nil
+}
+
+func encodeRecord(op uint8, hkey uint64, payload []byte) []byte {
+	buf := make([]byte, recordHeaderSize+len(payload))
+	buf[0] = op
+	binary.BigEndian.PutUint64(buf[1:], hkey)
+	binary.BigEndian.PutUint32(buf[9:], crc32.ChecksumIEEE(payload))
+	binary.BigEndian.PutUint32(buf[13:], uint32(len(payload)))
+	copy(buf[recordHeaderSize:], payload)
+	return buf
+}
+
+// append writes a new record at the end of the segment and returns its offset.
+func (s *segment) append(op uint8, hkey uint64, payload []byte, sync bool) (int64, error) {
+	offset := s.size
+	buf := encodeRecord(op, hkey, payload)
+	n, err := s.file.WriteAt(buf, offset)
+	if err != nil {
+		return 0, err
+	}
+	if sync {
+		if err = s.file.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	s.size += int64(n)
+	return offset, nil
+}
+
+// readPayload reads the payload of the record at the given offset.
+func (s *segment) readPayload(offset int64, length uint32) ([]byte, error) {
+	payload := make([]byte, length)
+	_, err := s.file.ReadAt(payload, offset+recordHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// scan reads the records sequentially, starting from the given offset, and calls
+// f for each of them. If f returns false, scan stops the iteration and returns the
+// offset of the next record. A torn or corrupted record at the end of the segment
+// is reported with errCorruptedRecord and the offset where the valid data ends.
+func (s *segment) scan(offset int64, f func(offset int64, op uint8, hkey uint64, payload []byte) bool) (int64, error) {
+	r := bufio.NewReader(io.NewSectionReader(s.file, offset, s.size-offset))
+	header := make([]byte, recordHeaderSize)
+
+	for offset < s.size {
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			return offset, errCorruptedRecord
+		}
+		op := header[0]
+		if op != opPut && op != opDelete {
+			return offset, errCorruptedRecord
+		}
+		hkey := binary.BigEndian.Uint64(header[1:])
+		checksum := binary.BigEndian.Uint32(header[9:])
+		length := binary.BigEndian.Uint32(header[13:])
+		if offset+recordHeaderSize+int64(length) > s.size {
+			return offset, errCorruptedRecord
+		}
+		payload := make([]byte, length)
+		if _, err = io.ReadFull(r, payload); err != nil {
+			return offset, errCorruptedRecord
+		}
+		if crc32.ChecksumIEEE(payload) != checksum {
+			return offset, errCorruptedRecord
+		}
+		next := offset + recordHeaderSize + int64(length)
+		if !f(offset, op, hkey, payload) {
+			return next, nil
+		}
+		offset = next
+	}
+	return offset, nil
+}
+
+// truncate drops everything after the given offset.
+func (s *segment) truncate(offset int64) error {
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	s.size = offset
+	return nil
+}
+
+func (s *segment) close() error {
+	err := s.file.Close()
+	if errors.Is(err, os.ErrClosed) {
+		// Destroy closes the segments again.
+		return nil
+	}
+	return err
+}
+
+// remove closes the segment and deletes its file from disk.
+func (s *segment) remove() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.file.Name())
+}
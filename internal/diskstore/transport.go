@@ -0,0 +1,133 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/buraksezer/olric/internal/kvstore/entry"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+type transferIterator struct {
+	storage *DiskStore
+}
+
+func (t *transferIterator) Next() bool {
+	return len(t.storage.segments) != 0
+}
+
+// Pop drops the oldest segment and the keys that are exported from it.
+func (t *transferIterator) Pop() error {
+	if len(t.storage.segments) == 0 {
+		return fmt.Errorf("there is no segment to pop")
+	}
+
+	s := t.storage.segments[0]
+	for hkey, loc := range t.storage.index {
+		if loc.segment == s {
+			delete(t.storage.index, hkey)
+		}
+	}
+	t.storage.segments = append(t.storage.segments[:0], t.storage.segments[1:]...)
+	return s.remove()
+}
+
+// Export encodes the live records of the oldest segment.
+func (t *transferIterator) Export() ([]byte, error) {
+	if len(t.storage.segments) == 0 {
+		return nil, io.EOF
+	}
+
+	s := t.storage.segments[0]
+	var buf bytes.Buffer
+	var exportErr error
+	_, err := s.scan(0, func(offset int64, op uint8, hkey uint64, payload []byte) bool {
+		if op != opPut {
+			return true
+		}
+		loc, ok := t.storage.index[hkey]
+		if !ok || loc.segment != s || loc.offset != offset {
+			// Stale record
+			return true
+		}
+		e := entry.New()
+		e.Decode(payload)
+		e.SetLastAccess(loc.lastAccess)
+		if _, exportErr = buf.Write(encodeRecord(opPut, hkey, e.Encode())); exportErr != nil {
+			return false
+		}
+		return true
+	})
+	if exportErr != nil {
+		return nil, exportErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Import decodes the records that are exported by a transferIterator. If the
+// engine has no keys, the records are inserted directly. Otherwise f is called
+// for every record to let the caller merge it.
+func (d *DiskStore) Import(data []byte, f func(uint64, storage.Entry) error) error {
+	empty := len(d.index) == 0
+	for len(data) != 0 {
+		if len(data) < recordHeaderSize {
+			return errCorruptedRecord
+		}
+		op := data[0]
+		hkey := binary.BigEndian.Uint64(data[1:])
+		checksum := binary.BigEndian.Uint32(data[9:])
+		length := binary.BigEndian.Uint32(data[13:])
+		if uint64(len(data)) < uint64(recordHeaderSize)+uint64(length) {
+			return errCorruptedRecord
+		}
+		payload := data[recordHeaderSize : recordHeaderSize+length]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			return errCorruptedRecord
+		}
+		data = data[recordHeaderSize+length:]
+		if op != opPut {
+			continue
+		}
+
+		if empty {
+			// DMap has no keys. Insert the records directly.
+			if err := d.PutRaw(hkey, payload); err != nil {
+				return err
+			}
+			continue
+		}
+
+		e := entry.New()
+		e.Decode(payload)
+		if err := f(hkey, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DiskStore) TransferIterator() storage.TransferIterator {
+	return &transferIterator{
+		storage: d,
+	}
+}
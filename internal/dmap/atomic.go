@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -24,7 +25,7 @@ import (
 )
 
 func (dm *DMap) loadCurrentAtomicInt(e *env) (int, error) {
-	entry, err := dm.get(e.key)
+	entry, err := dm.get(e.ctx, e.key)
 	if errors.Is(err, ErrKeyNotFound) {
 		err = nil
 	}
@@ -84,7 +85,13 @@ func (dm *DMap) atomicIncrDecr(opcode protocol.OpCode, e *env, delta int) (int,
 
 // Incr atomically increments key by delta. The return value is the new value after being incremented or an error.
 func (dm *DMap) Incr(key string, delta int) (int, error) {
+	return dm.IncrContext(context.Background(), key, delta)
+}
+
+// IncrContext is like Incr but the given context cancels the network operations.
+func (dm *DMap) IncrContext(ctx context.Context, key string, delta int) (int, error) {
 	e := &env{
+		ctx:           ctx,
 		opcode:        protocol.OpPut,
 		replicaOpcode: protocol.OpPutReplica,
 		dmap:          dm.name,
@@ -97,7 +104,13 @@ func (dm *DMap) Incr(key string, delta int) (int, error) {
 
 // Decr atomically decrements key by delta. The return value is the new value after being decremented or an error.
 func (dm *DMap) Decr(key string, delta int) (int, error) {
+	return dm.DecrContext(context.Background(), key, delta)
+}
+
+// DecrContext is like Decr but the given context cancels the network operations.
+func (dm *DMap) DecrContext(ctx context.Context, key string, delta int) (int, error) {
 	e := &env{
+		ctx:           ctx,
 		opcode:        protocol.OpPut,
 		replicaOpcode: protocol.OpPutReplica,
 		dmap:          dm.name,
@@ -118,7 +131,7 @@ func (dm *DMap) getPut(e *env) ([]byte, error) {
 		}
 	}()
 
-	entry, err := dm.get(e.key)
+	entry, err := dm.get(e.ctx, e.key)
 	if errors.Is(err, ErrKeyNotFound) {
 		err = nil
 	}
@@ -138,6 +151,11 @@ func (dm *DMap) getPut(e *env) ([]byte, error) {
 
 // GetPut atomically sets key to value and returns the old value stored at key.
 func (dm *DMap) GetPut(key string, value interface{}) (interface{}, error) {
+	return dm.GetPutContext(context.Background(), key, value)
+}
+
+// GetPutContext is like GetPut but the given context cancels the network operations.
+func (dm *DMap) GetPutContext(ctx context.Context, key string, value interface{}) (interface{}, error) {
 	if value == nil {
 		value = struct{}{}
 	}
@@ -146,6 +164,7 @@ func (dm *DMap) GetPut(key string, value interface{}) (interface{}, error) {
 		return nil, err
 	}
 	e := &env{
+		ctx:           ctx,
 		opcode:        protocol.OpPut,
 		replicaOpcode: protocol.OpPutReplica,
 		dmap:          dm.name,
@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"time"
@@ -49,6 +50,7 @@ func (s *Service) incrDecrOperation(w, r protocol.EncodeDecoder) {
 		return
 	}
 	e := &env{
+		ctx:           context.Background(),
 		opcode:        protocol.OpPut,
 		replicaOpcode: protocol.OpPutReplica,
 		dmap:          req.DMap(),
@@ -93,6 +95,7 @@ func (s *Service) getPutOperation(w, r protocol.EncodeDecoder) {
 	}
 
 	e := &env{
+		ctx:           context.Background(),
 		opcode:        protocol.OpPut,
 		replicaOpcode: protocol.OpPutReplica,
 		dmap:          req.DMap(),
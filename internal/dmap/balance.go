@@ -30,12 +30,16 @@ type fragmentPack struct {
 	Kind    partitions.Kind
 	Name    string
 	Payload []byte
+	// Pending is the write-behind queue of a primary fragment.
+	Pending []writeBehindEntry
 }
 
 func (dm *DMap) fragmentMergeFunction(f *fragment, hkey uint64, entry storage.Entry) error {
+	dm.s.clock.Update(entry.Timestamp())
+
 	current, err := f.storage.Get(hkey)
 	if errors.Is(err, storage.ErrKeyNotFound) {
-		return f.storage.Put(hkey, entry)
+		return f.put(hkey, entry)
 	}
 	if err != nil {
 		return err
@@ -48,7 +52,7 @@ func (dm *DMap) fragmentMergeFunction(f *fragment, hkey uint64, entry storage.En
 		// No need to insert the winner
 		return nil
 	}
-	return f.storage.Put(hkey, versions[0].entry)
+	return f.put(hkey, versions[0].entry)
 }
 
 func (dm *DMap) mergeFragments(part *partitions.Partition, fp *fragmentPack) error {
@@ -61,9 +65,22 @@ func (dm *DMap) mergeFragments(part *partitions.Partition, fp *fragmentPack) err
 	f.Lock()
 	defer f.Unlock()
 
-	return f.storage.Import(fp.Payload, func(hkey uint64, entry storage.Entry) error {
+	if len(fp.Pending) != 0 {
+		if f.writeBehind == nil {
+			return fmt.Errorf("write-behind mode is not enabled on DMap: %s", dm.name)
+		}
+		f.writeBehind.add(fp.Pending...)
+	}
+	if len(fp.Payload) == 0 {
+		return nil
+	}
+
+	err = f.storage.Import(fp.Payload, func(hkey uint64, entry storage.Entry) error {
 		return dm.fragmentMergeFunction(f, hkey, entry)
 	})
+	// The engine inserts the entries directly if it's empty.
+	f.countTombstones()
+	return err
 }
 
 func (s *Service) checkOwnership(part *partitions.Partition) bool {
@@ -84,6 +84,44 @@ func TestDMap_Balance_FragmentMergeFunction(t *testing.T) {
 	require.Equal(t, currentValue, winner.Value())
 }
 
+func TestDMap_Merge_Fragments_ClockSkew(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("mykey", "myval")
+	require.NoError(t, err)
+
+	hkey := partitions.HKey("mymap", "mykey")
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	require.NoError(t, err)
+
+	// The entry comes from a member whose clock is an hour ahead.
+	future := time.Now().Add(time.Hour).UnixNano()
+	e := dm.engine.NewEntry()
+	e.SetKey("mykey")
+	e.SetTimestamp(future)
+	e.SetValue([]byte("skewed-value"))
+
+	f.Lock()
+	err = dm.fragmentMergeFunction(f, hkey, e)
+	f.Unlock()
+	require.NoError(t, err)
+
+	// The next write must win, even though the wall clock of this member is behind.
+	err = dm.Put("mykey", "newval")
+	require.NoError(t, err)
+
+	entry, err := dm.GetEntry("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "newval", entry.Value)
+	require.Greater(t, entry.Timestamp, future)
+}
+
 func TestDMap_Balancer_JoinNewNode(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	db1 := cluster.AddMember(nil).(*Service)
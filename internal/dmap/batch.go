@@ -0,0 +1,215 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/vmihailenco/msgpack"
+)
+
+func newBatchResult(key string, value []byte, err error) protocol.BatchResult {
+	if err != nil {
+		return protocol.BatchResult{
+			Key:    key,
+			Status: neterrors.StatusCodeOf(err),
+			Value:  []byte(err.Error()),
+		}
+	}
+	return protocol.BatchResult{
+		Key:    key,
+		Status: protocol.StatusOK,
+		Value:  value,
+	}
+}
+
+func (dm *DMap) batchOnThisNode(ctx context.Context, op protocol.OpCode, item protocol.BatchEntry) protocol.BatchResult {
+	switch op {
+	case protocol.OpGet:
+		entry, err := dm.get(ctx, item.Key)
+		if err != nil {
+			return newBatchResult(item.Key, nil, err)
+		}
+		return newBatchResult(item.Key, entry.Encode(), nil)
+	case protocol.OpPut:
+		e := newEnv(ctx, protocol.OpPut, dm.name, item.Key, item.Value, nilTimeout, 0, partitions.PRIMARY)
+		return newBatchResult(item.Key, nil, dm.put(e))
+	case protocol.OpDelete:
+		return newBatchResult(item.Key, nil, dm.deleteKey(ctx, item.Key))
+	default:
+		err := neterrors.Wrap(neterrors.ErrInvalidArgument, fmt.Sprintf("invalid batch operation: %d", op))
+		return newBatchResult(item.Key, nil, err)
+	}
+}
+
+func (dm *DMap) batchOnOwner(ctx context.Context, owner discovery.Member, op protocol.OpCode, items []protocol.BatchEntry) []protocol.BatchResult {
+	results := make([]protocol.BatchResult, 0, len(items))
+	if owner.CompareByName(dm.s.rt.This()) {
+		for _, item := range items {
+			results = append(results, dm.batchOnThisNode(ctx, op, item))
+		}
+		return results
+	}
+
+	sendRequest := func() ([]protocol.BatchResult, error) {
+		value, err := msgpack.Marshal(items)
+		if err != nil {
+			return nil, err
+		}
+		req := protocol.NewDMapMessage(protocol.OpBatch)
+		req.SetDMap(dm.name)
+		req.SetValue(value)
+		req.SetExtra(protocol.BatchExtra{Op: op})
+		resp, err := dm.s.requestTo(ctx, owner.String(), req)
+		if err != nil {
+			return nil, err
+		}
+		var res []protocol.BatchResult
+		err = msgpack.Unmarshal(resp.Value(), &res)
+		return res, err
+	}
+
+	res, err := sendRequest()
+	if op != protocol.OpGet {
+		for _, item := range items {
+			dm.invalidateNearCache(item.Key)
+		}
+	}
+	if err != nil {
+		// The request has failed for all the keys on this owner.
+		for _, item := range items {
+			results = append(results, newBatchResult(item.Key, nil, err))
+		}
+		return results
+	}
+	return res
+}
+
+// batch groups the keys by their partition owners and runs the operation on
+// the owners in parallel, one request per owner.
+func (dm *DMap) batch(ctx context.Context, op protocol.OpCode, items []protocol.BatchEntry) []protocol.BatchResult {
+	groups := make(map[discovery.Member][]protocol.BatchEntry)
+	for _, item := range items {
+		hkey := dm.hkey(item.Key)
+		owner := dm.s.primary.PartitionByHKey(hkey).Owner()
+		groups[owner] = append(groups[owner], item)
+	}
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	results := make([]protocol.BatchResult, 0, len(items))
+	for owner, group := range groups {
+		wg.Add(1)
+		go func(owner discovery.Member, group []protocol.BatchEntry) {
+			defer wg.Done()
+			res := dm.batchOnOwner(ctx, owner, op, group)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			results = append(results, res...)
+		}(owner, group)
+	}
+	wg.Wait()
+	return results
+}
+
+func keysToBatchEntries(keys []string) []protocol.BatchEntry {
+	items := make([]protocol.BatchEntry, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, protocol.BatchEntry{Key: key})
+	}
+	return items
+}
+
+// MGet gets the values for the given keys. Keys are grouped by their partition
+// owners, and the owners are queried in parallel. It returns the found values
+// and the errors for the rest of the keys. ErrKeyNotFound is returned for the
+// missing keys. It's thread-safe.
+func (dm *DMap) MGet(keys []string) (map[string]interface{}, map[string]error) {
+	return dm.MGetContext(context.Background(), keys)
+}
+
+// MGetContext is like MGet but the given context cancels the network operations.
+func (dm *DMap) MGetContext(ctx context.Context, keys []string) (map[string]interface{}, map[string]error) {
+	values := make(map[string]interface{})
+	errs := make(map[string]error)
+	for _, res := range dm.batch(ctx, protocol.OpGet, keysToBatchEntries(keys)) {
+		if err := statusToError(res.Status, res.Value); err != nil {
+			errs[res.Key] = err
+			continue
+		}
+		entry := dm.engine.NewEntry()
+		entry.Decode(res.Value)
+		value, err := dm.unmarshalValue(entry.Value())
+		if err != nil {
+			errs[res.Key] = err
+			continue
+		}
+		values[res.Key] = value
+	}
+	return values, errs
+}
+
+// MPut sets the values for the given keys. Keys are grouped by their partition
+// owners, and the owners are called in parallel. It returns the errors for the
+// failed keys. It's thread-safe.
+func (dm *DMap) MPut(items map[string]interface{}) map[string]error {
+	return dm.MPutContext(context.Background(), items)
+}
+
+// MPutContext is like MPut but the given context cancels the network operations.
+func (dm *DMap) MPutContext(ctx context.Context, items map[string]interface{}) map[string]error {
+	errs := make(map[string]error)
+	entries := make([]protocol.BatchEntry, 0, len(items))
+	for key, value := range items {
+		data, err := dm.s.serializer.Marshal(value)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		entries = append(entries, protocol.BatchEntry{Key: key, Value: data})
+	}
+
+	for _, res := range dm.batch(ctx, protocol.OpPut, entries) {
+		if err := statusToError(res.Status, res.Value); err != nil {
+			errs[res.Key] = err
+		}
+	}
+	return errs
+}
+
+// MDelete deletes the given keys. Keys are grouped by their partition owners,
+// and the owners are called in parallel. It returns the errors for the failed
+// keys. It's thread-safe.
+func (dm *DMap) MDelete(keys []string) map[string]error {
+	return dm.MDeleteContext(context.Background(), keys)
+}
+
+// MDeleteContext is like MDelete but the given context cancels the network operations.
+func (dm *DMap) MDeleteContext(ctx context.Context, keys []string) map[string]error {
+	errs := make(map[string]error)
+	for _, res := range dm.batch(ctx, protocol.OpDelete, keysToBatchEntries(keys)) {
+		if err := statusToError(res.Status, res.Value); err != nil {
+			errs[res.Key] = err
+		}
+	}
+	return errs
+}
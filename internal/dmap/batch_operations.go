@@ -0,0 +1,53 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/vmihailenco/msgpack"
+)
+
+func (s *Service) batchOperation(w, r protocol.EncodeDecoder) {
+	req := r.(*protocol.DMapMessage)
+	extra, ok := req.Extra().(protocol.BatchExtra)
+	if !ok {
+		neterrors.ErrorResponse(w, neterrors.Wrap(neterrors.ErrInvalidArgument, "batch operation is missing"))
+		return
+	}
+
+	dm, err := s.getOrCreateDMap(req.DMap())
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+
+	var items []protocol.BatchEntry
+	err = msgpack.Unmarshal(req.Value(), &items)
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+
+	value, err := msgpack.Marshal(dm.batch(context.Background(), extra.Op, items))
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+	w.SetStatus(protocol.StatusOK)
+	w.SetValue(value)
+}
@@ -0,0 +1,113 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_MPut_MGet(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	cluster.AddMember(nil)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	items := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		items[testutil.ToKey(i)] = testutil.ToVal(i)
+	}
+	errs := dm1.MPut(items)
+	require.Len(t, errs, 0)
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 110; i++ {
+		keys = append(keys, testutil.ToKey(i))
+	}
+	values, errs := dm2.MGet(keys)
+	require.Len(t, values, 100)
+	require.Len(t, errs, 10)
+	for i := 0; i < 100; i++ {
+		require.Equal(t, testutil.ToVal(i), values[testutil.ToKey(i)])
+	}
+	for i := 100; i < 110; i++ {
+		require.ErrorIs(t, errs[testutil.ToKey(i)], ErrKeyNotFound)
+	}
+
+	// The keys are also accessible one by one.
+	for i := 0; i < 100; i++ {
+		value, err := dm2.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+}
+
+func TestDMap_MDelete(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	cluster.AddMember(nil)
+	defer cluster.Shutdown()
+
+	dm, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+		keys = append(keys, testutil.ToKey(i))
+	}
+
+	errs := dm.MDelete(keys[:50])
+	require.Len(t, errs, 0)
+
+	values, errs := dm.MGet(keys)
+	require.Len(t, values, 50)
+	require.Len(t, errs, 50)
+	for i := 0; i < 50; i++ {
+		require.ErrorIs(t, errs[testutil.ToKey(i)], ErrKeyNotFound)
+	}
+}
+
+func TestDMap_MPut_KeyTooLarge(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	large := string(make([]byte, 300))
+	errs := dm.MPut(map[string]interface{}{
+		"mykey": "myvalue",
+		large:   "myvalue",
+	})
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[large], ErrKeyTooLarge)
+
+	value, err := dm.Get("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+}
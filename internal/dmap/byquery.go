@@ -0,0 +1,260 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/buraksezer/olric/query"
+	"github.com/vmihailenco/msgpack"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// validateMutationQuery validates a query of DeleteByQuery and ExpireByQuery.
+// All the matching keys are affected, so $aggregate and $limit are not supported.
+func validateMutationQuery(q query.M) error {
+	if err := query.Validate(q); err != nil {
+		return err
+	}
+	if _, ok := q["$aggregate"]; ok {
+		return fmt.Errorf("%w: $aggregate cannot be used to modify the keys", query.ErrInvalidQuery)
+	}
+	if _, ok := q["$limit"]; ok {
+		return fmt.Errorf("%w: $limit cannot be used to modify the keys", query.ErrInvalidQuery)
+	}
+	return nil
+}
+
+// mutateOnPartition runs the query on the owners of the partition and calls f
+// for each matching entry. The entries may only exist on the previous owners.
+// It's called on the primary owner of the partition and returns the number of
+// the keys that f has modified.
+func (dm *DMap) mutateOnPartition(ctx context.Context, partID uint64, q query.M,
+	f func(hkey uint64, entry storage.Entry) (bool, error)) (int, error) {
+	owner := dm.s.primary.PartitionByID(partID).Owner()
+	if !owner.CompareByID(dm.s.rt.This()) {
+		return 0, fmt.Errorf("partition: %d is owned by %s", partID, owner)
+	}
+
+	c, err := dm.QueryContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	entries, err := c.runQueryOnOwners(partID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, entry := range entries {
+		ok, err := f(dm.hkey(entry.Key()), entry)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (dm *DMap) localDeleteByQuery(ctx context.Context, partID uint64, q query.M) (int, error) {
+	return dm.mutateOnPartition(ctx, partID, q, func(hkey uint64, entry storage.Entry) (bool, error) {
+		deleted, err := dm.deleteOnPrimary(ctx, hkey, entry.Key())
+		if err != nil {
+			return false, err
+		}
+		// The key is missing on this member but the previous owners have it.
+		// deleteOnPrimary has deleted it there.
+		return deleted || len(dm.s.primary.PartitionOwnersByID(partID)) > 1, nil
+	})
+}
+
+func (dm *DMap) localExpireByQuery(ctx context.Context, partID uint64, q query.M, timeout time.Duration) (int, error) {
+	return dm.mutateOnPartition(ctx, partID, q, func(hkey uint64, entry storage.Entry) (bool, error) {
+		e := &env{
+			ctx:     ctx,
+			dmap:    dm.name,
+			key:     entry.Key(),
+			hkey:    hkey,
+			timeout: timeout,
+		}
+		err := dm.expireQueriedEntry(e, entry)
+		if errors.Is(err, ErrKeyNotFound) {
+			// The key has been deleted in the meantime.
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// expireQueriedEntry is like callExpireOnCluster but it copies the entry to this
+// member first, if it only exists on the previous owners.
+func (dm *DMap) expireQueriedEntry(e *env, entry storage.Entry) error {
+	part := dm.getPartitionByHKey(e.hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return err
+	}
+	e.fragment = f
+	f.Lock()
+	defer f.Unlock()
+
+	current, err := f.storage.Get(e.hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) || (err == nil && current.Timestamp() < entry.Timestamp()) {
+		// Like readRepair, the version of the previous owner is copied as is.
+		tmp := f.storage.NewEntry()
+		tmp.SetKey(entry.Key())
+		tmp.SetValue(entry.Value())
+		tmp.SetTTL(entry.TTL())
+		tmp.SetTimestamp(entry.Timestamp())
+		err = f.put(e.hkey, tmp)
+	}
+	if err != nil {
+		return err
+	}
+
+	// An expired, idle or deleted key cannot be revived by updating its TTL.
+	if _, err = dm.liveTTL(f, e.hkey); err != nil {
+		return err
+	}
+	return dm.expireOnLockedFragment(e)
+}
+
+// mutateOnCluster calls local on the partitions that are owned by this member
+// and sends the request to the owners of the other partitions. It returns the
+// total number of the modified keys.
+func (dm *DMap) mutateOnCluster(ctx context.Context, q query.M,
+	newReq func(partID uint64) *protocol.DMapMessage,
+	local func(partID uint64) (int, error)) (int, error) {
+	value, err := msgpack.Marshal(q)
+	if err != nil {
+		return 0, err
+	}
+	// The keys may be cached by this member.
+	defer dm.purgeNearCache()
+
+	var count int64
+	sem := semaphore.NewWeighted(NumConcurrentWorkers)
+	g, gctx := errgroup.WithContext(ctx)
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		if err := sem.Acquire(gctx, 1); err != nil {
+			break
+		}
+
+		id := partID
+		g.Go(func() error {
+			defer sem.Release(1)
+
+			owner := dm.s.primary.PartitionByID(id).Owner()
+			if owner.CompareByID(dm.s.rt.This()) {
+				n, err := local(id)
+				atomic.AddInt64(&count, int64(n))
+				return err
+			}
+
+			req := newReq(id)
+			req.SetDMap(dm.name)
+			req.SetValue(value)
+			resp, err := dm.s.requestTo(gctx, owner.String(), req)
+			if err != nil {
+				return err
+			}
+			var n int
+			if len(resp.Value()) != 0 {
+				if err = msgpack.Unmarshal(resp.Value(), &n); err != nil {
+					return err
+				}
+			}
+			atomic.AddInt64(&count, int64(n))
+			return nil
+		})
+	}
+	err = g.Wait()
+	if err == nil {
+		// Acquire fails only if the context is done.
+		err = ctx.Err()
+	}
+	return int(atomic.LoadInt64(&count)), err
+}
+
+func (dm *DMap) purgeNearCache() {
+	if dm.nearCache != nil {
+		dm.nearCache.Purge()
+	}
+}
+
+// DeleteByQuery deletes the keys that match the given query and returns the number
+// of the deleted keys. The keys are deleted on the partition owners and the deletions
+// are replicated to the backups, like Delete. $aggregate and $limit are not supported.
+//
+// The deletions are not atomic. If it returns an error, some of the matching keys may
+// already be deleted.
+func (dm *DMap) DeleteByQuery(q query.M) (int, error) {
+	return dm.DeleteByQueryContext(context.Background(), q)
+}
+
+// DeleteByQueryContext is like DeleteByQuery but the given context cancels the network operations.
+func (dm *DMap) DeleteByQueryContext(ctx context.Context, q query.M) (int, error) {
+	if err := validateMutationQuery(q); err != nil {
+		return 0, err
+	}
+	return dm.mutateOnCluster(ctx, q,
+		func(partID uint64) *protocol.DMapMessage {
+			req := protocol.NewDMapMessage(protocol.OpLocalDeleteByQuery)
+			req.SetExtra(protocol.LocalQueryExtra{PartID: partID})
+			return req
+		},
+		func(partID uint64) (int, error) {
+			return dm.localDeleteByQuery(ctx, partID, q)
+		})
+}
+
+// ExpireByQuery updates the expiry of the keys that match the given query and returns
+// the number of the updated keys. The keys are updated on the partition owners and the
+// updates are replicated to the backups, like Expire. $aggregate and $limit are not
+// supported.
+func (dm *DMap) ExpireByQuery(q query.M, timeout time.Duration) (int, error) {
+	return dm.ExpireByQueryContext(context.Background(), q, timeout)
+}
+
+// ExpireByQueryContext is like ExpireByQuery but the given context cancels the network operations.
+func (dm *DMap) ExpireByQueryContext(ctx context.Context, q query.M, timeout time.Duration) (int, error) {
+	if err := validateMutationQuery(q); err != nil {
+		return 0, err
+	}
+	return dm.mutateOnCluster(ctx, q,
+		func(partID uint64) *protocol.DMapMessage {
+			req := protocol.NewDMapMessage(protocol.OpLocalExpireByQuery)
+			req.SetExtra(protocol.LocalExpireByQueryExtra{
+				PartID: partID,
+				TTL:    timeout.Nanoseconds(),
+			})
+			return req
+		},
+		func(partID uint64) (int, error) {
+			return dm.localExpireByQuery(ctx, partID, q, timeout)
+		})
+}
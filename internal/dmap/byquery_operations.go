@@ -0,0 +1,57 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/query"
+)
+
+func (s *Service) deleteByQueryOperation(w, r protocol.EncodeDecoder) {
+	s.queryOperationCommon(w, r,
+		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
+			return dm.DeleteByQuery(q)
+		})
+}
+
+func (s *Service) localDeleteByQueryOperation(w, r protocol.EncodeDecoder) {
+	s.queryOperationCommon(w, r,
+		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
+			req := r.(*protocol.DMapMessage)
+			partID := req.Extra().(protocol.LocalQueryExtra).PartID
+			return dm.localDeleteByQuery(context.Background(), partID, q)
+		})
+}
+
+func (s *Service) expireByQueryOperation(w, r protocol.EncodeDecoder) {
+	s.queryOperationCommon(w, r,
+		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
+			req := r.(*protocol.DMapMessage)
+			ttl := req.Extra().(protocol.ExpireByQueryExtra).TTL
+			return dm.ExpireByQuery(q, time.Duration(ttl))
+		})
+}
+
+func (s *Service) localExpireByQueryOperation(w, r protocol.EncodeDecoder) {
+	s.queryOperationCommon(w, r,
+		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
+			req := r.(*protocol.DMapMessage)
+			extra := req.Extra().(protocol.LocalExpireByQueryExtra)
+			return dm.localExpireByQuery(context.Background(), extra.PartID, q, time.Duration(extra.TTL))
+		})
+}
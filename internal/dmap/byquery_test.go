@@ -0,0 +1,199 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/buraksezer/olric/query"
+	"github.com/stretchr/testify/require"
+)
+
+func newByQueryCluster(t *testing.T) (*testcluster.TestCluster, *DMap, *DMap) {
+	cluster := testcluster.New(NewService)
+
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		var key string
+		if i%2 == 0 {
+			key = fmt.Sprintf("even:%d", i)
+		} else {
+			key = fmt.Sprintf("odd:%d", i)
+		}
+		require.NoError(t, dm1.Put(key, i))
+	}
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+	return cluster, dm1, dm2
+}
+
+func TestDMap_DeleteByQuery(t *testing.T) {
+	cluster, dm1, dm2 := newByQueryCluster(t)
+	defer cluster.Shutdown()
+
+	count, err := dm2.DeleteByQuery(query.M{
+		"$onKey": query.M{
+			"$regexMatch": "^even:",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 50, count)
+
+	for i := 0; i < 100; i++ {
+		if i%2 != 0 {
+			_, err = dm1.Get(fmt.Sprintf("odd:%d", i))
+			require.NoError(t, err)
+			continue
+		}
+
+		key := fmt.Sprintf("even:%d", i)
+		_, err = dm1.Get(key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+
+		// The deletion has been replicated to the backup.
+		for _, dm := range []*DMap{dm1, dm2} {
+			hkey := dm.hkey(key)
+			f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.BACKUP))
+			if errors.Is(err, errFragmentNotFound) {
+				continue
+			}
+			require.NoError(t, err)
+
+			f.RLock()
+			entry, err := f.storage.Get(hkey)
+			f.RUnlock()
+			if !errors.Is(err, storage.ErrKeyNotFound) {
+				require.NoError(t, err)
+				require.True(t, isTombstone(entry))
+			}
+		}
+	}
+
+	// There is nothing to delete anymore.
+	count, err = dm1.DeleteByQuery(query.M{
+		"$onKey": query.M{
+			"$regexMatch": "^even:",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestDMap_ExpireByQuery(t *testing.T) {
+	cluster, dm1, dm2 := newByQueryCluster(t)
+	defer cluster.Shutdown()
+
+	count, err := dm2.ExpireByQuery(query.M{
+		"$onValue": query.M{
+			"$gt": 49,
+		},
+	}, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, 50, count)
+
+	<-time.After(10 * time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("odd:%d", i)
+		if i%2 == 0 {
+			key = fmt.Sprintf("even:%d", i)
+		}
+		_, err = dm1.Get(key)
+		if i > 49 {
+			require.ErrorIs(t, err, ErrKeyNotFound)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestDMap_ByQuery_PreviousOwner(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	require.NoError(t, dm1.Put("mykey-1", 1))
+	require.NoError(t, dm1.Put("mykey-2", 2))
+
+	// The keys only exist on the previous owners.
+	for _, key := range []string{"mykey-1", "mykey-2"} {
+		previous, current := ownerOf(key, s1, s2)
+		setPreviousOwner(partitions.HKey("mymap", key), previous, current, s1, s2)
+	}
+
+	count, err := dm1.ExpireByQuery(query.M{
+		"$onKey": query.M{"$regexMatch": "^mykey-1$"},
+	}, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	count, err = dm1.DeleteByQuery(query.M{
+		"$onKey": query.M{"$regexMatch": "^mykey-2$"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	<-time.After(10 * time.Millisecond)
+
+	for _, key := range []string{"mykey-1", "mykey-2"} {
+		_, err = dm1.Get(key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_DeleteByQuery_InvalidQuery(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	_, err = dm.DeleteByQuery(query.M{
+		"$onKey": query.M{
+			"$regexMatch": "",
+		},
+		"$limit": 10,
+	})
+	require.ErrorIs(t, err, query.ErrInvalidQuery)
+
+	_, err = dm.ExpireByQuery(query.M{
+		"$onKey": query.M{
+			"$regexMatch": "",
+		},
+		"$aggregate": query.M{"$count": true},
+	}, time.Second)
+	require.ErrorIs(t, err, query.ErrInvalidQuery)
+}
@@ -0,0 +1,77 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrVersionMismatch is returned by CompareAndSwap when the stored version of
+// the key is different from the expected one.
+var ErrVersionMismatch = neterrors.New(protocol.StatusErrVersionMismatch, "version mismatch")
+
+// versionOnFragment returns the current version of the key on the fragment. It
+// returns zero if the key doesn't exist. It's not thread-safe.
+func versionOnFragment(f *fragment, hkey uint64) (int64, error) {
+	entry, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if isTombstone(entry) || isKeyExpired(entry.TTL()) {
+		return 0, nil
+	}
+	return entry.Timestamp(), nil
+}
+
+// checkVersion compares the current version of the key with the expected one.
+// It's not thread-safe.
+func (dm *DMap) checkVersion(e *env) error {
+	current, err := versionOnFragment(e.fragment, e.hkey)
+	if err != nil {
+		return err
+	}
+	if current != e.version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// CompareAndSwap sets the value for the given key, if the current version of
+// the key is still the expected one. The version of an entry is returned by
+// GetEntry. Zero version means that the key must not exist. It returns
+// ErrVersionMismatch if the key has been modified since the version was read.
+// It's thread-safe.
+func (dm *DMap) CompareAndSwap(key string, version int64, value interface{}) error {
+	return dm.CompareAndSwapContext(context.Background(), key, version, value)
+}
+
+// CompareAndSwapContext is like CompareAndSwap but the given context cancels the
+// network operations.
+func (dm *DMap) CompareAndSwapContext(ctx context.Context, key string, version int64, value interface{}) error {
+	e, err := dm.prepareAndSerialize(ctx, protocol.OpCompareAndSwap, key, value, nilTimeout, 0)
+	if err != nil {
+		return err
+	}
+	e.version = version
+	return dm.put(e)
+}
@@ -0,0 +1,115 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_CompareAndSwap(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("mykey", "value-1")
+	require.NoError(t, err)
+
+	entry, err := dm.GetEntry("mykey")
+	require.NoError(t, err)
+	require.NotZero(t, entry.Version)
+
+	err = dm.CompareAndSwap("mykey", entry.Version, "value-2")
+	require.NoError(t, err)
+
+	// The version has changed with the previous call.
+	err = dm.CompareAndSwap("mykey", entry.Version, "value-3")
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	value, err := dm.Get("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "value-2", value)
+}
+
+func TestDMap_CompareAndSwap_KeyNotFound(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.CompareAndSwap("mykey", 1, "value")
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	// Zero version means that the key must not exist.
+	err = dm.CompareAndSwap("mykey", 0, "value")
+	require.NoError(t, err)
+
+	err = dm.CompareAndSwap("mykey", 0, "value")
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	err = dm.Delete("mykey")
+	require.NoError(t, err)
+
+	err = dm.CompareAndSwap("mykey", 0, "value")
+	require.NoError(t, err)
+}
+
+func TestDMap_CompareAndSwap_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		entry, err := dm2.GetEntry(testutil.ToKey(i))
+		require.NoError(t, err)
+
+		err = dm2.CompareAndSwap(testutil.ToKey(i), entry.Version, i*2)
+		require.NoError(t, err)
+
+		err = dm1.CompareAndSwap(testutil.ToKey(i), entry.Version, i*3)
+		require.ErrorIs(t, err, ErrVersionMismatch)
+	}
+
+	for i := 0; i < 10; i++ {
+		value, err := dm1.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, i*2, value)
+	}
+}
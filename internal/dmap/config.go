@@ -9,13 +9,21 @@ import (
 
 // dmapConfig keeps DMap config control parameters and access-log for keys in a dmap.
 type dmapConfig struct {
-	engine          *config.Engine
-	maxIdleDuration time.Duration
-	ttlDuration     time.Duration
-	maxKeys         int
-	maxInuse        int
-	lruSamples      int
-	evictionPolicy  config.EvictionPolicy
+	engine                *config.Engine
+	maxIdleDuration       time.Duration
+	ttlDuration           time.Duration
+	maxKeys               int
+	maxInuse              int
+	lruSamples            int
+	lfuDecayTime          time.Duration
+	evictionPolicy        config.EvictionPolicy
+	hashTags              bool
+	keyspaceNotifications bool
+	nearCache             *config.NearCache
+	loader                config.MapLoader
+	store                 config.MapStore
+	writeDelay            time.Duration
+	writeBatchSize        int
 }
 
 func (c *dmapConfig) load(dc *config.DMaps, name string) error {
@@ -25,8 +33,12 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 	c.maxKeys = dc.MaxKeys
 	c.maxInuse = dc.MaxInuse
 	c.lruSamples = dc.LRUSamples
+	c.lfuDecayTime = dc.LFUDecayTime
 	c.evictionPolicy = dc.EvictionPolicy
 	c.engine = dc.Engine
+	c.hashTags = dc.HashTags
+	c.keyspaceNotifications = dc.KeyspaceNotifications
+	c.nearCache = dc.NearCache
 
 	if dc.Custom != nil {
 		// config.DMap struct can be used for fine-grained control.
@@ -50,17 +62,42 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 			if c.lruSamples != cs.LRUSamples {
 				c.lruSamples = cs.LRUSamples
 			}
+			if c.lfuDecayTime != cs.LFUDecayTime {
+				c.lfuDecayTime = cs.LFUDecayTime
+			}
 			if c.evictionPolicy != cs.EvictionPolicy {
 				c.evictionPolicy = cs.EvictionPolicy
 			}
 			if c.engine == nil {
 				c.engine = cs.Engine
 			}
+			if c.hashTags != cs.HashTags {
+				c.hashTags = cs.HashTags
+			}
+			if c.keyspaceNotifications != cs.KeyspaceNotifications {
+				c.keyspaceNotifications = cs.KeyspaceNotifications
+			}
+			if cs.NearCache != nil {
+				c.nearCache = cs.NearCache
+			}
+			c.loader = cs.Loader
+			c.store = cs.Store
+			c.writeDelay = cs.WriteDelay
+			c.writeBatchSize = cs.WriteBatchSize
+		}
+	}
+
+	if c.writeDelay > 0 {
+		if c.store == nil {
+			return fmt.Errorf("write-behind mode requires a MapStore")
+		}
+		if c.writeBatchSize <= 0 {
+			c.writeBatchSize = config.DefaultWriteBatchSize
 		}
 	}
 
 	// TODO: Create a new function to verify config config.
-	if c.evictionPolicy == config.LRUEviction {
+	if c.evictionPolicy == config.LRUEviction || c.evictionPolicy == config.LFUEviction {
 		if c.maxInuse <= 0 && c.maxKeys <= 0 {
 			return fmt.Errorf("maxInuse or maxKeys have to be greater than zero")
 		}
@@ -68,6 +105,9 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 		if c.lruSamples == 0 {
 			c.lruSamples = config.DefaultLRUSamples
 		}
+		if c.lfuDecayTime == 0 {
+			c.lfuDecayTime = config.DefaultLFUDecayTime
+		}
 	}
 	return nil
 }
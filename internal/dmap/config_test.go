@@ -31,6 +31,7 @@ func TestDMap_Config(t *testing.T) {
 	c.DMaps.MaxKeys = 100000
 	c.DMaps.MaxInuse = 1000000
 	c.DMaps.LRUSamples = 10
+	c.DMaps.LFUDecayTime = 30 * time.Second
 	c.DMaps.EvictionPolicy = config.LRUEviction
 	c.DMaps.Engine = testutil.NewEngineConfig(t)
 
@@ -66,6 +67,9 @@ func TestDMap_Config(t *testing.T) {
 	if dc.lruSamples != c.DMaps.LRUSamples {
 		t.Fatalf("Expected LRUSamples: %v. Got: %v", c.DMaps.LRUSamples, dc.lruSamples)
 	}
+	if dc.lfuDecayTime != c.DMaps.LFUDecayTime {
+		t.Fatalf("Expected LFUDecayTime: %v. Got: %v", c.DMaps.LFUDecayTime, dc.lfuDecayTime)
+	}
 	if dc.evictionPolicy != c.DMaps.EvictionPolicy {
 		t.Fatalf("Expected EvictionPolicy: %v. Got: %v", c.DMaps.EvictionPolicy, dc.evictionPolicy)
 	}
@@ -15,12 +15,15 @@
 package dmap
 
 import (
+	"context"
 	"errors"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -32,8 +35,8 @@ var (
 	DeleteMisses = stats.NewInt64Counter()
 )
 
-func (dm *DMap) deleteBackupFromFragment(key string, kind partitions.Kind) error {
-	hkey := partitions.HKey(dm.name, key)
+func (dm *DMap) deleteBackupFromFragment(key string, kind partitions.Kind, timestamp int64) error {
+	hkey := dm.hkey(key)
 	part := dm.getPartitionByHKey(hkey, kind)
 	f, err := dm.loadFragment(part)
 	if errors.Is(err, errFragmentNotFound) {
@@ -47,17 +50,23 @@ func (dm *DMap) deleteBackupFromFragment(key string, kind partitions.Kind) error
 	f.Lock()
 	defer f.Unlock()
 
-	return f.storage.Delete(hkey)
+	if timestamp == 0 {
+		// The request has no timestamp, there is nothing to reconcile.
+		return f.delete(hkey)
+	}
+	dm.s.clock.Update(timestamp)
+	return dm.putTombstone(f, hkey, key, timestamp)
 }
 
-func (dm *DMap) deleteFromPreviousOwners(key string, owners []discovery.Member) error {
+func (dm *DMap) deleteFromPreviousOwners(ctx context.Context, key string, timestamp int64, owners []discovery.Member) error {
 	// Traverse in reverse order. Except from the latest host, this one.
 	for i := len(owners) - 2; i >= 0; i-- {
 		owner := owners[i]
 		req := protocol.NewDMapMessage(protocol.OpDeletePrev)
 		req.SetDMap(dm.name)
 		req.SetKey(key)
-		_, err := dm.s.requestTo(owner.String(), req)
+		req.SetExtra(protocol.DeleteExtra{Timestamp: timestamp})
+		_, err := dm.s.requestTo(ctx, owner.String(), req)
 		if err != nil {
 			return err
 		}
@@ -65,7 +74,7 @@ func (dm *DMap) deleteFromPreviousOwners(key string, owners []discovery.Member)
 	return nil
 }
 
-func (dm *DMap) deleteBackupOnCluster(hkey uint64, key string) error {
+func (dm *DMap) deleteBackupOnCluster(ctx context.Context, hkey uint64, key string, timestamp int64) error {
 	owners := dm.s.backup.PartitionOwnersByHKey(hkey)
 	var g errgroup.Group
 	for _, owner := range owners {
@@ -75,7 +84,8 @@ func (dm *DMap) deleteBackupOnCluster(hkey uint64, key string) error {
 			req := protocol.NewDMapMessage(protocol.OpDeleteReplica)
 			req.SetDMap(dm.name)
 			req.SetKey(key)
-			_, err := dm.s.requestTo(mem.String(), req)
+			req.SetExtra(protocol.DeleteExtra{Timestamp: timestamp})
+			_, err := dm.s.requestTo(ctx, mem.String(), req)
 			if err != nil {
 				dm.s.log.V(3).Printf("[ERROR] Failed to delete replica key/value on %s: %s", dm.name, err)
 			}
@@ -85,68 +95,115 @@ func (dm *DMap) deleteBackupOnCluster(hkey uint64, key string) error {
 	return g.Wait()
 }
 
-// deleteOnCluster is not a thread-safe function
-func (dm *DMap) deleteOnCluster(hkey uint64, key string, f *fragment) error {
+// deleteOnCluster is not a thread-safe function. It leaves tombstones behind if
+// timestamp is non-zero. Evictions pass zero, evicted keys have to free up space.
+func (dm *DMap) deleteOnCluster(ctx context.Context, hkey uint64, key string, f *fragment, timestamp int64) error {
+	if err := dm.propagateDelete(ctx, hkey, key, f, timestamp); err != nil {
+		return err
+	}
+
+	// DeleteHits is the number of deletion reqs resulting in an item being removed.
+	DeleteHits.Increase(1)
+
+	return nil
+}
+
+// propagateDelete deletes the key on the previous owners, the backups and the
+// given fragment. It's not thread-safe.
+func (dm *DMap) propagateDelete(ctx context.Context, hkey uint64, key string, f *fragment, timestamp int64) error {
 	owners := dm.s.primary.PartitionOwnersByHKey(hkey)
 	if len(owners) == 0 {
 		panic("partition owners list cannot be empty")
 	}
 
-	err := dm.deleteFromPreviousOwners(key, owners)
+	err := dm.deleteFromPreviousOwners(ctx, key, timestamp, owners)
 	if err != nil {
 		return err
 	}
 
 	if dm.s.config.ReplicaCount != 0 {
-		err := dm.deleteBackupOnCluster(hkey, key)
+		err := dm.deleteBackupOnCluster(ctx, hkey, key, timestamp)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = f.storage.Delete(hkey)
-	if err != nil {
-		return err
+	if timestamp == 0 {
+		return f.delete(hkey)
 	}
-
-	// DeleteHits is the number of deletion reqs resulting in an item being removed.
-	DeleteHits.Increase(1)
-
-	return nil
+	return dm.putTombstone(f, hkey, key, timestamp)
 }
 
-func (dm *DMap) deleteKey(key string) error {
-	hkey := partitions.HKey(dm.name, key)
+func (dm *DMap) deleteKey(ctx context.Context, key string) error {
+	hkey := dm.hkey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	if !member.CompareByName(dm.s.rt.This()) {
+		defer dm.invalidateNearCache(key)
 		req := protocol.NewDMapMessage(protocol.OpDelete)
 		req.SetDMap(dm.name)
 		req.SetKey(key)
-		_, err := dm.s.requestTo(member.String(), req)
+		_, err := dm.s.requestTo(ctx, member.String(), req)
 		return err
 	}
 
+	_, err := dm.deleteOnPrimary(ctx, hkey, key)
+	return err
+}
+
+// deleteOnPrimary deletes the key on this member, the partition owner. It returns
+// false if the key doesn't exist.
+func (dm *DMap) deleteOnPrimary(ctx context.Context, hkey uint64, key string) (bool, error) {
 	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
 	f, err := dm.loadOrCreateFragment(part)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	f.Lock()
 	defer f.Unlock()
 
+	// The key may only exist in the backing store.
+	if err = dm.deleteOnMapStore(ctx, f, key); err != nil {
+		return false, err
+	}
+
+	// Tombstones carry the time of deletion. It's compared with the timestamps
+	// of the other versions.
+	timestamp := dm.s.clock.Now()
+
 	// Check the HKey before trying to delete it.
-	if !f.storage.Check(hkey) {
+	entry, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) || (err == nil && isTombstone(entry)) {
 		// DeleteMisses is the number of deletions reqs for missing keys
 		DeleteMisses.Increase(1)
-		return nil
+
+		// The key may still exist on the previous owners or the backups. The
+		// tombstone hides their versions.
+		owners := dm.s.primary.PartitionOwnersByHKey(hkey)
+		if len(owners) > 1 || dm.s.config.ReplicaCount > config.MinimumReplicaCount {
+			return false, dm.propagateDelete(ctx, hkey, key, f, timestamp)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
 
-	return dm.deleteOnCluster(hkey, key, f)
+	if err = dm.deleteOnCluster(ctx, hkey, key, f, timestamp); err != nil {
+		return false, err
+	}
+
+	dm.notifyKeyspace(KeyspaceDelete, key, timestamp, nil)
+	return true, nil
 }
 
 // Delete deletes the value for the given key. Delete will not return error if key doesn't exist. It's thread-safe.
 // It is safe to modify the contents of the argument after Delete returns.
 func (dm *DMap) Delete(key string) error {
-	return dm.deleteKey(key)
+	return dm.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete but the given context cancels the network operations.
+func (dm *DMap) DeleteContext(ctx context.Context, key string) error {
+	return dm.deleteKey(ctx, key)
 }
@@ -15,6 +15,8 @@
 package dmap
 
 import (
+	"context"
+
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/neterrors"
@@ -38,20 +40,30 @@ func (s *Service) deleteOperationCommon(w, r protocol.EncodeDecoder, f func(dm *
 func (s *Service) deleteOperation(w, r protocol.EncodeDecoder) {
 	s.deleteOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
 		req := r.(*protocol.DMapMessage)
-		return dm.deleteKey(req.Key())
+		return dm.deleteKey(context.Background(), req.Key())
 	})
 }
 
+// deleteTimestamp extracts the time of deletion from the request. It returns zero
+// if the sender doesn't send a timestamp.
+func deleteTimestamp(req *protocol.DMapMessage) int64 {
+	extra, ok := req.Extra().(protocol.DeleteExtra)
+	if !ok {
+		return 0
+	}
+	return extra.Timestamp
+}
+
 func (s *Service) deletePrevOperation(w, r protocol.EncodeDecoder) {
 	s.deleteOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
 		req := r.(*protocol.DMapMessage)
-		return dm.deleteBackupFromFragment(req.Key(), partitions.PRIMARY)
+		return dm.deleteBackupFromFragment(req.Key(), partitions.PRIMARY, deleteTimestamp(req))
 	})
 }
 
 func (s *Service) deleteReplicaOperation(w, r protocol.EncodeDecoder) {
 	s.deleteOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
 		req := r.(*protocol.DMapMessage)
-		return dm.deleteBackupFromFragment(req.Key(), partitions.BACKUP)
+		return dm.deleteBackupFromFragment(req.Key(), partitions.BACKUP, deleteTimestamp(req))
 	})
 }
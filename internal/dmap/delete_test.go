@@ -16,6 +16,7 @@ package dmap
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -127,11 +128,13 @@ func TestDMap_Delete_StaleFragments(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	c1 := testutil.NewConfig()
 	c1.DMaps.CheckEmptyFragmentsInterval = time.Millisecond
+	c1.DMaps.TombstoneGracePeriod = time.Millisecond
 	e1 := testcluster.NewEnvironment(c1)
 	s1 := cluster.AddMember(e1).(*Service)
 
 	c2 := testutil.NewConfig()
 	c2.DMaps.CheckEmptyFragmentsInterval = time.Millisecond
+	c2.DMaps.TombstoneGracePeriod = time.Millisecond
 	e2 := testcluster.NewEnvironment(c2)
 	s2 := cluster.AddMember(e2).(*Service)
 
@@ -250,12 +253,55 @@ func TestDMap_Delete_DeleteKeyValFromPreviousOwners(t *testing.T) {
 	}
 	// this has to be the last one
 	data = append(data, owner)
-	err = dm.deleteFromPreviousOwners("mykey", data)
+	err = dm.deleteFromPreviousOwners(context.Background(), "mykey", time.Now().UnixNano(), data)
 	if err != nil {
 		t.Fatalf("Expected nil. Got: %v", err)
 	}
 }
 
+// setPreviousOwner makes current the primary owner of the partition and leaves
+// the data on the previous owner, as if the fragments have not been moved yet.
+func setPreviousOwner(hkey uint64, previous, current *Service, services ...*Service) {
+	owners := []discovery.Member{previous.rt.This(), current.rt.This()}
+	for _, s := range services {
+		s.primary.PartitionByHKey(hkey).SetOwners(owners)
+	}
+}
+
+// ownerOf returns the primary owner of the key and the other member.
+func ownerOf(key string, s1, s2 *Service) (*Service, *Service) {
+	owner := s1.primary.PartitionByHKey(partitions.HKey("mymap", key)).Owner()
+	if owner.CompareByID(s1.rt.This()) {
+		return s1, s2
+	}
+	return s2, s1
+}
+
+func TestDMap_Delete_OnlyOnPreviousOwner(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	require.NoError(t, dm1.Put("mykey", "myvalue"))
+
+	previous, current := ownerOf("mykey", s1, s2)
+	setPreviousOwner(partitions.HKey("mymap", "mykey"), previous, current, s1, s2)
+
+	dm, err := current.NewDMap("mymap")
+	require.NoError(t, err)
+	_, err = dm.Get("mykey")
+	require.NoError(t, err)
+
+	// The key doesn't exist on the primary owner, the tombstone has to hide
+	// the version on the previous owner.
+	require.NoError(t, dm.Delete("mykey"))
+	_, err = dm.Get("mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
 func TestDMap_Delete_Backup(t *testing.T) {
 	cluster := testcluster.New(NewService)
 
@@ -308,6 +354,9 @@ func TestDMap_Delete_Compaction(t *testing.T) {
 	c.ReadRepair = true
 	c.ReplicaCount = 2
 	c.DMaps.TriggerCompactionInterval = time.Millisecond
+	// Remove the tombstones immediately.
+	c.DMaps.CheckEmptyFragmentsInterval = time.Millisecond
+	c.DMaps.TombstoneGracePeriod = time.Millisecond
 	c.DMaps.Engine.Name = config.DefaultStorageEngine
 	c.DMaps.Engine.Implementation = &kvstore.KVStore{}
 	c.DMaps.Engine.Config = map[string]interface{}{
@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"runtime"
 
 	"github.com/buraksezer/olric/internal/discovery"
@@ -23,7 +24,7 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
-func (dm *DMap) destroyOnCluster() error {
+func (dm *DMap) destroyOnCluster(ctx context.Context) error {
 	num := int64(runtime.NumCPU())
 	sem := semaphore.NewWeighted(num)
 
@@ -54,7 +55,7 @@ func (dm *DMap) destroyOnCluster() error {
 			req := protocol.NewDMapMessage(protocol.OpDestroyDMapInternal)
 			req.SetDMap(dm.name)
 			dm.s.log.V(6).Printf("[DEBUG] Calling Destroy command on %s for %s", addr, dm.name)
-			_, err := dm.s.requestTo(addr, req)
+			_, err := dm.s.requestTo(ctx, addr, req)
 			if err != nil {
 				dm.s.log.V(3).Printf("[ERROR] Failed to destroy DMap: %s on %s", dm.name, addr)
 			}
@@ -68,5 +69,10 @@ func (dm *DMap) destroyOnCluster() error {
 // is no global lock on DMaps. So if you call Put, PutEx and Destroy methods
 // concurrently on the cluster, Put and PutEx calls may set new values to the DMap.
 func (dm *DMap) Destroy() error {
-	return dm.destroyOnCluster()
+	return dm.DestroyContext(context.Background())
+}
+
+// DestroyContext is like Destroy but the given context cancels the network operations.
+func (dm *DMap) DestroyContext(ctx context.Context) error {
+	return dm.destroyOnCluster(ctx)
 }
@@ -15,6 +15,8 @@
 package dmap
 
 import (
+	"context"
+
 	"errors"
 
 	"github.com/buraksezer/olric/config"
@@ -30,7 +32,7 @@ func (s *Service) destroyOperation(w, r protocol.EncodeDecoder) {
 		neterrors.ErrorResponse(w, err)
 		return
 	}
-	err = dm.destroyOnCluster()
+	err = dm.destroyOnCluster(context.Background())
 	if err != nil {
 		neterrors.ErrorResponse(w, err)
 		return
@@ -0,0 +1,76 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/diskstore"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_DiskStore(t *testing.T) {
+	dataDir := t.TempDir()
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.DMaps.Engine.Name = config.DiskStorageEngine
+		c.DMaps.Engine.Implementation = &diskstore.DiskStore{}
+		c.DMaps.Engine.Config = map[string]interface{}{
+			"dataDir":        dataDir,
+			"maxSegmentSize": 1 << 20,
+			"syncWrites":     false,
+		}
+		return c
+	}
+
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		value, err := dm.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+	cluster.Shutdown()
+
+	// Start a new node on the same data directory. The fragments on disk are
+	// opened before the node bootstraps.
+	cluster = testcluster.New(NewService)
+	s = cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	defer cluster.Shutdown()
+	require.NoError(t, s.Restore())
+
+	dm, err = s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	n, err := dm.Len()
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	for i := 0; i < 100; i++ {
+		value, err := dm.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+}
@@ -21,9 +21,11 @@ import (
 
 	"github.com/buraksezer/olric/internal/bufpool"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/nearcache"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/neterrors"
 	"github.com/buraksezer/olric/pkg/storage"
+	"golang.org/x/sync/singleflight"
 )
 
 // pool is good for recycling memory while reading messages from the socket.
@@ -45,6 +47,12 @@ type DMap struct {
 	s            *Service
 	engine       storage.Engine
 	config       *dmapConfig
+	// loadGroup deduplicates the concurrent MapLoader calls for the same key.
+	loadGroup singleflight.Group
+	// nearCache keeps the entries that are owned by the other members, if it's enabled.
+	nearCache *nearcache.NearCache
+	// listeners are called when an entry is evicted or expired on this member.
+	listeners evictionListeners
 }
 
 // Name exposes name of the DMap.
@@ -84,7 +92,11 @@ func (s *Service) NewDMap(name string) (*DMap, error) {
 	if err := s.rt.CheckBootstrap(); err != nil {
 		return nil, err
 	}
+	return s.createDMap(name)
+}
 
+// createDMap creates a new DMap instance without checking the cluster state.
+func (s *Service) createDMap(name string) (*DMap, error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -105,7 +117,18 @@ func (s *Service) NewDMap(name string) (*DMap, error) {
 
 	// It's a shortcut.
 	dm.engine = dm.config.engine.Implementation
+	if err := dm.checkLFUSupport(); err != nil {
+		return nil, err
+	}
+	if err := dm.newNearCache(); err != nil {
+		return nil, err
+	}
 	s.dmaps[name] = dm
+
+	if dm.isWriteBehindEnabled() {
+		s.wg.Add(1)
+		go dm.writeBehindWorker()
+	}
 	return dm, nil
 }
 
@@ -118,6 +141,15 @@ func (s *Service) getOrCreateDMap(name string) (*DMap, error) {
 	return dm, err
 }
 
+// hkey calculates HKey of the given key. If hash tags are enabled, the keys with
+// the same hash tag are stored on the same partition.
+func (dm *DMap) hkey(key string) uint64 {
+	if dm.config.hashTags {
+		return partitions.HKeyWithHashTag(dm.name, key, dm.s.config.PartitionCount)
+	}
+	return partitions.HKey(dm.name, key)
+}
+
 func (dm *DMap) getPartitionByHKey(hkey uint64, kind partitions.Kind) *partitions.Partition {
 	var part *partitions.Partition
 	switch {
@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -22,8 +23,11 @@ import (
 )
 
 type env struct {
+	// ctx cancels the network operations that are run on behalf of the caller.
+	ctx           context.Context
 	hkey          uint64
 	timestamp     int64
+	version       int64
 	flags         int16
 	opcode        protocol.OpCode
 	replicaOpcode protocol.OpCode
@@ -33,10 +37,14 @@ type env struct {
 	timeout       time.Duration
 	kind          partitions.Kind
 	fragment      *fragment
+	// loaded is true if the value has been read from the MapLoader. It's not
+	// written back to the MapStore.
+	loaded bool
 }
 
-func newEnv(opcode protocol.OpCode, name, key string, value []byte, timeout time.Duration, flags int16, kind partitions.Kind) *env {
+func newEnv(ctx context.Context, opcode protocol.OpCode, name, key string, value []byte, timeout time.Duration, flags int16, kind partitions.Kind) *env {
 	e := &env{
+		ctx:       ctx,
 		opcode:    opcode,
 		dmap:      name,
 		key:       key,
@@ -55,20 +63,22 @@ func newEnv(opcode protocol.OpCode, name, key string, value []byte, timeout time
 		e.replicaOpcode = protocol.OpPutIfReplica
 	case opcode == protocol.OpPutIfEx:
 		e.replicaOpcode = protocol.OpPutIfExReplica
+	case opcode == protocol.OpCompareAndSwap:
+		e.replicaOpcode = protocol.OpPutReplica
 	}
 	return e
 }
 
 // newEnvFromReq generates a new protocol message from writeop instance.
-func newEnvFromReq(r protocol.EncodeDecoder, kind partitions.Kind) *env {
-	e := &env{}
+func (dm *DMap) newEnvFromReq(r protocol.EncodeDecoder, kind partitions.Kind) *env {
+	e := &env{ctx: context.Background()}
 	req := r.(*protocol.DMapMessage)
 	e.dmap = req.DMap()
 	e.key = req.Key()
 	e.value = req.Value()
 	e.opcode = req.Op
 	e.kind = kind
-	e.hkey = partitions.HKey(req.DMap(), req.Key())
+	e.hkey = dm.hkey(req.Key())
 
 	// Set opcode for a possible replica operation
 	switch e.opcode {
@@ -80,6 +90,8 @@ func newEnvFromReq(r protocol.EncodeDecoder, kind partitions.Kind) *env {
 		e.replicaOpcode = protocol.OpPutIfReplica
 	case protocol.OpPutIfEx:
 		e.replicaOpcode = protocol.OpPutIfExReplica
+	case protocol.OpCompareAndSwap:
+		e.replicaOpcode = protocol.OpPutReplica
 	}
 
 	// Extract extras
@@ -96,6 +108,9 @@ func newEnvFromReq(r protocol.EncodeDecoder, kind partitions.Kind) *env {
 		e.flags = req.Extra().(protocol.PutIfExExtra).Flags
 		e.timestamp = req.Extra().(protocol.PutIfExExtra).Timestamp
 		e.timeout = time.Duration(req.Extra().(protocol.PutIfExExtra).TTL)
+	case protocol.OpCompareAndSwap:
+		e.version = req.Extra().(protocol.CompareAndSwapExtra).Version
+		e.timestamp = req.Extra().(protocol.CompareAndSwapExtra).Timestamp
 	case protocol.OpExpire:
 		e.timestamp = req.Extra().(protocol.ExpireExtra).Timestamp
 		e.timeout = time.Duration(req.Extra().(protocol.ExpireExtra).TTL)
@@ -132,6 +147,11 @@ func (e *env) toReq(opcode protocol.OpCode) *protocol.DMapMessage {
 			Timestamp: e.timestamp,
 			TTL:       e.timeout.Nanoseconds(),
 		})
+	case protocol.OpCompareAndSwap:
+		req.SetExtra(protocol.CompareAndSwapExtra{
+			Version:   e.version,
+			Timestamp: e.timestamp,
+		})
 	case protocol.OpExpire:
 		req.SetExtra(protocol.ExpireExtra{
 			Timestamp: e.timestamp,
@@ -20,6 +20,7 @@ import (
 	"math/rand"
 	"runtime"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -36,15 +37,28 @@ func (dm *DMap) isKeyIdleOnFragment(hkey uint64, f *fragment) bool {
 	if dm.config.maxIdleDuration.Nanoseconds() == 0 {
 		return false
 	}
-	// Maximum time in seconds for each entry to stay idle in the map.
-	// It limits the lifetime of the entries relative to the time of the last
-	// read or write access performed on them. The entries whose idle period
-	// exceeds this limit are expired and evicted automatically.
 	lastAccess, err := f.storage.GetLastAccess(hkey)
 	if errors.Is(err, storage.ErrKeyNotFound) {
 		return false
 	}
 	// TODO: Handle other errors.
+	return dm.isIdle(lastAccess)
+}
+
+// isIdle returns true if the last access time exceeds MaxIdleDuration.
+func (dm *DMap) isIdle(lastAccess int64) bool {
+	if dm.config == nil {
+		return false
+	}
+
+	if dm.config.maxIdleDuration.Nanoseconds() == 0 {
+		return false
+	}
+	lastAccess = dm.lastAccessTime(lastAccess)
+	// Maximum time in seconds for each entry to stay idle in the map.
+	// It limits the lifetime of the entries relative to the time of the last
+	// read or write access performed on them. The entries whose idle period
+	// exceeds this limit are expired and evicted automatically.
 	ttl := (dm.config.maxIdleDuration.Nanoseconds() + lastAccess) / 1000000
 	return isKeyExpired(ttl)
 }
@@ -123,6 +137,8 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 	var maxTotalCount = 100
 	var totalCount = 0
 
+	// name is the name of the fragment, not the DMap.
+	name = strings.TrimPrefix(name, "dmap.")
 	dm, err := s.getOrCreateDMap(name)
 	if err != nil {
 		s.log.V(3).Printf("[ERROR] Failed to load DMap: %s: %v", name, err)
@@ -143,8 +159,18 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 				// this means 'break'.
 				return false
 			}
-			if isKeyExpired(entry.TTL()) || dm.isKeyIdleOnFragment(hkey, f) {
-				err = dm.deleteOnCluster(hkey, entry.Key(), f)
+			if isTombstone(entry) {
+				// Tombstones are removed by the janitor.
+				return true
+			}
+			// Range updates the last access time of the entry, so use the
+			// previous value to find the idle keys.
+			if isKeyExpired(entry.TTL()) || dm.isIdle(entry.LastAccess()) {
+				reason := EvictionReasonExpired
+				if !isKeyExpired(entry.TTL()) {
+					reason = EvictionReasonIdle
+				}
+				err = dm.deleteOnCluster(s.ctx, hkey, entry.Key(), f, 0)
 				if err != nil {
 					// It will be tried again.
 					dm.s.log.V(3).Printf("[ERROR] Failed to delete expired key: %s on DMap: %s: %v",
@@ -154,6 +180,8 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 
 				// number of valid items removed from cache to free memory for new items.
 				EvictedTotal.Increase(1)
+				dm.notifyKeyspace(KeyspaceExpire, entry.Key(), dm.s.clock.Now(), nil)
+				dm.notifyEvictionListeners(entry.Key(), entry.Value(), reason)
 			}
 			return true
 		})
@@ -202,6 +230,10 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 		if idx >= dm.config.lruSamples {
 			return false
 		}
+		if isTombstone(e) {
+			// The key has already been deleted.
+			return true
+		}
 		idx++
 		i := lruItem{
 			HKey:       hkey,
@@ -217,8 +249,12 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 
 	sort.Slice(items, func(i, j int) bool { return items[i].LastAccess < items[j].LastAccess })
 	// Pick the first item to delete. It's the least recently used item in the sample.
-	item := items[0]
-	key, err := e.fragment.storage.GetKey(item.HKey)
+	return dm.evictKey(e, items[0].HKey, "LRU")
+}
+
+// evictKey deletes the key that's selected by the eviction policy. It's not thread-safe.
+func (dm *DMap) evictKey(e *env, hkey uint64, policy string) error {
+	entry, err := e.fragment.storage.Get(hkey)
 	if err != nil {
 		if errors.Is(err, storage.ErrKeyNotFound) {
 			err = ErrKeyNotFound
@@ -227,15 +263,18 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 		return err
 	}
 	// Here we have a key/value pair to evict for making room for a new pair.
+	key := entry.Key()
 	if dm.s.log.V(6).Ok() {
-		dm.s.log.V(6).Printf("[DEBUG] Evicted item on DMap: %s, key: %s with LRU", e.dmap, key)
+		dm.s.log.V(6).Printf("[DEBUG] Evicted item on DMap: %s, key: %s with %s", e.dmap, key, policy)
 	}
-	err = dm.deleteOnCluster(item.HKey, key, e.fragment)
+	err = dm.deleteOnCluster(e.ctx, hkey, key, e.fragment, 0)
 	if err != nil {
 		return err
 	}
 
 	// number of valid items removed from cache to free memory for new items.
 	EvictedTotal.Increase(1)
+	dm.notifyKeyspace(KeyspaceEvict, key, dm.s.clock.Now(), nil)
+	dm.notifyEvictionListeners(key, entry.Value(), EvictionReasonEvicted)
 	return nil
 }
@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -40,6 +41,7 @@ func (dm *DMap) localExpireOnReplica(e *env) error {
 	f.Lock()
 	defer f.Unlock()
 
+	dm.s.clock.Update(e.timestamp)
 	return dm.localExpire(e)
 }
 
@@ -66,7 +68,8 @@ func (dm *DMap) asyncExpireOnCluster(e *env) error {
 		dm.s.wg.Add(1)
 		go func(host discovery.Member) {
 			defer dm.s.wg.Done()
-			_, err := dm.s.requestTo(host.String(), req)
+			// The caller doesn't wait for the replicas.
+			_, err := dm.s.requestTo(context.Background(), host.String(), req)
 			if err != nil {
 				if dm.s.log.V(3).Ok() {
 					dm.s.log.V(3).Printf("[ERROR] Failed to set expire in async mode: %v", err)
@@ -84,7 +87,7 @@ func (dm *DMap) syncExpireOnCluster(e *env) error {
 	var successful int
 	owners := dm.s.backup.PartitionOwnersByHKey(e.hkey)
 	for _, owner := range owners {
-		_, err := dm.s.requestTo(owner.String(), req)
+		_, err := dm.s.requestTo(e.ctx, owner.String(), req)
 		if err != nil {
 			if dm.s.log.V(3).Ok() {
 				dm.s.log.V(3).Printf("[ERROR] Failed to call expire command on %s for DMap: %s: %v",
@@ -119,6 +122,19 @@ func (dm *DMap) callExpireOnCluster(e *env) error {
 	f.Lock()
 	defer f.Unlock()
 
+	// An expired, idle or deleted key cannot be revived by updating its TTL.
+	if _, err = dm.liveTTL(f, e.hkey); err != nil {
+		return err
+	}
+	return dm.expireOnLockedFragment(e)
+}
+
+// expireOnLockedFragment updates the expiry of the key on the partition owner and
+// its replicas. The fragment has to be locked by the caller.
+func (dm *DMap) expireOnLockedFragment(e *env) error {
+	// See putOnCluster
+	e.timestamp = dm.s.clock.Now()
+
 	if dm.s.config.ReplicaCount == config.MinimumReplicaCount {
 		// MinimumReplicaCount is 1. So it's enough to put the key locally. There is no
 		// other replica host.
@@ -136,22 +152,29 @@ func (dm *DMap) callExpireOnCluster(e *env) error {
 }
 
 func (dm *DMap) expire(e *env) error {
-	e.hkey = partitions.HKey(e.dmap, e.key)
+	e.hkey = dm.hkey(e.key)
 	member := dm.s.primary.PartitionByHKey(e.hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
 		// We are on the partition owner.
 		return dm.callExpireOnCluster(e)
 	}
 	// Redirect to the partition owner
+	defer dm.invalidateNearCache(e.key)
 	req := e.toReq(protocol.OpExpire)
-	_, err := dm.s.requestTo(member.String(), req)
+	_, err := dm.s.requestTo(e.ctx, member.String(), req)
 	return err
 }
 
 // Expire updates the expiry for the given key. It returns ErrKeyNotFound if the
-// DB does not contain the key. It's thread-safe.
+// DB does not contain the key, or the key is already expired or idle. It's thread-safe.
 func (dm *DMap) Expire(key string, timeout time.Duration) error {
+	return dm.ExpireContext(context.Background(), key, timeout)
+}
+
+// ExpireContext is like Expire but the given context cancels the network operations.
+func (dm *DMap) ExpireContext(ctx context.Context, key string, timeout time.Duration) error {
 	e := &env{
+		ctx:       ctx,
 		dmap:      dm.name,
 		key:       key,
 		timestamp: time.Now().UnixNano(),
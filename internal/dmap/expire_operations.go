@@ -37,14 +37,14 @@ func (s *Service) expireOperationCommon(w, r protocol.EncodeDecoder, f func(dm *
 
 func (s *Service) expireReplicaOperation(w, r protocol.EncodeDecoder) {
 	s.expireOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
-		e := newEnvFromReq(r, partitions.BACKUP)
+		e := dm.newEnvFromReq(r, partitions.BACKUP)
 		return dm.localExpireOnReplica(e)
 	})
 }
 
 func (s *Service) expireOperation(w, r protocol.EncodeDecoder) {
 	s.expireOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
-		e := newEnvFromReq(r, partitions.PRIMARY)
+		e := dm.newEnvFromReq(r, partitions.PRIMARY)
 		return dm.expire(e)
 	})
 }
@@ -74,6 +74,28 @@ func TestDMap_Expire_ErrKeyNotFound(t *testing.T) {
 	}
 }
 
+func TestDMap_Expire_Expired_Key(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	err = dm.PutEx("mykey", "myvalue", time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	<-time.After(5 * time.Millisecond)
+
+	// The key is still in the fragment, but it cannot be revived.
+	err = dm.Expire("mykey", time.Hour)
+	if err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound. Got: %v", err)
+	}
+}
+
 func testExpireWithConfig(t *testing.T, s1, s2 *Service) {
 	dm, err := s1.NewDMap("mymap")
 	if err != nil {
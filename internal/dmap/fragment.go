@@ -17,9 +17,15 @@ package dmap
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
@@ -34,10 +40,63 @@ type fragment struct {
 	storage storage.Engine
 	ctx     context.Context
 	cancel  context.CancelFunc
+	// writeBehind is only set on the primary fragments in write-behind mode.
+	writeBehind *writeBehindQueue
+	// tombstones keeps the HKeys of the tombstones. They are not counted as keys.
+	tombstones map[uint64]struct{}
 }
 
+// Stats returns the statistics of the storage engine. Length doesn't include
+// the tombstones.
 func (f *fragment) Stats() storage.Stats {
-	return f.storage.Stats()
+	f.RLock()
+	defer f.RUnlock()
+
+	st := f.storage.Stats()
+	st.Length = f.length()
+	return st
+}
+
+// put inserts the entry and keeps track of the tombstones. It's not thread-safe.
+func (f *fragment) put(hkey uint64, entry storage.Entry) error {
+	err := f.storage.Put(hkey, entry)
+	if err != nil {
+		return err
+	}
+	if isTombstone(entry) {
+		f.tombstones[hkey] = struct{}{}
+	} else {
+		delete(f.tombstones, hkey)
+	}
+	return nil
+}
+
+// delete deletes the entry and keeps track of the tombstones. It's not thread-safe.
+func (f *fragment) delete(hkey uint64) error {
+	err := f.storage.Delete(hkey)
+	if err != nil {
+		return err
+	}
+	delete(f.tombstones, hkey)
+	return nil
+}
+
+// countTombstones finds the tombstones in the storage engine again. It should be
+// called after the entries are inserted or removed by bypassing put and delete.
+// It's not thread-safe.
+func (f *fragment) countTombstones() {
+	f.tombstones = make(map[uint64]struct{})
+	f.storage.Range(func(hkey uint64, entry storage.Entry) bool {
+		if isTombstone(entry) {
+			f.tombstones[hkey] = struct{}{}
+		}
+		return true
+	})
+}
+
+// length returns the number of the keys without the tombstones. It's not thread-safe.
+func (f *fragment) length() int {
+	return f.storage.Stats().Length - len(f.tombstones)
 }
 
 func (f *fragment) Compaction() (bool, error) {
@@ -72,27 +131,35 @@ func (f *fragment) Length() int {
 	f.RLock()
 	defer f.RUnlock()
 
-	return f.storage.Stats().Length
+	return f.length()
 }
 
 func (f *fragment) Move(part *partitions.Partition, name string, owners []discovery.Member) error {
 	f.Lock()
 	defer f.Unlock()
 
+	fp := &fragmentPack{
+		PartID: part.ID(),
+		Kind:   part.Kind(),
+		Name:   strings.TrimPrefix(name, "dmap."),
+	}
+	// The pending writes move along with the fragment.
+	if f.writeBehind != nil {
+		fp.Pending = f.writeBehind.snapshot()
+	}
+
 	i := f.storage.TransferIterator()
-	if !i.Next() {
+	hasNext := i.Next()
+	if !hasNext && len(fp.Pending) == 0 {
 		return nil
 	}
 
-	payload, err := i.Export()
-	if err != nil {
-		return err
-	}
-	fp := &fragmentPack{
-		PartID:  part.ID(),
-		Kind:    part.Kind(),
-		Name:    strings.TrimPrefix(name, "dmap."),
-		Payload: payload,
+	if hasNext {
+		payload, err := i.Export()
+		if err != nil {
+			return err
+		}
+		fp.Payload = payload
 	}
 	value, err := msgpack.Marshal(fp)
 	if err != nil {
@@ -102,17 +169,41 @@ func (f *fragment) Move(part *partitions.Partition, name string, owners []discov
 	req := protocol.NewSystemMessage(protocol.OpMoveFragment)
 	req.SetValue(value)
 	for _, owner := range owners {
-		_, err = f.service.requestTo(owner.String(), req)
+		_, err = f.service.requestTo(context.Background(), owner.String(), req)
 		if err != nil {
 			return err
 		}
 	}
 
-	return i.Pop()
+	if f.writeBehind != nil {
+		// The new owner flushes them.
+		f.writeBehind.remove(fp.Pending)
+	}
+	if !hasNext {
+		return nil
+	}
+	if err = i.Pop(); err != nil {
+		return err
+	}
+	f.countTombstones()
+	return nil
+}
+
+// persistentFragmentName returns the name of the fragment for the persistent storage
+// engines. It's parsed by openPersistentFragments after a restart.
+func persistentFragmentName(part *partitions.Partition, name string) string {
+	return fmt.Sprintf("%s.%d.%s", strings.ToLower(part.Kind().String()), part.ID(), name)
 }
 
-func (dm *DMap) newFragment() (*fragment, error) {
-	c := storage.NewConfig(dm.config.engine.Config)
+func (dm *DMap) newFragment(part *partitions.Partition) (*fragment, error) {
+	// Copy the configuration, it's shared by all fragments of the DMap.
+	c := storage.NewConfig(dm.config.engine.Config).Copy()
+	// Persistent storage engines use this to find the same data after a restart.
+	c.Add("fragment", persistentFragmentName(part, dm.name))
+	if dm.isLFUEnabled() {
+		// LFU keeps the access counters in the LastAccess field.
+		c.Add("accessCounter", true)
+	}
 	engine, err := dm.engine.Fork(c)
 	if err != nil {
 		return nil, err
@@ -125,12 +216,18 @@ func (dm *DMap) newFragment() (*fragment, error) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	return &fragment{
+	f := &fragment{
 		service: dm.s,
 		storage: engine,
 		ctx:     ctx,
 		cancel:  cancel,
-	}, nil
+	}
+	// Persistent storage engines may load tombstones from disk.
+	f.countTombstones()
+	if part.Kind() == partitions.PRIMARY && dm.isWriteBehindEnabled() {
+		f.writeBehind = newWriteBehindQueue()
+	}
+	return f, nil
 }
 
 func (dm *DMap) loadOrCreateFragment(part *partitions.Partition) (*fragment, error) {
@@ -145,7 +242,7 @@ func (dm *DMap) loadOrCreateFragment(part *partitions.Partition) (*fragment, err
 		return fg.(*fragment), nil
 	}
 
-	f, err := dm.newFragment()
+	f, err := dm.newFragment(part)
 	if err != nil {
 		return nil, err
 	}
@@ -162,4 +259,91 @@ func (dm *DMap) loadFragment(part *partitions.Partition) (*fragment, error) {
 	return f.(*fragment), nil
 }
 
+// dataDirs returns the data directories of the DMaps that use the disk storage engine.
+func (s *Service) dataDirs() []string {
+	engines := []*config.Engine{s.config.DMaps.Engine}
+	for _, cs := range s.config.DMaps.Custom {
+		engines = append(engines, cs.Engine)
+	}
+
+	var dirs []string
+	seen := make(map[string]struct{})
+	for _, engine := range engines {
+		if engine == nil || engine.Name != config.DiskStorageEngine {
+			continue
+		}
+		dataDir, ok := engine.Config["dataDir"].(string)
+		if !ok || dataDir == "" {
+			continue
+		}
+		if _, ok := seen[dataDir]; ok {
+			continue
+		}
+		seen[dataDir] = struct{}{}
+		dirs = append(dirs, dataDir)
+	}
+	return dirs
+}
+
+// openPersistentFragments opens the fragments that are found in the data directories
+// of the disk storage engine. Otherwise, the persisted data is invisible until a write
+// creates the fragment again.
+func (s *Service) openPersistentFragments() error {
+	var total int
+	for _, dataDir := range s.dataDirs() {
+		files, err := ioutil.ReadDir(dataDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if !file.IsDir() {
+				continue
+			}
+			name, err := url.PathUnescape(file.Name())
+			if err != nil {
+				continue
+			}
+			// The format is kind.partID.dmapName
+			parsed := strings.SplitN(name, ".", 3)
+			if len(parsed) != 3 {
+				continue
+			}
+			partID, err := strconv.ParseUint(parsed[1], 10, 64)
+			if err != nil || partID >= s.config.PartitionCount {
+				continue
+			}
+			var part *partitions.Partition
+			switch parsed[0] {
+			case strings.ToLower(partitions.PRIMARY.String()):
+				part = s.primary.PartitionByID(partID)
+			case strings.ToLower(partitions.BACKUP.String()):
+				part = s.backup.PartitionByID(partID)
+			default:
+				continue
+			}
+
+			dm, err := s.createDMap(parsed[2])
+			if err != nil {
+				return err
+			}
+			if dm.config.engine.Name != config.DiskStorageEngine || dm.config.engine.Config["dataDir"] != dataDir {
+				// The DMap uses a different storage engine now.
+				continue
+			}
+			if _, err = dm.loadOrCreateFragment(part); err != nil {
+				return fmt.Errorf("failed to open DMap fragment: %s: %w", name, err)
+			}
+			total++
+		}
+	}
+	if total > 0 {
+		s.log.V(2).Printf("[INFO] %d persistent DMap fragment(s) have been opened", total)
+	}
+	return nil
+}
+
 var _ partitions.Fragment = (*fragment)(nil)
@@ -41,7 +41,8 @@ func TestDMap_Fragment(t *testing.T) {
 	})
 
 	t.Run("newFragment", func(t *testing.T) {
-		_, err := dm.newFragment()
+		part := s.primary.PartitionByID(1)
+		_, err := dm.newFragment(part)
 		if err != nil {
 			t.Fatalf("Expected nil. Got: %v", err)
 		}
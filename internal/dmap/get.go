@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"errors"
 	"sort"
 	"time"
@@ -30,10 +31,13 @@ import (
 
 // Entry is a DMap entry with its metadata.
 type Entry struct {
-	Key       string
-	Value     interface{}
-	TTL       int64
-	Timestamp int64
+	Key        string
+	Value      interface{}
+	TTL        int64
+	Timestamp  int64
+	LastAccess int64
+	// Version is an opaque token for CompareAndSwap.
+	Version int64
 }
 
 var (
@@ -87,18 +91,19 @@ func (dm *DMap) getOnFragment(e *env) (storage.Entry, error) {
 	return entry, nil
 }
 
-func (dm *DMap) lookupOnPreviousOwner(owner *discovery.Member, key string) (*version, error) {
+func (dm *DMap) lookupOnPreviousOwner(ctx context.Context, owner *discovery.Member, key string) (*version, error) {
 	req := protocol.NewDMapMessage(protocol.OpGetPrev)
 	req.SetDMap(dm.name)
 	req.SetKey(key)
 
 	v := &version{host: owner}
-	resp, err := dm.s.requestTo(owner.String(), req)
+	resp, err := dm.s.requestTo(ctx, owner.String(), req)
 	if err != nil {
 		return nil, err
 	}
 	data := dm.engine.NewEntry()
 	data.Decode(resp.Value())
+	dm.s.clock.Update(data.Timestamp())
 	v.entry = data
 	return v, nil
 }
@@ -134,17 +139,18 @@ func (dm *DMap) lookupOnThisNode(hkey uint64, key string) *version {
 	}
 	// We found the key
 	//
-	// LRU and MaxIdleDuration eviction policies are only valid on
+	// LRU, LFU and MaxIdleDuration eviction policies are only valid on
 	// the partition owner. Normally, we shouldn't need to retrieve the keys
 	// from the backup or the previous owners. When the fsck merge
 	// a fragmented partition or recover keys from a backup, Olric
 	// continue maintaining a reliable access log.
+	dm.touchAccessCounter(f, hkey, value)
 	return dm.valueToVersion(value)
 }
 
 // lookupOnOwners collects versions of a key/value pair on the partition owner
 // by including previous partition owners.
-func (dm *DMap) lookupOnOwners(hkey uint64, key string) []*version {
+func (dm *DMap) lookupOnOwners(ctx context.Context, hkey uint64, key string) []*version {
 	owners := dm.s.primary.PartitionOwnersByHKey(hkey)
 	if len(owners) == 0 {
 		panic("partition owners list cannot be empty")
@@ -157,7 +163,7 @@ func (dm *DMap) lookupOnOwners(hkey uint64, key string) []*version {
 	// Traverse in reverse order. Except from the latest host, this one.
 	for i := len(owners) - 2; i >= 0; i-- {
 		owner := owners[i]
-		v, err := dm.lookupOnPreviousOwner(&owner, key)
+		v, err := dm.lookupOnPreviousOwner(ctx, &owner, key)
 		if err != nil {
 			if dm.s.log.V(3).Ok() {
 				dm.s.log.V(3).Printf("[ERROR] Failed to call get on a previous "+
@@ -172,6 +178,8 @@ func (dm *DMap) lookupOnOwners(hkey uint64, key string) []*version {
 	return versions
 }
 
+// sortVersions sorts the versions by their timestamps, the latest one comes first.
+// Timestamps are generated by the hybrid logical clock of the partition owner.
 func (dm *DMap) sortVersions(versions []*version) []*version {
 	sort.Slice(versions,
 		func(i, j int) bool {
@@ -196,7 +204,7 @@ func (dm *DMap) sanitizeAndSortVersions(versions []*version) []*version {
 	return dm.sortVersions(sanitized)
 }
 
-func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
+func (dm *DMap) lookupOnReplicas(ctx context.Context, hkey uint64, key string) []*version {
 	// Check backup.
 	backups := dm.s.backup.PartitionOwnersByHKey(hkey)
 	versions := make([]*version, 0, len(backups))
@@ -206,7 +214,7 @@ func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
 		req.SetKey(key)
 		host := replica
 		v := &version{host: &host}
-		resp, err := dm.s.requestTo(replica.String(), req)
+		resp, err := dm.s.requestTo(ctx, replica.String(), req)
 		if err != nil {
 			if dm.s.log.V(6).Ok() {
 				dm.s.log.V(6).Printf("[ERROR] Failed to call get on"+
@@ -215,6 +223,7 @@ func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
 		} else {
 			data := dm.engine.NewEntry()
 			data.Decode(resp.Value())
+			dm.s.clock.Update(data.Timestamp())
 			v.entry = data
 		}
 		versions = append(versions, v)
@@ -222,16 +231,20 @@ func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
 	return versions
 }
 
-func (dm *DMap) readRepair(winner *version, versions []*version) {
+func (dm *DMap) readRepair(ctx context.Context, winner *version, versions []*version) {
 	for _, version := range versions {
 		if version.entry != nil && winner.entry.Timestamp() == version.entry.Timestamp() {
 			continue
 		}
+		if version.entry == nil && isTombstone(winner.entry) {
+			// The host doesn't have the key, no need to create a tombstone.
+			continue
+		}
 
 		// Sync
 		tmp := *version.host
 		if tmp.CompareByID(dm.s.rt.This()) {
-			hkey := partitions.HKey(dm.name, winner.entry.Key())
+			hkey := dm.hkey(winner.entry.Key())
 			part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
 			f, err := dm.loadOrCreateFragment(part)
 			if err != nil {
@@ -274,7 +287,7 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 					TTL:       winner.entry.TTL(),
 				})
 			}
-			_, err := dm.s.requestTo(version.host.String(), req)
+			_, err := dm.s.requestTo(ctx, version.host.String(), req)
 			if err != nil {
 				dm.s.log.V(3).Printf("[ERROR] Failed to synchronize replica %s: %v", version.host, err)
 			}
@@ -282,13 +295,13 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 	}
 }
 
-func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
+func (dm *DMap) getOnCluster(ctx context.Context, hkey uint64, key string) (storage.Entry, error) {
 	// RUnlock should not be called with defer statement here because
 	// readRepair function may call putOnFragment function which needs a write
 	// lock. Please don't forget calling RUnlock before returning here.
-	versions := dm.lookupOnOwners(hkey, key)
+	versions := dm.lookupOnOwners(ctx, hkey, key)
 	if dm.s.config.ReadQuorum >= config.MinimumReplicaCount {
-		v := dm.lookupOnReplicas(hkey, key)
+		v := dm.lookupOnReplicas(ctx, hkey, key)
 		versions = append(versions, v...)
 	}
 	if len(versions) < dm.s.config.ReadQuorum {
@@ -305,6 +318,13 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 
 	// The most up-to-date version of the values.
 	winner := sorted[0]
+	if isTombstone(winner.entry) {
+		if dm.s.config.ReadRepair {
+			// Propagate the tombstone to the hosts that have a stale version.
+			dm.readRepair(ctx, winner, versions)
+		}
+		return nil, ErrKeyNotFound
+	}
 	if isKeyExpired(winner.entry.TTL()) || dm.isKeyIdle(hkey) {
 		return nil, ErrKeyNotFound
 	}
@@ -312,17 +332,20 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 	if dm.s.config.ReadRepair {
 		// Parallel read operations may propagate different versions of
 		// the same key/value pair. The rule is simple: last write wins.
-		dm.readRepair(winner, versions)
+		dm.readRepair(ctx, winner, versions)
 	}
 	return winner.entry, nil
 }
 
-func (dm *DMap) get(key string) (storage.Entry, error) {
-	hkey := partitions.HKey(dm.name, key)
+func (dm *DMap) get(ctx context.Context, key string) (storage.Entry, error) {
+	hkey := dm.hkey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	// We are on the partition owner
 	if member.CompareByName(dm.s.rt.This()) {
-		entry, err := dm.getOnCluster(hkey, key)
+		entry, err := dm.getOnCluster(ctx, hkey, key)
+		if errors.Is(err, ErrKeyNotFound) && dm.config.loader != nil {
+			entry, err = dm.loadOnCluster(ctx, hkey, key)
+		}
 		if errors.Is(err, ErrKeyNotFound) {
 			GetMisses.Increase(1)
 		}
@@ -341,7 +364,7 @@ func (dm *DMap) get(key string) (storage.Entry, error) {
 	req.SetDMap(dm.name)
 	req.SetKey(key)
 
-	resp, err := dm.s.requestTo(member.String(), req)
+	resp, err := dm.s.requestTo(ctx, member.String(), req)
 	if errors.Is(err, ErrKeyNotFound) {
 		GetMisses.Increase(1)
 	}
@@ -361,7 +384,12 @@ func (dm *DMap) get(key string) (storage.Entry, error) {
 // does not contains the key. It's thread-safe. It is safe to modify the contents
 // of the returned value.
 func (dm *DMap) Get(key string) (interface{}, error) {
-	raw, err := dm.get(key)
+	return dm.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get but the given context cancels the network operations.
+func (dm *DMap) GetContext(ctx context.Context, key string) (interface{}, error) {
+	raw, err := dm.getWithNearCache(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -372,7 +400,12 @@ func (dm *DMap) Get(key string) (interface{}, error) {
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value.
 func (dm *DMap) GetEntry(key string) (*Entry, error) {
-	entry, err := dm.get(key)
+	return dm.GetEntryContext(context.Background(), key)
+}
+
+// GetEntryContext is like GetEntry but the given context cancels the network operations.
+func (dm *DMap) GetEntryContext(ctx context.Context, key string) (*Entry, error) {
+	entry, err := dm.getWithNearCache(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -381,9 +414,11 @@ func (dm *DMap) GetEntry(key string) (*Entry, error) {
 		return nil, err
 	}
 	return &Entry{
-		Key:       entry.Key(),
-		Value:     value,
-		TTL:       entry.TTL(),
-		Timestamp: entry.Timestamp(),
+		Key:        entry.Key(),
+		Value:      value,
+		TTL:        entry.TTL(),
+		Timestamp:  entry.Timestamp(),
+		LastAccess: dm.lastAccessTime(entry.LastAccess()),
+		Version:    entry.Timestamp(),
 	}, nil
 }
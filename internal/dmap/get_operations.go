@@ -15,6 +15,8 @@
 package dmap
 
 import (
+	"context"
+
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/neterrors"
@@ -41,20 +43,20 @@ func (s *Service) getOperationCommon(w, r protocol.EncodeDecoder, f func(dm *DMa
 func (s *Service) getOperation(w, r protocol.EncodeDecoder) {
 	s.getOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) (storage.Entry, error) {
 		req := r.(*protocol.DMapMessage)
-		return dm.get(req.Key())
+		return dm.getWithNearCache(context.Background(), req.Key())
 	})
 }
 
 func (s *Service) getReplicaOperation(w, r protocol.EncodeDecoder) {
 	s.getOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) (storage.Entry, error) {
-		e := newEnvFromReq(r, partitions.BACKUP)
+		e := dm.newEnvFromReq(r, partitions.BACKUP)
 		return dm.getOnFragment(e)
 	})
 }
 
 func (s *Service) getPrevOperation(w, r protocol.EncodeDecoder) {
 	s.getOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) (storage.Entry, error) {
-		e := newEnvFromReq(r, partitions.PRIMARY)
+		e := dm.newEnvFromReq(r, partitions.PRIMARY)
 		return dm.getOnFragment(e)
 	})
 }
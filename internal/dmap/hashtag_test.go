@@ -0,0 +1,95 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_HashTags(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.DMaps.Custom = map[string]config.DMap{"mymap": {HashTags: true}}
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.DMaps.Custom = map[string]config.DMap{"mymap": {HashTags: true}}
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("user:{42}:%d", i)
+		err = dm1.Put(key, i)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	// All the keys are on the same partition.
+	partID := s1.primary.PartitionIDByHKey(dm1.hkey(keys[0]))
+	for _, key := range keys {
+		require.Equal(t, partID, s1.primary.PartitionIDByHKey(dm1.hkey(key)))
+	}
+	part := s1.primary.PartitionByID(partID)
+	owner := s1
+	if !part.Owner().CompareByID(s1.rt.This()) {
+		owner = s2
+	}
+	f, err := dm1.loadFragment(owner.primary.PartitionByID(partID))
+	require.NoError(t, err)
+	require.Equal(t, len(keys), f.Length())
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+	for i, key := range keys {
+		value, err := dm2.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+
+	err = dm2.Delete(keys[0])
+	require.NoError(t, err)
+	_, err = dm1.Get(keys[0])
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Other keys with the same tag are not affected.
+	_, err = dm1.Get(keys[1])
+	require.NoError(t, err)
+}
+
+func TestDMap_HashTags_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	partitions := make(map[uint64]struct{})
+	for i := 0; i < 100; i++ {
+		hkey := dm.hkey(fmt.Sprintf("user:{42}:%d", i))
+		partitions[s.primary.PartitionIDByHKey(hkey)] = struct{}{}
+	}
+	require.Greater(t, len(partitions), 1)
+}
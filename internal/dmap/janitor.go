@@ -49,12 +49,29 @@ func (s *Service) deleteEmptyFragments() {
 			f.Lock()
 			defer f.Unlock()
 
-			if f.storage.Stats().Length != 0 {
-				// It's not empty. Continue scanning.
+			// Remove the tombstones of deleted keys after the grace period.
+			count, err := s.deleteStaleTombstones(f)
+			if err != nil {
+				s.log.V(3).Printf("[ERROR] Failed to delete stale tombstones on DMap fragment (kind: %s): %s on PartID: %d: %v",
+					part.Kind(), name, part.ID(), err)
+			}
+			if count > 0 && s.log.V(6).Ok() {
+				s.log.V(6).Printf("[DEBUG] %d stale tombstone(s) have been deleted on DMap fragment (kind: %s): %s on PartID: %d",
+					count, part.Kind(), name, part.ID())
+			}
+
+			if f.length() != 0 || len(f.tombstones) != 0 {
+				// It's not empty. The tombstones are kept until the grace period
+				// is over, otherwise a stale replica could resurrect the keys.
+				// Continue scanning.
+				return true
+			}
+			if f.writeBehind != nil && f.writeBehind.length() != 0 {
+				// There are writes waiting to be flushed.
 				return true
 			}
 
-			err := wipeOutFragment(part, name.(string), f)
+			err = wipeOutFragment(part, name.(string), f)
 			if err != nil {
 				s.log.V(3).Printf("[ERROR] Failed to delete empty DMap fragment (kind: %s): %s on PartID: %d",
 					part.Kind(), name, part.ID())
@@ -0,0 +1,139 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/buraksezer/olric/query"
+	"github.com/vmihailenco/msgpack"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// localLen returns the number of the keys in the primary fragment of the partition.
+// Tombstones and expired keys are not counted.
+func (dm *DMap) localLen(partID uint64) (int, error) {
+	part := dm.s.primary.PartitionByID(partID)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	var count int
+	f.storage.Range(func(_ uint64, entry storage.Entry) bool {
+		if !isTombstone(entry) && !isKeyExpired(entry.TTL()) {
+			count++
+		}
+		return true
+	})
+	return count, nil
+}
+
+// lenOfPartition returns the number of the keys in the partition.
+func (dm *DMap) lenOfPartition(ctx context.Context, partID uint64) (int, error) {
+	owners := dm.s.primary.PartitionOwnersByID(partID)
+	if len(owners) != 1 {
+		// The keys may be on the previous owners during rebalancing. Fetch and
+		// reconcile them to count every key once.
+		c, err := dm.QueryContext(ctx, query.M{
+			"$onKey": query.M{
+				"$regexMatch": "",
+				"$options": query.M{
+					"$onValue": query.M{
+						"$ignore": true,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return 0, err
+		}
+		defer c.Close()
+
+		entries, err := c.runQueryOnOwners(partID)
+		if err != nil {
+			return 0, err
+		}
+		return len(entries), nil
+	}
+
+	owner := owners[0]
+	if owner.CompareByID(dm.s.rt.This()) {
+		return dm.localLen(partID)
+	}
+
+	req := protocol.NewDMapMessage(protocol.OpLocalLen)
+	req.SetDMap(dm.name)
+	req.SetExtra(protocol.LocalLenExtra{
+		PartID: partID,
+	})
+	resp, err := dm.s.requestTo(ctx, owner.String(), req)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if len(resp.Value()) != 0 {
+		err = msgpack.Unmarshal(resp.Value(), &count)
+	}
+	return count, err
+}
+
+// Len returns the number of the keys in the DMap. The primary owners of the partitions
+// count their keys, so the backups are not counted. Expired keys are not counted,
+// even if they are not evicted yet.
+func (dm *DMap) Len() (int, error) {
+	return dm.LenContext(context.Background())
+}
+
+// LenContext is like Len but the given context cancels the network operations.
+func (dm *DMap) LenContext(ctx context.Context) (int, error) {
+	var count int64
+	sem := semaphore.NewWeighted(NumConcurrentWorkers)
+	g, gctx := errgroup.WithContext(ctx)
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		if err := sem.Acquire(gctx, 1); err != nil {
+			break
+		}
+
+		id := partID
+		g.Go(func() error {
+			defer sem.Release(1)
+
+			n, err := dm.lenOfPartition(gctx, id)
+			if err != nil {
+				return err
+			}
+			atomic.AddInt64(&count, int64(n))
+			return nil
+		})
+	}
+	err := g.Wait()
+	if err == nil {
+		// Acquire fails only if the context is done.
+		err = ctx.Err()
+	}
+	return int(atomic.LoadInt64(&count)), err
+}
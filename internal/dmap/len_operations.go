@@ -0,0 +1,56 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/vmihailenco/msgpack"
+)
+
+func (s *Service) lenOperationCommon(w, r protocol.EncodeDecoder, f func(dm *DMap, req *protocol.DMapMessage) (int, error)) {
+	req := r.(*protocol.DMapMessage)
+	dm, err := s.getOrCreateDMap(req.DMap())
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+	count, err := f(dm, req)
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+	value, err := msgpack.Marshal(count)
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+	w.SetStatus(protocol.StatusOK)
+	w.SetValue(value)
+}
+
+func (s *Service) lenOperation(w, r protocol.EncodeDecoder) {
+	s.lenOperationCommon(w, r, func(dm *DMap, req *protocol.DMapMessage) (int, error) {
+		return dm.LenContext(context.Background())
+	})
+}
+
+func (s *Service) localLenOperation(w, r protocol.EncodeDecoder) {
+	s.lenOperationCommon(w, r, func(dm *DMap, req *protocol.DMapMessage) (int, error) {
+		return dm.localLen(req.Extra().(protocol.LocalLenExtra).PartID)
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Len(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	count, err := dm1.Len()
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+	for i := 100; i < 110; i++ {
+		require.NoError(t, dm1.PutEx(testutil.ToKey(i), testutil.ToVal(i), time.Millisecond))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, dm1.Delete(testutil.ToKey(i)))
+	}
+
+	<-time.After(10 * time.Millisecond)
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+	for _, dm := range []*DMap{dm1, dm2} {
+		// Tombstones, expired keys and backups are not counted.
+		count, err = dm.Len()
+		require.NoError(t, err)
+		require.Equal(t, 95, count)
+	}
+}
+
+func TestDMap_Len_PreviousOwners(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	// The partitions have two owners until the fragments are moved.
+	s2 := cluster.AddMember(nil).(*Service)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	count, err := dm2.Len()
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+}
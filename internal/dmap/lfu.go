@@ -0,0 +1,196 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+/*
+The LFU implementation is borrowed from Redis. Every entry has a logarithmic
+access counter, it's incremented with a probability that decreases as the
+counter grows, so 8 bits are enough to represent millions of accesses. The
+counter is decremented by one for every LFUDecayTime that the key stays idle,
+so the keys that were popular in the past can be evicted eventually.
+
+Like Redis, the access counter is kept in the LastAccess field of the entry,
+so the entry layout doesn't change. The storage engine is forked with the
+accessCounter option and it stops updating the field on reads:
+
+LAST-ACCESS-TIME(56 bits, in milliseconds) | COUNTER(8 bits)
+*/
+
+const (
+	// lfuInitialCounter is the counter of a new key. It gives the new keys a chance
+	// to accumulate accesses before they are evicted.
+	lfuInitialCounter = 5
+
+	// lfuLogFactor controls how fast the counter saturates. With 10, the counter
+	// reaches 255 after about one million accesses.
+	lfuLogFactor = 10
+)
+
+func packAccessCounter(counter uint8, lat int64) int64 {
+	return lat<<8 | int64(counter)
+}
+
+func unpackAccessCounter(v int64) (uint8, int64) {
+	return uint8(v & 0xff), v >> 8
+}
+
+func nowInMilliseconds() int64 {
+	return time.Now().UnixNano() / 1000000
+}
+
+// isLFUEnabled returns true if the LastAccess fields keep the access counters.
+func (dm *DMap) isLFUEnabled() bool {
+	return dm.config != nil && dm.config.evictionPolicy == config.LFUEviction
+}
+
+// checkLFUSupport returns an error if the storage engine cannot keep the access counters.
+func (dm *DMap) checkLFUSupport() error {
+	if !dm.isLFUEnabled() {
+		return nil
+	}
+	if _, ok := dm.engine.(storage.LastAccessUpdater); !ok {
+		return fmt.Errorf("storage engine: %s doesn't support %s eviction policy",
+			dm.engine.Name(), config.LFUEviction)
+	}
+	return nil
+}
+
+// lastAccessTime returns the last access time in nanoseconds from the LastAccess
+// field of an entry.
+func (dm *DMap) lastAccessTime(lastAccess int64) int64 {
+	if !dm.isLFUEnabled() {
+		return lastAccess
+	}
+	_, lat := unpackAccessCounter(lastAccess)
+	return lat * 1000000
+}
+
+// decayedCounter returns the counter after decrementing it by the number of the
+// decay periods elapsed since the last access.
+func (dm *DMap) decayedCounter(v int64, now int64) uint8 {
+	counter, lat := unpackAccessCounter(v)
+	if lat > now {
+		// It's not an access counter. The entry has been stored before the
+		// engine started to keep the access counters.
+		return lfuInitialCounter
+	}
+	period := dm.config.lfuDecayTime.Milliseconds()
+	if period <= 0 {
+		return counter
+	}
+	periods := (now - lat) / period
+	if periods >= int64(counter) {
+		return 0
+	}
+	return counter - uint8(periods)
+}
+
+// incrementCounter increments the counter logarithmically.
+func incrementCounter(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+	base := float64(counter) - lfuInitialCounter
+	if base < 0 {
+		base = 0
+	}
+	if rand.Float64() < 1.0/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// nextAccessCounter returns the access counter of an entry after an access.
+func (dm *DMap) nextAccessCounter(v int64) int64 {
+	now := nowInMilliseconds()
+	counter := incrementCounter(dm.decayedCounter(v, now))
+	return packAccessCounter(counter, now)
+}
+
+// newAccessCounter returns the access counter of a new version of the key. The
+// previous version's counter is inherited, if there is any. It's not thread-safe.
+func (dm *DMap) newAccessCounter(f *fragment, hkey uint64) int64 {
+	entry, err := f.storage.Get(hkey)
+	if err != nil || isTombstone(entry) {
+		return packAccessCounter(lfuInitialCounter, nowInMilliseconds())
+	}
+	return dm.nextAccessCounter(entry.LastAccess())
+}
+
+// touchAccessCounter updates the access counter of the entry after a read. It's
+// only valid on the partition owner and not thread-safe.
+func (dm *DMap) touchAccessCounter(f *fragment, hkey uint64, entry storage.Entry) {
+	if !dm.isLFUEnabled() || isTombstone(entry) {
+		return
+	}
+	updater, ok := f.storage.(storage.LastAccessUpdater)
+	if !ok {
+		return
+	}
+	err := updater.UpdateLastAccess(hkey, dm.nextAccessCounter(entry.LastAccess()))
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		dm.s.log.V(3).Printf("[ERROR] Failed to update access counter of HKey: %d on %s: %v", hkey, dm.name, err)
+	}
+}
+
+type lfuItem struct {
+	HKey    uint64
+	Counter uint8
+}
+
+func (dm *DMap) evictKeyWithLFU(e *env) error {
+	var idx = 1
+	var items []lfuItem
+
+	// Warning: fragment is already locked by DMap.Put. Be sure about that before editing this function.
+
+	// Pick random items from the distributed map and sort them by their access counters.
+	now := nowInMilliseconds()
+	e.fragment.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		if idx >= dm.config.lruSamples {
+			return false
+		}
+		if isTombstone(e) {
+			// The key has already been deleted.
+			return true
+		}
+		idx++
+		i := lfuItem{
+			HKey:    hkey,
+			Counter: dm.decayedCounter(e.LastAccess(), now),
+		}
+		items = append(items, i)
+		return true
+	})
+
+	if len(items) == 0 {
+		return fmt.Errorf("nothing found to expire with LFU")
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Counter < items[j].Counter })
+	// Pick the first item to delete. It's the least frequently used item in the sample.
+	return dm.evictKey(e, items[0].HKey, "LFU")
+}
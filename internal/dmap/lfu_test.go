@@ -0,0 +1,139 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_LFU_AccessCounter(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.EvictionPolicy = config.LFUEviction
+	c.DMaps.MaxKeys = 1000000
+	c.DMaps.LRUSamples = 1000
+	c.DMaps.LFUDecayTime = 10 * time.Millisecond
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	counter, ldt := unpackAccessCounter(packAccessCounter(42, 1234))
+	require.Equal(t, uint8(42), counter)
+	require.Equal(t, int64(1234), ldt)
+
+	// A new key starts with the initial counter and the first access always increments it.
+	require.Equal(t, uint8(lfuInitialCounter+1), incrementCounter(lfuInitialCounter))
+	require.Equal(t, uint8(255), incrementCounter(255))
+
+	// The counter is decremented by one for every elapsed decay period.
+	v := packAccessCounter(10, 1000)
+	require.Equal(t, uint8(10), dm.decayedCounter(v, 1009))
+	require.Equal(t, uint8(7), dm.decayedCounter(v, 1035))
+	require.Equal(t, uint8(0), dm.decayedCounter(v, 2000))
+}
+
+func TestDMap_LFU_Evict_Least_Frequently_Used(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.EvictionPolicy = config.LFUEviction
+	c.DMaps.MaxKeys = 1000000
+	c.DMaps.LRUSamples = 1000
+	c.DMaps.LFUDecayTime = time.Hour
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	// Find some keys on the same partition.
+	var keys []string
+	partID := dm.getPartitionByHKey(dm.hkey(testutil.ToKey(0)), partitions.PRIMARY).ID()
+	for i := 0; len(keys) < 10; i++ {
+		key := testutil.ToKey(i)
+		if dm.getPartitionByHKey(dm.hkey(key), partitions.PRIMARY).ID() == partID {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		require.NoError(t, dm.Put(key, key))
+	}
+
+	// The first key is never read.
+	for _, key := range keys[1:] {
+		for i := 0; i < 10; i++ {
+			_, err := dm.Get(key)
+			require.NoError(t, err)
+		}
+	}
+
+	// Overwriting a key keeps its access counter.
+	require.NoError(t, dm.Put(keys[1], "updated"))
+
+	part := dm.getPartitionByHKey(dm.hkey(keys[0]), partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	require.NoError(t, err)
+
+	e := &env{
+		ctx:      context.Background(),
+		dmap:     dm.name,
+		fragment: f,
+	}
+	f.Lock()
+	err = dm.evictKeyWithLFU(e)
+	f.Unlock()
+	require.NoError(t, err)
+
+	_, err = dm.Get(keys[0])
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	for _, key := range keys[1:] {
+		_, err = dm.Get(key)
+		require.NoError(t, err)
+	}
+}
+
+func TestDMap_LFU_LastAccess(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.EvictionPolicy = config.LFUEviction
+	c.DMaps.MaxKeys = 1000000
+	c.DMaps.LRUSamples = 1000
+	c.DMaps.LFUDecayTime = time.Hour
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	before := time.Now().UnixNano()
+	require.NoError(t, dm.Put("mykey", "myvalue"))
+	_, err = dm.Get("mykey")
+	require.NoError(t, err)
+
+	// LastAccess keeps the access counter, but the entry still reports the last access time.
+	entry, err := dm.GetEntry("mykey")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, entry.LastAccess, before/1000000*1000000)
+	require.LessOrEqual(t, entry.LastAccess, time.Now().UnixNano())
+}
@@ -0,0 +1,185 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons of the eviction events.
+const (
+	// EvictionReasonEvicted means that the entry is evicted by LRU, LFU or the
+	// memory budget of the node.
+	EvictionReasonEvicted = "evicted"
+
+	// EvictionReasonExpired means that the TTL of the entry is exceeded.
+	EvictionReasonExpired = "expired"
+
+	// EvictionReasonIdle means that the entry is idle longer than MaxIdleDuration.
+	EvictionReasonIdle = "idle"
+)
+
+// evictionListenerQueueSize is the number of the eviction events that can wait
+// to be dispatched. The events are dropped if the queue is full, the eviction is
+// never blocked by the listeners.
+const evictionListenerQueueSize = 1024
+
+// EvictionEvent is passed to the eviction listeners when an entry is removed
+// by the eviction mechanisms.
+type EvictionEvent struct {
+	Key    string
+	Value  interface{}
+	Reason string
+}
+
+type pendingEviction struct {
+	key    string
+	value  []byte
+	reason string
+}
+
+// evictionListeners keeps the eviction listeners of a DMap and the events that
+// wait to be dispatched.
+type evictionListeners struct {
+	sync.Mutex
+
+	m       map[uint64]func(EvictionEvent)
+	queue   []pendingEviction
+	notify  chan struct{}
+	running bool
+	dropped uint64
+}
+
+// AddEvictionListener registers a function that's called whenever an entry is
+// evicted or expired on this member. The function receives the key, the last
+// value and the reason. The functions are called sequentially by a background
+// goroutine, so they never block the eviction. It returns a registration ID.
+func (dm *DMap) AddEvictionListener(f func(EvictionEvent)) uint64 {
+	dm.listeners.Lock()
+	defer dm.listeners.Unlock()
+
+	if dm.listeners.m == nil {
+		dm.listeners.m = make(map[uint64]func(EvictionEvent))
+	}
+	if !dm.listeners.running {
+		dm.listeners.notify = make(chan struct{}, 1)
+		dm.listeners.running = true
+		dm.s.wg.Add(1)
+		go dm.evictionListenerWorker()
+	}
+
+	listenerID := rand.Uint64()
+	dm.listeners.m[listenerID] = f
+	return listenerID
+}
+
+// DroppedEvictionEvents returns the number of the eviction events that are
+// dropped because the queue of the listeners was full.
+func (dm *DMap) DroppedEvictionEvents() uint64 {
+	return atomic.LoadUint64(&dm.listeners.dropped)
+}
+
+// RemoveEvictionListener removes the eviction listener with the given registration ID.
+func (dm *DMap) RemoveEvictionListener(listenerID uint64) {
+	dm.listeners.Lock()
+	defer dm.listeners.Unlock()
+
+	delete(dm.listeners.m, listenerID)
+}
+
+// notifyEvictionListeners queues an eviction event, if there is any listener.
+// value is the serialized value. It's called on the partition owner.
+func (dm *DMap) notifyEvictionListeners(key string, value []byte, reason string) {
+	dm.listeners.Lock()
+	defer dm.listeners.Unlock()
+
+	if len(dm.listeners.m) == 0 {
+		return
+	}
+	if len(dm.listeners.queue) >= evictionListenerQueueSize {
+		atomic.AddUint64(&dm.listeners.dropped, 1)
+		dm.s.log.V(3).Printf("[ERROR] Eviction listener queue is full, dropped %s event for key: %s on DMap: %s", reason, key, dm.name)
+		return
+	}
+	// The value may point to the memory of the storage engine.
+	v := make([]byte, len(value))
+	copy(v, value)
+	dm.listeners.queue = append(dm.listeners.queue, pendingEviction{
+		key:    key,
+		value:  v,
+		reason: reason,
+	})
+
+	select {
+	case dm.listeners.notify <- struct{}{}:
+	default:
+		// The worker has already been notified.
+	}
+}
+
+func (dm *DMap) dispatchEvictionEvents() {
+	dm.listeners.Lock()
+	queue := dm.listeners.queue
+	dm.listeners.queue = nil
+	listeners := make([]func(EvictionEvent), 0, len(dm.listeners.m))
+	for _, f := range dm.listeners.m {
+		listeners = append(listeners, f)
+	}
+	dm.listeners.Unlock()
+
+	for _, p := range queue {
+		value, err := dm.unmarshalValue(p.value)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to unmarshal value for eviction listener: %s on DMap: %s: %v", p.key, dm.name, err)
+			continue
+		}
+		event := EvictionEvent{
+			Key:    p.key,
+			Value:  value,
+			Reason: p.reason,
+		}
+		for _, f := range listeners {
+			dm.runEvictionListener(f, event)
+		}
+	}
+}
+
+// runEvictionListener calls an eviction listener. A panic is recovered and logged,
+// it doesn't stop the worker.
+func (dm *DMap) runEvictionListener(f func(EvictionEvent), event EvictionEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			dm.s.log.V(3).Printf("[ERROR] Eviction listener panicked for key: %s on DMap: %s: %v\n%s",
+				event.Key, dm.name, r, debug.Stack())
+		}
+	}()
+	f(event)
+}
+
+func (dm *DMap) evictionListenerWorker() {
+	defer dm.s.wg.Done()
+
+	for {
+		select {
+		case <-dm.listeners.notify:
+			dm.dispatchEvictionEvents()
+		case <-dm.s.ctx.Done():
+			return
+		}
+	}
+}
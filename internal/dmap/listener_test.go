@@ -0,0 +1,229 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type evictionEventCollector struct {
+	mtx    sync.Mutex
+	events map[string]EvictionEvent
+}
+
+func (c *evictionEventCollector) add(event EvictionEvent) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.events[event.Key] = event
+}
+
+func (c *evictionEventCollector) len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.events)
+}
+
+func TestDMap_EvictionListener_Expired(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	collector := &evictionEventCollector{events: make(map[string]EvictionEvent)}
+	dm.AddEvictionListener(collector.add)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.PutEx(testutil.ToKey(i), testutil.ToVal(i), time.Millisecond))
+	}
+
+	<-time.After(10 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.evictKeys()
+	}
+
+	require.Eventually(t, func() bool { return collector.len() > 0 }, time.Second, 10*time.Millisecond)
+	collector.mtx.Lock()
+	defer collector.mtx.Unlock()
+	for key, event := range collector.events {
+		require.Equal(t, EvictionReasonExpired, event.Reason)
+		_, err := dm.Get(key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		var i int
+		for ; testutil.ToKey(i) != key; i++ {
+		}
+		require.Equal(t, testutil.ToVal(i), event.Value)
+	}
+}
+
+func TestDMap_EvictionListener_Idle(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.MaxIdleDuration = 10 * time.Millisecond
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	collector := &evictionEventCollector{events: make(map[string]EvictionEvent)}
+	dm.AddEvictionListener(collector.add)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	<-time.After(15 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.evictKeys()
+	}
+
+	require.Eventually(t, func() bool { return collector.len() > 0 }, time.Second, 10*time.Millisecond)
+	collector.mtx.Lock()
+	defer collector.mtx.Unlock()
+	for _, event := range collector.events {
+		require.Equal(t, EvictionReasonIdle, event.Reason)
+	}
+}
+
+func TestDMap_EvictionListener_LRU(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.MaxKeys = 70
+	c.DMaps.EvictionPolicy = config.LRUEviction
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	collector := &evictionEventCollector{events: make(map[string]EvictionEvent)}
+	dm.AddEvictionListener(collector.add)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	require.Eventually(t, func() bool { return collector.len() > 0 }, time.Second, 10*time.Millisecond)
+	collector.mtx.Lock()
+	defer collector.mtx.Unlock()
+	for key, event := range collector.events {
+		require.Equal(t, EvictionReasonEvicted, event.Reason)
+		_, err := dm.Get(key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_RemoveEvictionListener(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	collector := &evictionEventCollector{events: make(map[string]EvictionEvent)}
+	dm.AddEvictionListener(collector.add)
+
+	var mtx sync.Mutex
+	var calls int
+	listenerID := dm.AddEvictionListener(func(EvictionEvent) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		calls++
+	})
+	dm.RemoveEvictionListener(listenerID)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.PutEx(testutil.ToKey(i), testutil.ToVal(i), time.Millisecond))
+	}
+
+	<-time.After(10 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.evictKeys()
+	}
+
+	// The other listener is still registered.
+	require.Eventually(t, func() bool { return collector.len() > 0 }, time.Second, 10*time.Millisecond)
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Equal(t, 0, calls)
+}
+
+func TestDMap_EvictionListener_Queue_Full(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	collector := &evictionEventCollector{events: make(map[string]EvictionEvent)}
+	dm.AddEvictionListener(collector.add)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	dm.AddEvictionListener(func(EvictionEvent) {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+	})
+
+	value, err := s.serializer.Marshal("myvalue")
+	require.NoError(t, err)
+
+	// Block the worker with the first event.
+	dm.notifyEvictionListeners("first", value, EvictionReasonEvicted)
+	<-started
+
+	for i := 0; i < 2*evictionListenerQueueSize; i++ {
+		dm.notifyEvictionListeners(testutil.ToKey(i), value, EvictionReasonEvicted)
+	}
+	require.Equal(t, uint64(evictionListenerQueueSize), dm.DroppedEvictionEvents())
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return collector.len() == evictionListenerQueueSize+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDMap_EvictionListener_Panic(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	collector := &evictionEventCollector{events: make(map[string]EvictionEvent)}
+	dm.AddEvictionListener(collector.add)
+
+	dm.AddEvictionListener(func(EvictionEvent) {
+		panic("listener failed")
+	})
+
+	value, err := s.serializer.Marshal("myvalue")
+	require.NoError(t, err)
+
+	// The worker survives the panics and keeps dispatching the events.
+	for i := 0; i < 10; i++ {
+		dm.notifyEvictionListeners(testutil.ToKey(i), value, EvictionReasonEvicted)
+		require.Eventually(t, func() bool { return collector.len() == i+1 }, time.Second, time.Millisecond)
+	}
+}
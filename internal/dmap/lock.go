@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/neterrors"
 )
@@ -44,7 +43,7 @@ type LockContext struct {
 }
 
 // unlockKey tries to unlock the lock by verifying the lock with token.
-func (dm *DMap) unlockKey(key string, token []byte) error {
+func (dm *DMap) unlockKey(ctx context.Context, key string, token []byte) error {
 	lkey := dm.name + key
 	// Only one unlockKey should work for a given key.
 	dm.s.locker.Lock(lkey)
@@ -56,7 +55,7 @@ func (dm *DMap) unlockKey(key string, token []byte) error {
 	}()
 
 	// get the key to check its value
-	entry, err := dm.get(key)
+	entry, err := dm.get(ctx, key)
 	if errors.Is(err, ErrKeyNotFound) {
 		return ErrNoSuchLock
 	}
@@ -74,7 +73,7 @@ func (dm *DMap) unlockKey(key string, token []byte) error {
 	}
 
 	// release it.
-	err = dm.deleteKey(key)
+	err = dm.deleteKey(ctx, key)
 	if err != nil {
 		return fmt.Errorf("unlock failed because of delete: %w", err)
 	}
@@ -83,29 +82,35 @@ func (dm *DMap) unlockKey(key string, token []byte) error {
 
 // unlock takes key and token and tries to unlock the key.
 // It redirects the request to the partition owner, if required.
-func (dm *DMap) unlock(key string, token []byte) error {
-	hkey := partitions.HKey(dm.name, key)
+func (dm *DMap) unlock(ctx context.Context, key string, token []byte) error {
+	hkey := dm.hkey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
-		return dm.unlockKey(key, token)
+		return dm.unlockKey(ctx, key, token)
 	}
 
 	req := protocol.NewDMapMessage(protocol.OpUnlock)
 	req.SetDMap(dm.name)
 	req.SetKey(key)
 	req.SetValue(token)
-	_, err := dm.s.requestTo(member.String(), req)
+	_, err := dm.s.requestTo(ctx, member.String(), req)
 	return err
 }
 
 // Unlock releases the lock.
 func (l *LockContext) Unlock() error {
-	return l.dm.unlock(l.key, l.token)
+	return l.UnlockContext(context.Background())
+}
+
+// UnlockContext is like Unlock but the given context cancels the network operations.
+func (l *LockContext) UnlockContext(ctx context.Context) error {
+	return l.dm.unlock(ctx, l.key, l.token)
 }
 
 // tryLock takes a deadline and env and sets a key-value pair by using
 // PutIf or PutIfEx commands. It tries to acquire the lock 100 times per second
 // if the lock is already acquired. It returns ErrLockNotAcquired if the deadline exceeds.
+// It stops waiting and returns the error of the context, if the context is done.
 func (dm *DMap) tryLock(e *env, deadline time.Duration) error {
 	err := dm.put(e)
 	if err == nil {
@@ -143,6 +148,9 @@ LOOP:
 		case <-ctx.Done():
 			// Deadline exceeded. Quit with an error.
 			return ErrLockNotAcquired
+		case <-e.ctx.Done():
+			// The caller has given up.
+			return e.ctx.Err()
 		case <-dm.s.ctx.Done():
 			return fmt.Errorf("server is gone")
 		}
@@ -151,13 +159,13 @@ LOOP:
 }
 
 // lockKey prepares a token and env calls tryLock
-func (dm *DMap) lockKey(opcode protocol.OpCode, key string, timeout, deadline time.Duration) (*LockContext, error) {
+func (dm *DMap) lockKey(ctx context.Context, opcode protocol.OpCode, key string, timeout, deadline time.Duration) (*LockContext, error) {
 	token := make([]byte, 16)
 	_, err := rand.Read(token)
 	if err != nil {
 		return nil, err
 	}
-	e, err := dm.prepareAndSerialize(opcode, key, token, timeout, IfNotFound)
+	e, err := dm.prepareAndSerialize(ctx, opcode, key, token, timeout, IfNotFound)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +187,13 @@ func (dm *DMap) lockKey(opcode protocol.OpCode, key string, timeout, deadline ti
 //
 // You should know that the locks are approximate, and only to be used for non-critical purposes.
 func (dm *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*LockContext, error) {
-	return dm.lockKey(protocol.OpPutIfEx, key, timeout, deadline)
+	return dm.LockWithTimeoutContext(context.Background(), key, timeout, deadline)
+}
+
+// LockWithTimeoutContext is like LockWithTimeout but the given context cancels
+// the network operations and waiting for the lock.
+func (dm *DMap) LockWithTimeoutContext(ctx context.Context, key string, timeout, deadline time.Duration) (*LockContext, error) {
+	return dm.lockKey(ctx, protocol.OpPutIfEx, key, timeout, deadline)
 }
 
 // Lock sets a lock for the given key. Acquired lock is only for the key in this dmap.
@@ -188,13 +202,19 @@ func (dm *DMap) LockWithTimeout(key string, timeout, deadline time.Duration) (*L
 //
 // You should know that the locks are approximate, and only to be used for non-critical purposes.
 func (dm *DMap) Lock(key string, deadline time.Duration) (*LockContext, error) {
-	return dm.lockKey(protocol.OpPutIf, key, nilTimeout, deadline)
+	return dm.LockContext(context.Background(), key, deadline)
+}
+
+// LockContext is like Lock but the given context cancels the network operations
+// and waiting for the lock.
+func (dm *DMap) LockContext(ctx context.Context, key string, deadline time.Duration) (*LockContext, error) {
+	return dm.lockKey(ctx, protocol.OpPutIf, key, nilTimeout, deadline)
 }
 
 // leaseKey tries to update the expiry of the key by verifying token.
-func (dm *DMap) leaseKey(key string, token []byte, timeout time.Duration) error {
+func (dm *DMap) leaseKey(ctx context.Context, key string, token []byte, timeout time.Duration) error {
 	// get the key to check its value
-	entry, err := dm.get(key)
+	entry, err := dm.get(ctx, key)
 	if errors.Is(err, ErrKeyNotFound) {
 		return ErrNoSuchLock
 	}
@@ -217,7 +237,7 @@ func (dm *DMap) leaseKey(key string, token []byte, timeout time.Duration) error
 	}
 
 	// update
-	err = dm.Expire(key, timeout)
+	err = dm.ExpireContext(ctx, key, timeout)
 	if err != nil {
 		return fmt.Errorf("lease failed: %w", err)
 	}
@@ -227,10 +247,15 @@ func (dm *DMap) leaseKey(key string, token []byte, timeout time.Duration) error
 // lease takes key and token and tries to update the expiry with duration.
 // It redirects the request to the partition owner, if required.
 func (dm *DMap) Lease(key string, token []byte, duration time.Duration) error {
-	hkey := partitions.HKey(dm.name, key)
+	return dm.LeaseContext(context.Background(), key, token, duration)
+}
+
+// LeaseContext is like Lease but the given context cancels the network operations.
+func (dm *DMap) LeaseContext(ctx context.Context, key string, token []byte, duration time.Duration) error {
+	hkey := dm.hkey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
-		return dm.leaseKey(key, token, duration)
+		return dm.leaseKey(ctx, key, token, duration)
 	}
 
 	req := protocol.NewDMapMessage(protocol.OpLockLease)
@@ -240,11 +265,16 @@ func (dm *DMap) Lease(key string, token []byte, duration time.Duration) error {
 	req.SetExtra(protocol.LockLeaseExtra{
 		Timeout: int64(duration),
 	})
-	_, err := dm.s.requestTo(member.String(), req)
+	_, err := dm.s.requestTo(ctx, member.String(), req)
 	return err
 }
 
 // Lease takes the duration to update the expiry for the given Lock.
 func (l *LockContext) Lease(duration time.Duration) error {
-	return l.dm.Lease(l.key, l.token, duration)
+	return l.LeaseContext(context.Background(), duration)
+}
+
+// LeaseContext is like Lease but the given context cancels the network operations.
+func (l *LockContext) LeaseContext(ctx context.Context, duration time.Duration) error {
+	return l.dm.LeaseContext(ctx, l.key, l.token, duration)
 }
@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"time"
 
 	"github.com/buraksezer/olric/internal/protocol"
@@ -43,7 +44,7 @@ func (s *Service) lockWithTimeoutOperation(w, r protocol.EncodeDecoder) {
 		req := r.(*protocol.DMapMessage)
 		timeout := req.Extra().(protocol.LockWithTimeoutExtra).Timeout
 		deadline := req.Extra().(protocol.LockWithTimeoutExtra).Deadline
-		return dm.lockKey(protocol.OpPutIfEx, req.Key(), time.Duration(timeout), time.Duration(deadline))
+		return dm.lockKey(context.Background(), protocol.OpPutIfEx, req.Key(), time.Duration(timeout), time.Duration(deadline))
 	})
 }
 
@@ -51,7 +52,7 @@ func (s *Service) lockOperation(w, r protocol.EncodeDecoder) {
 	s.lockOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) (*LockContext, error) {
 		req := r.(*protocol.DMapMessage)
 		deadline := req.Extra().(protocol.LockExtra).Deadline
-		return dm.lockKey(protocol.OpPutIf, req.Key(), nilTimeout, time.Duration(deadline))
+		return dm.lockKey(context.Background(), protocol.OpPutIf, req.Key(), nilTimeout, time.Duration(deadline))
 	})
 }
 
@@ -62,7 +63,7 @@ func (s *Service) unlockOperation(w, r protocol.EncodeDecoder) {
 		neterrors.ErrorResponse(w, err)
 		return
 	}
-	err = dm.unlock(req.Key(), req.Value())
+	err = dm.unlock(context.Background(), req.Key(), req.Value())
 	if err != nil {
 		neterrors.ErrorResponse(w, err)
 		return
@@ -16,6 +16,7 @@ package dmap
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"strconv"
 	"testing"
@@ -164,6 +165,34 @@ func TestDMap_Lock_ErrLockNotAcquired_Standalone(t *testing.T) {
 	}
 }
 
+func TestDMap_LockContext_Canceled_Standalone(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	key := "lock.test.foo"
+	dm, err := s.NewDMap("lock.test")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	_, err = dm.Lock(key, time.Second)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = dm.LockContext(ctx, key, time.Minute)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded. Got: %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatalf("LockContext didn't return after the context is done")
+	}
+}
+
 func TestDMap_LockWithTimeout_Cluster(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	s1 := cluster.AddMember(nil).(*Service)
@@ -0,0 +1,136 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// loadOnCluster loads a missing key with the MapLoader of the DMap and stores it
+// on the cluster. Concurrent calls for the same key share a single Load call, they
+// run with the context of the first caller. It's called on the partition owner.
+func (dm *DMap) loadOnCluster(ctx context.Context, hkey uint64, key string) (storage.Entry, error) {
+	v, err, _ := dm.loadGroup.Do(key, func() (interface{}, error) {
+		// The key may have been loaded just before this call.
+		entry, err := dm.getOnCluster(ctx, hkey, key)
+		if !errors.Is(err, ErrKeyNotFound) {
+			return entry, err
+		}
+
+		// The backing store is behind the pending writes in write-behind mode.
+		entry, ok, err := dm.pendingWrite(hkey, key)
+		if ok || err != nil {
+			return entry, err
+		}
+
+		value, err := dm.config.loader.Load(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, ErrKeyNotFound
+		}
+
+		e, err := dm.prepareAndSerialize(ctx, protocol.OpPut, key, value, nilTimeout, 0)
+		if err != nil {
+			return nil, err
+		}
+		e.hkey = hkey
+		e.loaded = true
+		if err = dm.putOnCluster(e); err != nil {
+			return nil, err
+		}
+
+		// putOnCluster sets the timestamp and the default TTL.
+		entry = dm.engine.NewEntry()
+		entry.SetKey(key)
+		entry.SetValue(e.value)
+		entry.SetTTL(timeoutToTTL(e.timeout))
+		entry.SetTimestamp(e.timestamp)
+		return entry, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(storage.Entry), nil
+}
+
+// pendingWrite returns the entry of a pending write on the key in write-behind
+// mode. A pending delete returns ErrKeyNotFound. ok is false, if there is no
+// pending write on the key.
+func (dm *DMap) pendingWrite(hkey uint64, key string) (entry storage.Entry, ok bool, err error) {
+	if !dm.isWriteBehindEnabled() {
+		return nil, false, nil
+	}
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if f.writeBehind == nil {
+		return nil, false, nil
+	}
+	pending, ok := f.writeBehind.get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if pending.Value == nil {
+		return nil, true, ErrKeyNotFound
+	}
+	entry = dm.engine.NewEntry()
+	entry.SetKey(key)
+	entry.SetValue(pending.Value)
+	entry.SetTimestamp(pending.Timestamp)
+	return entry, true, nil
+}
+
+// storeOnMapStore persists the key/value pair with the MapStore of the DMap, if
+// there is any. The values that have been read from the MapLoader are skipped.
+// In write-behind mode, the write is queued on the fragment. It's not thread-safe.
+func (dm *DMap) storeOnMapStore(e *env) error {
+	if dm.config.store == nil || e.loaded {
+		return nil
+	}
+	if e.fragment.writeBehind != nil {
+		e.fragment.writeBehind.add(writeBehindEntry{Key: e.key, Value: e.value, Timestamp: e.timestamp})
+		return nil
+	}
+	value, err := dm.unmarshalValue(e.value)
+	if err != nil {
+		return err
+	}
+	return dm.config.store.Store(e.ctx, e.key, value)
+}
+
+// deleteOnMapStore deletes the key with the MapStore of the DMap, if there is any.
+// In write-behind mode, the delete is queued on the fragment. It's not thread-safe.
+func (dm *DMap) deleteOnMapStore(ctx context.Context, f *fragment, key string) error {
+	if dm.config.store == nil {
+		return nil
+	}
+	if f.writeBehind != nil {
+		f.writeBehind.add(writeBehindEntry{Key: key, Timestamp: dm.s.clock.Now()})
+		return nil
+	}
+	return dm.config.store.Delete(ctx, key)
+}
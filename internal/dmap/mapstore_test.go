@@ -0,0 +1,174 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type testMapStore struct {
+	mtx    sync.Mutex
+	m      map[string]interface{}
+	loads  int32
+	stores int32
+	fail   bool
+}
+
+func newTestMapStore() *testMapStore {
+	return &testMapStore{m: make(map[string]interface{})}
+}
+
+func (s *testMapStore) Load(_ context.Context, key string) (interface{}, error) {
+	atomic.AddInt32(&s.loads, 1)
+	// Give the concurrent callers a chance to pile up.
+	<-time.After(50 * time.Millisecond)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.m[key], nil
+}
+
+func (s *testMapStore) Store(_ context.Context, key string, value interface{}) error {
+	atomic.AddInt32(&s.stores, 1)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.fail {
+		return errors.New("backing store is down")
+	}
+	s.m[key] = value
+	return nil
+}
+
+func (s *testMapStore) Delete(_ context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.fail {
+		return errors.New("backing store is down")
+	}
+	delete(s.m, key)
+	return nil
+}
+
+func (s *testMapStore) setFail(fail bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.fail = fail
+}
+
+func (s *testMapStore) get(key string) (interface{}, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	value, ok := s.m[key]
+	return value, ok
+}
+
+func TestDMap_MapLoader(t *testing.T) {
+	ms := newTestMapStore()
+	ms.m["mykey"] = "myvalue"
+
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.DMaps.Custom = map[string]config.DMap{"mymap": {Loader: ms, Store: ms, TTLDuration: time.Hour}}
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.DMaps.Custom = map[string]config.DMap{"mymap": {Loader: ms, Store: ms, TTLDuration: time.Hour}}
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		wg.Add(1)
+		go func(dm *DMap) {
+			defer wg.Done()
+			value, err := dm.Get("mykey")
+			require.NoError(t, err)
+			require.Equal(t, "myvalue", value)
+		}(dm)
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&ms.loads))
+
+	// The loaded value is stored with the TTL of the DMap.
+	entry, err := dm1.GetEntry("mykey")
+	require.NoError(t, err)
+	require.NotZero(t, entry.TTL)
+	require.Equal(t, int32(1), atomic.LoadInt32(&ms.loads))
+
+	_, err = dm1.Get("missing-key")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_MapStore(t *testing.T) {
+	ms := newTestMapStore()
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.DMaps.Custom = map[string]config.DMap{"mymap": {Loader: ms, Store: ms}}
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.DMaps.Custom = map[string]config.DMap{"mymap": {Loader: ms, Store: ms}}
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		value, ok := ms.get(testutil.ToKey(i))
+		require.True(t, ok)
+		require.Equal(t, i, value)
+	}
+
+	for i := 0; i < 10; i++ {
+		err = dm2.Delete(testutil.ToKey(i))
+		require.NoError(t, err)
+		_, ok := ms.get(testutil.ToKey(i))
+		require.False(t, ok)
+	}
+
+	// Writes fail without modifying the DMap if the backing store fails.
+	ms.setFail(true)
+	err = dm1.Put("mykey", "myvalue")
+	require.Error(t, err)
+	ms.setFail(false)
+
+	_, err = dm2.Get("mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
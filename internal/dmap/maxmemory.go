@@ -0,0 +1,341 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrOutOfMemory means that the memory budget of the node is exceeded and no key can be evicted.
+var ErrOutOfMemory = neterrors.New(protocol.StatusErrOutOfMemory, "out of memory")
+
+const (
+	// maxMemoryCheckInterval is the interval between two sequential calls of
+	// the memory budget worker.
+	maxMemoryCheckInterval = 100 * time.Millisecond
+
+	// maxMemoryEvictionsPerRound limits the number of the evicted keys in a round
+	// to prevent CPU starvation.
+	maxMemoryEvictionsPerRound = 1000
+)
+
+// memoryBudget tracks the in-use memory of the primary fragments on this node.
+type memoryBudget struct {
+	// usage is recalculated by the memory budget worker. The writes on the
+	// primary fragments update it in the meantime.
+	usage int64
+	// exhausted is set if the budget is exceeded and the worker could not find
+	// a key to evict.
+	exhausted int32
+	wakeUp    chan struct{}
+}
+
+func newMemoryBudget() *memoryBudget {
+	return &memoryBudget{
+		wakeUp: make(chan struct{}, 1),
+	}
+}
+
+func (m *memoryBudget) notify() {
+	select {
+	case m.wakeUp <- struct{}{}:
+	default:
+		// The worker has already been notified.
+	}
+}
+
+// isNoEvictionPolicy returns true if the writes fail instead of evicting keys.
+func isNoEvictionPolicy(policy config.EvictionPolicy) bool {
+	return policy == "" || policy == "NONE"
+}
+
+func isVolatilePolicy(policy config.EvictionPolicy) bool {
+	return policy == config.VolatileTTLEviction ||
+		policy == config.VolatileLRUEviction ||
+		policy == config.VolatileRandomEviction
+}
+
+// memoryEvictionScore returns the score of the entry for MaxMemoryPolicy. The
+// entry with the lowest score is evicted first. It returns false if the entry
+// cannot be evicted by the policy.
+func (dm *DMap) memoryEvictionScore(policy config.EvictionPolicy, entry storage.Entry) (int64, bool) {
+	if isTombstone(entry) {
+		return 0, false
+	}
+	if isVolatilePolicy(policy) && entry.TTL() == 0 {
+		return 0, false
+	}
+	switch policy {
+	case config.VolatileTTLEviction:
+		return entry.TTL(), true
+	case config.VolatileLRUEviction, config.AllKeysLRUEviction:
+		// LFU DMaps keep an access counter in the same field.
+		return dm.lastAccessTime(entry.LastAccess()), true
+	default:
+		// Random policies evict the first sampled key.
+		return 0, true
+	}
+}
+
+// sampleForMemoryEviction samples keys from the fragment and returns the key with
+// the lowest score. It's not thread-safe.
+func (dm *DMap) sampleForMemoryEviction(f *fragment, skip uint64) (uint64, int64, bool) {
+	policy := dm.s.config.DMaps.MaxMemoryPolicy
+	var hkey uint64
+	var score int64
+	var found bool
+	var idx int
+	f.storage.Range(func(h uint64, entry storage.Entry) bool {
+		if idx >= dm.s.config.DMaps.LRUSamples {
+			return false
+		}
+		idx++
+		if h == skip {
+			return true
+		}
+		sc, ok := dm.memoryEvictionScore(policy, entry)
+		if !ok {
+			return true
+		}
+		if !found || sc < score {
+			hkey, score, found = h, sc, true
+		}
+		// A random key is enough.
+		return policy != config.AllKeysRandomEviction && policy != config.VolatileRandomEviction
+	})
+	return hkey, score, found
+}
+
+// isBudgeted returns true if the fragment counts toward the memory budget. Inuse
+// of the disk storage engine is the size of the data on disk.
+func isBudgeted(f *fragment) bool {
+	return f.storage.Name() != config.DiskStorageEngine
+}
+
+// memoryUsage returns the in-use memory of the primary fragments on this node. The
+// backup fragments are not counted, the keys can only be evicted by the primary owners.
+func (s *Service) memoryUsage() int64 {
+	var usage int64
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		s.primary.PartitionByID(partID).Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+			f := tmp.(*fragment)
+			if !isBudgeted(f) {
+				return true
+			}
+			f.RLock()
+			usage += int64(f.storage.Stats().Inuse)
+			f.RUnlock()
+			return true
+		})
+	}
+	return usage
+}
+
+// fragmentOwner returns the DMap of the given fragment. The access times are
+// decoded by the DMap.
+func (s *Service) fragmentOwner(name string) (*DMap, bool) {
+	dm, err := s.getOrCreateDMap(strings.TrimPrefix(name, "dmap."))
+	if err != nil {
+		s.log.V(3).Printf("[ERROR] Failed to load DMap: %s: %v", name, err)
+		return nil, false
+	}
+	return dm, true
+}
+
+// evictKeyForMemory samples keys from random primary fragments on this node and
+// evicts the key with the lowest score. It returns the freed memory and false if
+// there is no key to evict.
+func (s *Service) evictKeyForMemory() (int64, bool) {
+	var candidate *fragment
+	var dm *DMap
+	var hkey uint64
+	var score int64
+	for i := 0; i < s.config.DMaps.LRUSamples; i++ {
+		part := s.primary.PartitionByID(uint64(rand.Intn(int(s.config.PartitionCount))))
+		part.Map().Range(func(n, tmp interface{}) bool {
+			if !strings.HasPrefix(n.(string), "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+			f := tmp.(*fragment)
+			if !isBudgeted(f) {
+				return true
+			}
+			owner, ok := s.fragmentOwner(n.(string))
+			if !ok {
+				return true
+			}
+			f.Lock()
+			h, sc, ok := owner.sampleForMemoryEviction(f, 0)
+			f.Unlock()
+			if ok && (candidate == nil || sc < score) {
+				candidate, dm, hkey, score = f, owner, h, sc
+			}
+			return true
+		})
+	}
+	if candidate == nil {
+		// Try all the partitions before giving up.
+		for partID := uint64(0); partID < s.config.PartitionCount && candidate == nil; partID++ {
+			s.primary.PartitionByID(partID).Map().Range(func(n, tmp interface{}) bool {
+				if !strings.HasPrefix(n.(string), "dmap.") {
+					return true
+				}
+				f := tmp.(*fragment)
+				if !isBudgeted(f) {
+					return true
+				}
+				owner, ok := s.fragmentOwner(n.(string))
+				if !ok {
+					return true
+				}
+				f.Lock()
+				h, _, ok := owner.sampleForMemoryEviction(f, 0)
+				f.Unlock()
+				if ok {
+					candidate, dm, hkey = f, owner, h
+					return false
+				}
+				return true
+			})
+		}
+	}
+	if candidate == nil {
+		return 0, false
+	}
+
+	candidate.Lock()
+	defer candidate.Unlock()
+	select {
+	case <-candidate.ctx.Done():
+		// The fragment is closed. It will be tried again.
+		return 0, true
+	default:
+	}
+	inuse := int64(candidate.storage.Stats().Inuse)
+	e := &env{
+		ctx:      s.ctx,
+		dmap:     dm.name,
+		fragment: candidate,
+	}
+	err := dm.evictKey(e, hkey, string(s.config.DMaps.MaxMemoryPolicy))
+	if err != nil {
+		if s.log.V(6).Ok() {
+			s.log.V(6).Printf("[DEBUG] Failed to evict key on DMap: %s: %v", dm.name, err)
+		}
+		return 0, true
+	}
+	return inuse - int64(candidate.storage.Stats().Inuse), true
+}
+
+// enforceMaxMemory recalculates the in-use memory and evicts keys until it
+// fits in the memory budget.
+func (s *Service) enforceMaxMemory() {
+	maxMemory := int64(s.config.DMaps.MaxMemory)
+	usage := s.memoryUsage()
+	atomic.StoreInt64(&s.memory.usage, usage)
+	if usage <= maxMemory || isNoEvictionPolicy(s.config.DMaps.MaxMemoryPolicy) {
+		atomic.StoreInt32(&s.memory.exhausted, 0)
+		return
+	}
+
+	for i := 0; usage > maxMemory && i < maxMemoryEvictionsPerRound; i++ {
+		if !s.isAlive() {
+			return
+		}
+		freed, ok := s.evictKeyForMemory()
+		if !ok {
+			atomic.StoreInt32(&s.memory.exhausted, 1)
+			return
+		}
+		usage -= freed
+		atomic.AddInt64(&s.memory.usage, -freed)
+	}
+	atomic.StoreInt32(&s.memory.exhausted, 0)
+}
+
+func (s *Service) maxMemoryWorker() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(maxMemoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.memory.wakeUp:
+		case <-s.ctx.Done():
+			return
+		}
+		s.enforceMaxMemory()
+	}
+}
+
+// checkMaxMemory makes room for the new key on the fragment if the memory budget
+// of the node is exceeded. It's not thread-safe, the caller has to acquire the
+// fragment's lock.
+func (dm *DMap) checkMaxMemory(e *env) error {
+	maxMemory := int64(dm.s.config.DMaps.MaxMemory)
+	if maxMemory == 0 || !isBudgeted(e.fragment) {
+		return nil
+	}
+	usage := atomic.LoadInt64(&dm.s.memory.usage)
+	if usage+int64(len(e.key)+len(e.value)) <= maxMemory {
+		return nil
+	}
+	if isNoEvictionPolicy(dm.s.config.DMaps.MaxMemoryPolicy) {
+		return ErrOutOfMemory
+	}
+
+	// Other fragments are locked by their own writers, so try this one first.
+	hkey, _, ok := dm.sampleForMemoryEviction(e.fragment, e.hkey)
+	if ok {
+		inuse := int64(e.fragment.storage.Stats().Inuse)
+		if err := dm.evictKey(e, hkey, string(dm.s.config.DMaps.MaxMemoryPolicy)); err != nil {
+			return err
+		}
+		atomic.AddInt64(&dm.s.memory.usage, int64(e.fragment.storage.Stats().Inuse)-inuse)
+		return nil
+	}
+	if atomic.LoadInt32(&dm.s.memory.exhausted) == 1 {
+		return ErrOutOfMemory
+	}
+	// The worker evicts the keys on the other fragments.
+	dm.s.memory.notify()
+	return nil
+}
+
+// trackMemoryUsage returns a function that adds the in-use memory change of the
+// fragment to the memory usage of the node. It's not thread-safe.
+func (dm *DMap) trackMemoryUsage(e *env) func() {
+	if dm.s.config.DMaps.MaxMemory == 0 || !isBudgeted(e.fragment) {
+		return func() {}
+	}
+	inuse := int64(e.fragment.storage.Stats().Inuse)
+	return func() {
+		atomic.AddInt64(&dm.s.memory.usage, int64(e.fragment.storage.Stats().Inuse)-inuse)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/diskstore"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_MaxMemory_AllKeysRandom(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxMemory = 20000
+	c.DMaps.MaxMemoryPolicy = config.AllKeysRandomEviction
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	// The keys are spread over the DMaps, every DMap is below its own limits.
+	for _, name := range []string{"mymap-1", "mymap-2"} {
+		dm, err := s.NewDMap(name)
+		require.NoError(t, err)
+		for i := 0; i < 500; i++ {
+			require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+		}
+	}
+
+	<-time.After(3 * maxMemoryCheckInterval)
+	require.LessOrEqual(t, s.memoryUsage(), int64(20000))
+
+	var total int
+	for _, name := range []string{"mymap-1", "mymap-2"} {
+		dm, err := s.NewDMap(name)
+		require.NoError(t, err)
+		n, err := dm.Len()
+		require.NoError(t, err)
+		total += n
+	}
+	require.Greater(t, total, 0)
+	require.Less(t, total, 1000)
+}
+
+func TestDMap_MaxMemory_VolatileTTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxMemory = 20000
+	c.DMaps.MaxMemoryPolicy = config.VolatileTTLEviction
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	persistent, err := s.NewDMap("persistent")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, persistent.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	volatile, err := s.NewDMap("volatile")
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, volatile.PutEx(testutil.ToKey(i), testutil.ToVal(i), time.Hour+time.Duration(i)*time.Second))
+	}
+
+	<-time.After(3 * maxMemoryCheckInterval)
+	require.LessOrEqual(t, s.memoryUsage(), int64(20000))
+
+	// The keys without an expiry are never evicted.
+	for i := 0; i < 100; i++ {
+		_, err = persistent.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	n, err := volatile.Len()
+	require.NoError(t, err)
+	require.Less(t, n, 1000)
+}
+
+func TestDMap_MaxMemory_OutOfMemory(t *testing.T) {
+	for _, policy := range []config.EvictionPolicy{"NONE", config.VolatileLRUEviction} {
+		t.Run(string(policy), func(t *testing.T) {
+			cluster := testcluster.New(NewService)
+			c := testutil.NewConfig()
+			c.DMaps.MaxMemory = 5000
+			c.DMaps.MaxMemoryPolicy = policy
+			s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+			defer cluster.Shutdown()
+
+			dm, err := s.NewDMap("mymap")
+			require.NoError(t, err)
+
+			// There is no key to evict.
+			var oom bool
+			for i := 0; i < 10000; i++ {
+				err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+				if errors.Is(err, ErrOutOfMemory) {
+					oom = true
+					break
+				}
+				require.NoError(t, err)
+				if i%100 == 0 {
+					<-time.After(maxMemoryCheckInterval)
+				}
+			}
+			require.True(t, oom)
+		})
+	}
+}
+
+func TestDMap_MaxMemory_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxMemoryPolicy = config.AllKeysRandomEviction
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+	n, err := dm.Len()
+	require.NoError(t, err)
+	require.Equal(t, 1000, n)
+}
+
+func TestDMap_MaxMemory_Primary_Fragments(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.MaxMemory = 1 << 30
+		c.DMaps.MaxMemoryPolicy = config.AllKeysRandomEviction
+		return c
+	}
+	s1 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	cluster.AddMember(testcluster.NewEnvironment(newConfig()))
+	defer cluster.Shutdown()
+
+	dm, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	inuse := func(kind partitions.Kind) int64 {
+		var total int64
+		for partID := uint64(0); partID < s1.config.PartitionCount; partID++ {
+			part := s1.primary.PartitionByID(partID)
+			if kind == partitions.BACKUP {
+				part = s1.backup.PartitionByID(partID)
+			}
+			tmp, ok := part.Map().Load("dmap.mymap")
+			if ok {
+				total += int64(tmp.(*fragment).storage.Stats().Inuse)
+			}
+		}
+		return total
+	}
+
+	// The backups cannot be evicted, they are not counted.
+	require.Greater(t, inuse(partitions.BACKUP), int64(0))
+	require.Equal(t, inuse(partitions.PRIMARY), s1.memoryUsage())
+}
+
+func TestDMap_MaxMemory_DiskStore(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxMemory = 5000
+	c.DMaps.Engine.Name = config.DiskStorageEngine
+	c.DMaps.Engine.Implementation = &diskstore.DiskStore{}
+	c.DMaps.Engine.Config = map[string]interface{}{
+		"dataDir":        t.TempDir(),
+		"maxSegmentSize": 1 << 20,
+	}
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	// Inuse of the disk storage engine is on disk, the writes never fail.
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+	require.Equal(t, int64(0), s.memoryUsage())
+}
+
+func TestDMap_MaxMemory_LRU_LFU_DMap(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxMemory = 1 << 30
+	c.DMaps.MaxMemoryPolicy = config.AllKeysLRUEviction
+	c.DMaps.Custom = map[string]config.DMap{
+		"lfu": {EvictionPolicy: config.LFUEviction, MaxKeys: 1000000},
+	}
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	lfu, err := s.NewDMap("lfu")
+	require.NoError(t, err)
+	require.True(t, lfu.isLFUEnabled())
+	lru, err := s.NewDMap("lru")
+	require.NoError(t, err)
+
+	// The LFU DMap keeps an access counter in LastAccess. The key has been
+	// accessed after the key of the other DMap.
+	recent := lfu.engine.NewEntry()
+	recent.SetValue([]byte("recent"))
+	recent.SetLastAccess(packAccessCounter(5, nowInMilliseconds()))
+	old := lru.engine.NewEntry()
+	old.SetValue([]byte("old"))
+	old.SetLastAccess(time.Now().Add(-time.Minute).UnixNano())
+
+	recentScore, ok := lfu.memoryEvictionScore(config.AllKeysLRUEviction, recent)
+	require.True(t, ok)
+	oldScore, ok := lru.memoryEvictionScore(config.AllKeysLRUEviction, old)
+	require.True(t, ok)
+	require.Greater(t, recentScore, oldScore)
+}
@@ -0,0 +1,110 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/nearcache"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// keyspaceSubscriber receives messages from a DTopic on this node. It's
+// implemented by the DTopic service.
+type keyspaceSubscriber interface {
+	Subscribe(topic string, f func(msg interface{})) (uint64, error)
+}
+
+// nearCacheEntry is an entry that's owned by another member.
+type nearCacheEntry struct {
+	raw []byte
+	// owner is the ID of the partition owner, when the entry is cached.
+	owner uint64
+}
+
+// newNearCache creates the near cache of the DMap, if it's enabled. The keys are
+// invalidated by the keyspace notifications, the near cache is disabled if
+// they cannot be received.
+func (dm *DMap) newNearCache() error {
+	if dm.config.nearCache == nil || dm.s.subscriber == nil {
+		return nil
+	}
+
+	nc := nearcache.New(dm.config.nearCache)
+	_, err := dm.s.subscriber.Subscribe(KeyspaceTopic(dm.name), func(msg interface{}) {
+		event, ok := msg.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if key, ok := event["key"].(string); ok {
+			nc.Invalidate(key)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	dm.nearCache = nc
+	return nil
+}
+
+// invalidateNearCache removes the given keys from the near cache, if it's enabled.
+// It's called after the writes that are redirected to the partition owners. So
+// the subsequent reads on this member don't wait for the keyspace notifications.
+func (dm *DMap) invalidateNearCache(keys ...string) {
+	if dm.nearCache == nil {
+		return
+	}
+	for _, key := range keys {
+		dm.nearCache.Invalidate(key)
+	}
+}
+
+// getWithNearCache is like get but it serves the entries that are owned by the
+// other members from the near cache. An entry is dropped if the ownership of its
+// partition has changed since it was cached.
+func (dm *DMap) getWithNearCache(ctx context.Context, key string) (storage.Entry, error) {
+	if dm.nearCache == nil {
+		return dm.get(ctx, key)
+	}
+
+	owner := dm.s.primary.PartitionByHKey(dm.hkey(key)).Owner()
+	if owner.CompareByName(dm.s.rt.This()) {
+		return dm.get(ctx, key)
+	}
+
+	if value, ok := dm.nearCache.Get(key); ok {
+		ne := value.(*nearCacheEntry)
+		if ne.owner == owner.ID {
+			// number of keys that have been requested and found present
+			GetHits.Increase(1)
+
+			entry := dm.engine.NewEntry()
+			entry.Decode(ne.raw)
+			return entry, nil
+		}
+		dm.nearCache.Invalidate(key)
+	}
+
+	load := dm.nearCache.Begin(key)
+	entry, err := dm.get(ctx, key)
+	if err != nil {
+		dm.nearCache.Finish(key, load, nil, 0, 0)
+		return nil, err
+	}
+	raw := entry.Encode()
+	// TTL is in milliseconds.
+	dm.nearCache.Finish(key, load, &nearCacheEntry{raw: raw, owner: owner.ID}, len(raw), entry.TTL()*1000000)
+	return entry, nil
+}
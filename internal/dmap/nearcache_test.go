@@ -0,0 +1,140 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForKeyspaceEvents waits until the given number of keyspace notifications
+// are published for the DMap. Otherwise, they may invalidate the keys that are
+// cached later.
+func waitForKeyspaceEvents(t *testing.T, p *testPublisher, name string, count int) {
+	require.Eventually(t, func() bool {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return len(p.events[KeyspaceTopic(name)]) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// remoteKeys returns the keys that are owned by the other members.
+func remoteKeys(dm *DMap, n int) []string {
+	var keys []string
+	for i := 0; i < n; i++ {
+		key := testutil.ToKey(i)
+		owner := dm.s.primary.PartitionByHKey(dm.hkey(key)).Owner()
+		if !owner.CompareByName(dm.s.rt.This()) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestDMap_NearCache(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	p := newTestPublisher()
+	c1 := testutil.NewConfig()
+	c1.DMaps.NearCache = &config.NearCache{MaxKeys: 1000}
+	e1 := testcluster.NewEnvironment(c1)
+	e1.Set("dtopic", p)
+	s1 := cluster.AddMember(e1).(*Service)
+	c2 := testutil.NewConfig()
+	c2.DMaps.NearCache = &config.NearCache{MaxKeys: 1000}
+	e2 := testcluster.NewEnvironment(c2)
+	e2.Set("dtopic", p)
+	s2 := cluster.AddMember(e2).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	keys := remoteKeys(dm2, 100)
+	require.NotEmpty(t, keys)
+	for _, key := range keys {
+		require.NoError(t, dm1.Put(key, "value"))
+	}
+	waitForKeyspaceEvents(t, p, "mymap", len(keys))
+	for _, key := range keys {
+		value, err := dm2.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, "value", value)
+	}
+	require.Equal(t, len(keys), dm2.nearCache.Len())
+
+	// The partition owners invalidate the near caches.
+	for _, key := range keys {
+		require.NoError(t, dm1.Put(key, "new-value"))
+	}
+	require.Eventually(t, func() bool {
+		return dm2.nearCache.Len() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, key := range keys {
+		value, err := dm2.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, "new-value", value)
+	}
+
+	// The writes on this member invalidate its near cache immediately.
+	require.NoError(t, dm2.Delete(keys[0]))
+	_, err = dm2.Get(keys[0])
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_NearCache_Ownership_Changed(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	p := newTestPublisher()
+	c1 := testutil.NewConfig()
+	c1.DMaps.NearCache = &config.NearCache{MaxKeys: 1000}
+	e1 := testcluster.NewEnvironment(c1)
+	e1.Set("dtopic", p)
+	s1 := cluster.AddMember(e1).(*Service)
+	c2 := testutil.NewConfig()
+	c2.DMaps.NearCache = &config.NearCache{MaxKeys: 1000}
+	e2 := testcluster.NewEnvironment(c2)
+	e2.Set("dtopic", p)
+	s2 := cluster.AddMember(e2).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	key := remoteKeys(dm2, 100)[0]
+	require.NoError(t, dm1.Put(key, "value"))
+	waitForKeyspaceEvents(t, p, "mymap", 1)
+	_, err = dm2.Get(key)
+	require.NoError(t, err)
+
+	// Pretend that the entry was cached when another member owned the partition.
+	value, ok := dm2.nearCache.Get(key)
+	require.True(t, ok)
+	value.(*nearCacheEntry).owner = 0
+
+	_, err = dm2.Get(key)
+	require.NoError(t, err)
+	value, ok = dm2.nearCache.Get(key)
+	require.True(t, ok)
+	owner := s2.primary.PartitionByHKey(dm2.hkey(key)).Owner()
+	require.Equal(t, owner.ID, value.(*nearCacheEntry).owner)
+}
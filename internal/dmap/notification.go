@@ -0,0 +1,100 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/pkg/neterrors"
+)
+
+// Operations of the keyspace notifications.
+const (
+	KeyspacePut    = "put"
+	KeyspaceDelete = "delete"
+	KeyspaceExpire = "expire"
+	KeyspaceEvict  = "evict"
+)
+
+// keyspaceNotificationBufferSize is the number of notifications that can wait to
+// be published. The notifications are dropped if the buffer is full, the writes
+// are never blocked by the subscribers.
+const keyspaceNotificationBufferSize = 1024
+
+// keyspacePublisher publishes messages to a DTopic. It's implemented by the DTopic service.
+type keyspacePublisher interface {
+	Publish(ctx context.Context, topic string, msg interface{}) error
+}
+
+type keyspaceNotification struct {
+	topic string
+	event map[string]interface{}
+}
+
+// KeyspaceTopic returns the name of the DTopic that receives the keyspace
+// notifications of the given DMap.
+func KeyspaceTopic(name string) string {
+	return "__keyspace__:" + name
+}
+
+// notifyKeyspace queues a keyspace notification on the partition owner, if it's
+// enabled for the DMap. The near caches on the other members are invalidated by
+// the same notifications. value is the serialized value, it can be nil.
+func (dm *DMap) notifyKeyspace(op, key string, timestamp int64, value []byte) {
+	if !dm.config.keyspaceNotifications && dm.config.nearCache == nil {
+		return
+	}
+	if dm.s.publisher == nil {
+		return
+	}
+
+	event := map[string]interface{}{
+		"key":       key,
+		"op":        op,
+		"timestamp": timestamp,
+	}
+	if value != nil {
+		v, err := dm.unmarshalValue(value)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to unmarshal value for keyspace notification: %s on DMap: %s: %v", key, dm.name, err)
+			return
+		}
+		event["value"] = v
+	}
+
+	select {
+	case dm.s.notifications <- keyspaceNotification{topic: KeyspaceTopic(dm.name), event: event}:
+	default:
+		dm.s.log.V(3).Printf("[ERROR] Keyspace notification buffer is full, dropped %s event for key: %s on DMap: %s", op, key, dm.name)
+	}
+}
+
+func (s *Service) keyspaceNotificationWorker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case n := <-s.notifications:
+			err := s.publisher.Publish(s.ctx, n.topic, n.event)
+			// ErrInvalidArgument means that a member has no listener for the topic.
+			if err != nil && !errors.Is(err, neterrors.ErrInvalidArgument) {
+				s.log.V(3).Printf("[ERROR] Failed to publish keyspace notification to %s: %v", n.topic, err)
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
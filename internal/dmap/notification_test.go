@@ -0,0 +1,173 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// testPublisher records the published messages and delivers them to the
+// subscribers. It can be shared by the members of a test cluster.
+type testPublisher struct {
+	mtx         sync.Mutex
+	events      map[string][]map[string]interface{}
+	subscribers map[string][]func(msg interface{})
+}
+
+func newTestPublisher() *testPublisher {
+	return &testPublisher{
+		events:      make(map[string][]map[string]interface{}),
+		subscribers: make(map[string][]func(msg interface{})),
+	}
+}
+
+func (p *testPublisher) Publish(_ context.Context, topic string, msg interface{}) error {
+	p.mtx.Lock()
+	p.events[topic] = append(p.events[topic], msg.(map[string]interface{}))
+	subscribers := p.subscribers[topic]
+	p.mtx.Unlock()
+
+	for _, f := range subscribers {
+		f(msg)
+	}
+	return nil
+}
+
+func (p *testPublisher) Subscribe(topic string, f func(msg interface{})) (uint64, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.subscribers[topic] = append(p.subscribers[topic], f)
+	return uint64(len(p.subscribers[topic])), nil
+}
+
+func (p *testPublisher) wait(t *testing.T, topic string, n int) []map[string]interface{} {
+	var events []map[string]interface{}
+	require.Eventually(t, func() bool {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+
+		events = p.events[topic]
+		return len(events) >= n
+	}, 5*time.Second, 10*time.Millisecond)
+	return events
+}
+
+func TestDMap_KeyspaceNotifications(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.KeyspaceNotifications = true
+	e := testcluster.NewEnvironment(c)
+	p := newTestPublisher()
+	e.Set("dtopic", p)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.Put("mykey", "myvalue"))
+	require.NoError(t, dm.Delete("mykey"))
+
+	events := p.wait(t, KeyspaceTopic("mymap"), 2)
+	require.Equal(t, "mykey", events[0]["key"])
+	require.Equal(t, KeyspacePut, events[0]["op"])
+	require.Equal(t, "myvalue", events[0]["value"])
+	require.NotZero(t, events[0]["timestamp"])
+
+	require.Equal(t, "mykey", events[1]["key"])
+	require.Equal(t, KeyspaceDelete, events[1]["op"])
+	require.NotContains(t, events[1], "value")
+}
+
+func TestDMap_KeyspaceNotifications_Expire(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.KeyspaceNotifications = true
+	e := testcluster.NewEnvironment(c)
+	p := newTestPublisher()
+	e.Set("dtopic", p)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.PutEx("mykey", "myvalue", time.Millisecond))
+	<-time.After(5 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.evictKeys()
+	}
+
+	events := p.wait(t, KeyspaceTopic("mymap"), 2)
+	require.Equal(t, KeyspacePut, events[0]["op"])
+	require.Equal(t, "mykey", events[1]["key"])
+	require.Equal(t, KeyspaceExpire, events[1]["op"])
+}
+
+func TestDMap_KeyspaceNotifications_Evict(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxKeys = 10
+	c.DMaps.EvictionPolicy = config.LRUEviction
+	c.DMaps.KeyspaceNotifications = true
+	e := testcluster.NewEnvironment(c)
+	p := newTestPublisher()
+	e.Set("dtopic", p)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.Put(testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	events := p.wait(t, KeyspaceTopic("mymap"), 100)
+	var evicted int
+	for _, event := range events {
+		if event["op"] == KeyspaceEvict {
+			evicted++
+		}
+	}
+	require.NotZero(t, evicted)
+}
+
+func TestDMap_KeyspaceNotifications_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	e := testcluster.NewEnvironment(nil)
+	p := newTestPublisher()
+	e.Set("dtopic", p)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	require.NoError(t, dm.Put("mykey", "myvalue"))
+
+	<-time.After(100 * time.Millisecond)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	require.Empty(t, p.events)
+}
@@ -29,6 +29,18 @@ func (s *Service) RegisterOperations(operations map[protocol.OpCode]func(w, r pr
 	s.operations[protocol.OpPutIfReplica] = s.putReplicaOperation
 	s.operations[protocol.OpPutIfExReplica] = s.putReplicaOperation
 
+	// DMap.CompareAndSwap
+	s.operations[protocol.OpCompareAndSwap] = s.putOperation
+
+	// DMap.MGet, DMap.MPut, DMap.MDelete
+	s.operations[protocol.OpBatch] = s.batchOperation
+
+	// DMap.Exec
+	s.operations[protocol.OpExec] = s.execOperation
+
+	// DMap.Execute
+	s.operations[protocol.OpExecuteOnKey] = s.executeOnKeyOperation
+
 	// DMap.Get
 	s.operations[protocol.OpGet] = s.getOperation
 	s.operations[protocol.OpGetPrev] = s.getPrevOperation
@@ -70,9 +82,30 @@ func (s *Service) RegisterOperations(operations map[protocol.OpCode]func(w, r pr
 	s.operations[protocol.OpExpire] = s.expireOperation
 	s.operations[protocol.OpExpireReplica] = s.expireReplicaOperation
 
+	// DMap.TTL
+	s.operations[protocol.OpTTL] = s.ttlOperation
+
+	// DMap.GetEx
+	s.operations[protocol.OpGetEx] = s.getExOperation
+
 	// DMap.Query (distributed query)
 	s.operations[protocol.OpLocalQuery] = s.localQueryOperation
 	s.operations[protocol.OpQuery] = s.queryOperation
+	s.operations[protocol.OpQueryEntries] = s.queryEntriesOperation
+	s.operations[protocol.OpLocalQueryPage] = s.localQueryPageOperation
+	s.operations[protocol.OpQueryPage] = s.queryPageOperation
+
+	// DMap.DeleteByQuery
+	s.operations[protocol.OpDeleteByQuery] = s.deleteByQueryOperation
+	s.operations[protocol.OpLocalDeleteByQuery] = s.localDeleteByQueryOperation
+
+	// DMap.ExpireByQuery
+	s.operations[protocol.OpExpireByQuery] = s.expireByQueryOperation
+	s.operations[protocol.OpLocalExpireByQuery] = s.localExpireByQueryOperation
+
+	// DMap.Len
+	s.operations[protocol.OpLen] = s.lenOperation
+	s.operations[protocol.OpLocalLen] = s.localLenOperation
 
 	// Internals
 	s.operations[protocol.OpMoveFragment] = s.moveFragmentOperation
@@ -0,0 +1,230 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack"
+)
+
+// EntryProcessor modifies an entry atomically on the partition owner. entry is
+// nil if the key doesn't exist. The returned value is stored as the new value
+// of the key, returning nil deletes the key. An error or a panic aborts the
+// operation without modifying the key.
+type EntryProcessor func(entry *Entry, args interface{}) (interface{}, error)
+
+// RegisterEntryProcessor registers an entry processor with the given name. The
+// same entry processors have to be registered on every member of the cluster.
+func (s *Service) RegisterEntryProcessor(name string, fn EntryProcessor) error {
+	if name == "" {
+		return neterrors.Wrap(neterrors.ErrInvalidArgument, "entry processor name cannot be empty")
+	}
+	if fn == nil {
+		return neterrors.Wrap(neterrors.ErrInvalidArgument, "entry processor cannot be nil")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.processors[name]; ok {
+		return neterrors.Wrap(neterrors.ErrInvalidArgument, fmt.Sprintf("entry processor already registered: %s", name))
+	}
+	s.processors[name] = fn
+	return nil
+}
+
+func (s *Service) entryProcessor(name string) (EntryProcessor, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	fn, ok := s.processors[name]
+	if !ok {
+		return nil, neterrors.Wrap(neterrors.ErrInvalidArgument, fmt.Sprintf("entry processor not found: %s", name))
+	}
+	return fn, nil
+}
+
+// currentEntryOnFragment returns the entry of the key on the fragment. It returns
+// nil if the key doesn't exist. It's not thread-safe.
+func (dm *DMap) currentEntryOnFragment(f *fragment, hkey uint64) (*Entry, storage.Entry, error) {
+	raw, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if isTombstone(raw) || isKeyExpired(raw.TTL()) {
+		return nil, nil, nil
+	}
+
+	value, err := dm.unmarshalValue(raw.Value())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Entry{
+		Key:       raw.Key(),
+		Value:     value,
+		TTL:       raw.TTL(),
+		Timestamp: raw.Timestamp(),
+		Version:   raw.Timestamp(),
+	}, raw, nil
+}
+
+// runEntryProcessor calls the entry processor. A panicking entry processor is
+// turned into an error, it must not crash the partition owner.
+func (dm *DMap) runEntryProcessor(name string, fn EntryProcessor, entry *Entry, args interface{}) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			dm.s.log.V(3).Printf("[ERROR] Entry processor: %s panicked on DMap: %s: %v\n%s", name, dm.name, r, debug.Stack())
+			err = neterrors.Wrap(neterrors.ErrInternalFailure, fmt.Sprintf("entry processor panicked: %s: %v", name, r))
+		}
+	}()
+	return fn(entry, args)
+}
+
+func (dm *DMap) executeOnCluster(ctx context.Context, hkey uint64, key string, call *protocol.EntryProcessorCall) ([]byte, error) {
+	fn, err := dm.s.entryProcessor(call.Name)
+	if err != nil {
+		return nil, err
+	}
+	args, err := dm.unmarshalValue(call.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Incr and GetPut use the same fine grained lock.
+	lkey := dm.name + key
+	dm.s.locker.Lock(lkey)
+	defer func() {
+		err := dm.s.locker.Unlock(lkey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dm.name, err)
+		}
+	}()
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	entry, raw, err := dm.currentEntryOnFragment(f, hkey)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := dm.runEntryProcessor(call.Name, fn, entry, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if newValue == nil {
+		if raw == nil {
+			return nil, nil
+		}
+		if err = dm.deleteOnMapStore(ctx, f, key); err != nil {
+			return nil, err
+		}
+		timestamp := dm.s.clock.Now()
+		if err = dm.deleteOnCluster(ctx, hkey, key, f, timestamp); err != nil {
+			return nil, err
+		}
+		dm.notifyKeyspace(KeyspaceDelete, key, timestamp, nil)
+		return nil, nil
+	}
+
+	value, err := dm.s.serializer.Marshal(newValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the remaining TTL of the key.
+	opcode, timeout := protocol.OpPut, nilTimeout
+	if raw != nil && raw.TTL() != 0 {
+		opcode = protocol.OpPutEx
+		timeout = time.Duration(raw.TTL()-time.Now().UnixNano()/1000000) * time.Millisecond
+	}
+	e := newEnv(ctx, opcode, dm.name, key, value, timeout, 0, partitions.PRIMARY)
+	e.hkey = hkey
+	e.fragment = f
+	if err = dm.putOnLockedFragment(e); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (dm *DMap) execute(ctx context.Context, key string, call *protocol.EntryProcessorCall) ([]byte, error) {
+	hkey := dm.hkey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		// We are on the partition owner.
+		return dm.executeOnCluster(ctx, hkey, key, call)
+	}
+
+	// Redirect to the partition owner.
+	defer dm.invalidateNearCache(key)
+	value, err := msgpack.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+	req := protocol.NewDMapMessage(protocol.OpExecuteOnKey)
+	req.SetDMap(dm.name)
+	req.SetKey(key)
+	req.SetValue(value)
+	resp, err := dm.s.requestTo(ctx, member.String(), req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Value(), nil
+}
+
+// Execute runs the entry processor that is registered with the given name on
+// the key, under the lock of the key on the partition owner. The new value is
+// replicated like Put. It returns the new value of the key, nil if the entry
+// processor has deleted the key. It's thread-safe.
+func (dm *DMap) Execute(key, name string, args interface{}) (interface{}, error) {
+	return dm.ExecuteContext(context.Background(), key, name, args)
+}
+
+// ExecuteContext is like Execute but the given context cancels the network operations.
+func (dm *DMap) ExecuteContext(ctx context.Context, key, name string, args interface{}) (interface{}, error) {
+	if args == nil {
+		args = struct{}{}
+	}
+	raw, err := dm.s.serializer.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	value, err := dm.execute(ctx, key, &protocol.EntryProcessorCall{Name: name, Args: raw})
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, nil
+	}
+	return dm.unmarshalValue(value)
+}
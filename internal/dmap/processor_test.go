@@ -0,0 +1,214 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/stretchr/testify/require"
+)
+
+func appendItem(entry *Entry, args interface{}) (interface{}, error) {
+	if entry == nil {
+		return args, nil
+	}
+	return entry.Value.(string) + "," + args.(string), nil
+}
+
+func deleteIfEmpty(entry *Entry, _ interface{}) (interface{}, error) {
+	if entry == nil || entry.Value == "" {
+		return nil, nil
+	}
+	return entry.Value, nil
+}
+
+func registerTestEntryProcessors(t *testing.T, services ...*Service) {
+	for _, s := range services {
+		require.NoError(t, s.RegisterEntryProcessor("appendItem", appendItem))
+		require.NoError(t, s.RegisterEntryProcessor("deleteIfEmpty", deleteIfEmpty))
+		require.NoError(t, s.RegisterEntryProcessor("fail", func(_ *Entry, _ interface{}) (interface{}, error) {
+			return nil, errors.New("failed")
+		}))
+	}
+}
+
+func TestDMap_Execute(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	registerTestEntryProcessors(t, s1, s2)
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		value, err := dm1.Execute(key, "appendItem", "a")
+		require.NoError(t, err)
+		require.Equal(t, "a", value)
+
+		value, err = dm2.Execute(key, "appendItem", "b")
+		require.NoError(t, err)
+		require.Equal(t, "a,b", value)
+
+		value, err = dm1.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, "a,b", value)
+	}
+
+	err = dm1.Put("empty", "")
+	require.NoError(t, err)
+	value, err := dm2.Execute("empty", "deleteIfEmpty", nil)
+	require.NoError(t, err)
+	require.Nil(t, value)
+	_, err = dm1.Get("empty")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Execute_KeepTTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	registerTestEntryProcessors(t, s)
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.PutEx("mykey", "a", time.Hour)
+	require.NoError(t, err)
+	before, err := dm.GetEntry("mykey")
+	require.NoError(t, err)
+
+	_, err = dm.Execute("mykey", "appendItem", "b")
+	require.NoError(t, err)
+
+	after, err := dm.GetEntry("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "a,b", after.Value)
+	require.InDelta(t, before.TTL, after.TTL, float64(time.Second/time.Millisecond))
+}
+
+func TestDMap_Execute_Errors(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	registerTestEntryProcessors(t, s)
+
+	err := s.RegisterEntryProcessor("appendItem", appendItem)
+	require.ErrorIs(t, err, neterrors.ErrInvalidArgument)
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	_, err = dm.Execute("mykey", "unknown", nil)
+	require.ErrorIs(t, err, neterrors.ErrInvalidArgument)
+
+	err = dm.Put("mykey", "value")
+	require.NoError(t, err)
+	_, err = dm.Execute("mykey", "fail", nil)
+	require.Error(t, err)
+
+	value, err := dm.Get("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+}
+
+func TestDMap_Execute_Panic(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	for _, s := range []*Service{s1, s2} {
+		registerTestEntryProcessors(t, s)
+		require.NoError(t, s.RegisterEntryProcessor("panic", func(_ *Entry, _ interface{}) (interface{}, error) {
+			panic("boom")
+		}))
+	}
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		require.NoError(t, dm1.Put(key, "value"))
+
+		// One of them runs on the partition owner, the other one is redirected.
+		for _, dm := range []*DMap{dm1, dm2} {
+			_, err = dm.Execute(key, "panic", nil)
+			require.ErrorIs(t, err, neterrors.ErrInternalFailure)
+		}
+
+		// The locks have been released.
+		value, err := dm2.Execute(key, "appendItem", "a")
+		require.NoError(t, err)
+		require.Equal(t, "value,a", value)
+	}
+}
+
+func TestDMap_Execute_Concurrent(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	counter := func(entry *Entry, _ interface{}) (interface{}, error) {
+		if entry == nil {
+			return 1, nil
+		}
+		return entry.Value.(int) + 1, nil
+	}
+	require.NoError(t, s1.RegisterEntryProcessor("counter", counter))
+	require.NoError(t, s2.RegisterEntryProcessor("counter", counter))
+
+	var wg sync.WaitGroup
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("mymap")
+		require.NoError(t, err)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(dm *DMap) {
+				defer wg.Done()
+				_, err := dm.Execute("mykey", "counter", nil)
+				require.NoError(t, err)
+			}(dm)
+		}
+	}
+	wg.Wait()
+
+	dm, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	value, err := dm.Get("mykey")
+	require.NoError(t, err)
+	require.Equal(t, 100, value)
+}
@@ -15,6 +15,7 @@
 package dmap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -50,8 +51,11 @@ func (dm *DMap) putOnFragment(e *env) error {
 	entry.SetValue(e.value)
 	entry.SetTTL(timeoutToTTL(e.timeout))
 	entry.SetTimestamp(e.timestamp)
+	if dm.isLFUEnabled() {
+		entry.SetLastAccess(dm.newAccessCounter(e.fragment, e.hkey))
+	}
 
-	err := e.fragment.storage.Put(e.hkey, entry)
+	err := e.fragment.put(e.hkey, entry)
 	if errors.Is(err, storage.ErrKeyTooLarge) {
 		err = ErrKeyTooLarge
 	}
@@ -76,6 +80,7 @@ func (dm *DMap) putOnReplicaFragment(e *env) error {
 	f.Lock()
 	defer f.Unlock()
 
+	dm.s.clock.Update(e.timestamp)
 	return dm.putOnFragment(e)
 }
 
@@ -83,7 +88,9 @@ func (dm *DMap) asyncPutOnBackup(e *env, owner discovery.Member) {
 	defer dm.s.wg.Done()
 
 	req := e.toReq(e.replicaOpcode)
-	_, err := dm.s.requestTo(owner.String(), req)
+	// The caller doesn't wait for the replicas, its context may be canceled before
+	// the request is done.
+	_, err := dm.s.requestTo(context.Background(), owner.String(), req)
 	if err != nil {
 		if dm.s.log.V(3).Ok() {
 			dm.s.log.V(3).Printf("[ERROR] Failed to create replica in async mode: %v", err)
@@ -117,7 +124,7 @@ func (dm *DMap) syncPutOnCluster(e *env) error {
 	owners := dm.s.backup.PartitionOwnersByHKey(e.hkey)
 	for _, owner := range owners {
 		req := e.toReq(e.replicaOpcode)
-		_, err := dm.s.requestTo(owner.String(), req)
+		_, err := dm.s.requestTo(e.ctx, owner.String(), req)
 		if err != nil {
 			if dm.s.log.V(3).Ok() {
 				dm.s.log.V(3).Printf("[ERROR] Failed to call put command on %s for DMap: %s: %v", owner, e.dmap, err)
@@ -140,13 +147,22 @@ func (dm *DMap) syncPutOnCluster(e *env) error {
 	return ErrWriteQuorum
 }
 
-func (dm *DMap) setLRUEvictionStats(e *env) error {
+func (dm *DMap) evictKeyWithPolicy(e *env) error {
+	if dm.config.evictionPolicy == config.LFUEviction {
+		return dm.evictKeyWithLFU(e)
+	}
+	return dm.evictKeyWithLRU(e)
+}
+
+func (dm *DMap) setEvictionStats(e *env) error {
 	// Try to make room for the new item, if it's required.
-	// MaxKeys and MaxInuse properties of LRU can be used in the same time.
+	// MaxKeys and MaxInuse properties of LRU and LFU can be used in the same time.
 	// But I think that it's good to use only one of time in a production system.
 	// Because it should be easy to understand and debug.
 	st := e.fragment.storage.Stats()
-	// This works for every request if you enabled LRU.
+	// The tombstones cannot be evicted, they are removed after the grace period.
+	st.Length = e.fragment.length()
+	// This works for every request if you enabled LRU or LFU.
 	// But loading a number from memory should be very cheap.
 	// ownedPartitionCount changes in the case of node join or leave.
 	ownedPartitionCount := dm.s.rt.OwnedPartitionCount()
@@ -156,7 +172,7 @@ func (dm *DMap) setLRUEvictionStats(e *env) error {
 		// manages itself independently. So if you set MaxKeys=70 and
 		// your partition count is 7, every partition 10 keys at maximum.
 		if st.Length >= dm.config.maxKeys/int(ownedPartitionCount) {
-			err := dm.evictKeyWithLRU(e)
+			err := dm.evictKeyWithPolicy(e)
 			if err != nil {
 				return err
 			}
@@ -170,7 +186,7 @@ func (dm *DMap) setLRUEvictionStats(e *env) error {
 		// your partition count is 7, every partition consumes 10M in-use space at maximum.
 		// WARNING: Actual allocated memory can be different.
 		if st.Inuse >= dm.config.maxInuse/int(ownedPartitionCount) {
-			err := dm.evictKeyWithLRU(e)
+			err := dm.evictKeyWithPolicy(e)
 			if err != nil {
 				return err
 			}
@@ -180,36 +196,28 @@ func (dm *DMap) setLRUEvictionStats(e *env) error {
 }
 
 func (dm *DMap) checkPutConditions(e *env) error {
+	if e.flags&(IfNotFound|IfFound) == 0 {
+		return nil
+	}
+
+	var found bool
+	entry, err := e.fragment.storage.Get(e.hkey)
+	if err == nil {
+		// Tombstones and expired keys don't exist for the caller.
+		found = !isTombstone(entry) && !isKeyExpired(entry.TTL())
+	}
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+
 	// Only set the key if it does not already exist.
-	if e.flags&IfNotFound != 0 {
-		ttl, err := e.fragment.storage.GetTTL(e.hkey)
-		if err == nil {
-			if !isKeyExpired(ttl) {
-				return ErrKeyFound
-			}
-		}
-		if errors.Is(err, storage.ErrKeyNotFound) {
-			err = nil
-		}
-		if err != nil {
-			return err
-		}
+	if e.flags&IfNotFound != 0 && found {
+		return ErrKeyFound
 	}
 
 	// Only set the key if it already exists.
-	if e.flags&IfFound != 0 && !e.fragment.storage.Check(e.hkey) {
-		ttl, err := e.fragment.storage.GetTTL(e.hkey)
-		if err == nil {
-			if isKeyExpired(ttl) {
-				return ErrKeyNotFound
-			}
-		}
-		if errors.Is(err, storage.ErrKeyNotFound) {
-			err = ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
+	if e.flags&IfFound != 0 && !found {
+		return ErrKeyNotFound
 	}
 	return nil
 }
@@ -225,7 +233,28 @@ func (dm *DMap) putOnCluster(e *env) error {
 	f.Lock()
 	defer f.Unlock()
 
-	if err = dm.checkPutConditions(e); err != nil {
+	return dm.putOnLockedFragment(e)
+}
+
+// putOnLockedFragment stores the key/value pair on the partition owner and its
+// replicas. It's not thread-safe, the caller has to acquire the fragment's lock.
+func (dm *DMap) putOnLockedFragment(e *env) error {
+	// The partition owner stamps the new version. The timestamp that is sent by
+	// the client or the coordinator is ignored, their clocks may be skewed.
+	e.timestamp = dm.s.clock.Now()
+
+	err := dm.checkPutConditions(e)
+	if err != nil {
+		return err
+	}
+
+	if e.opcode == protocol.OpCompareAndSwap {
+		if err = dm.checkVersion(e); err != nil {
+			return err
+		}
+	}
+
+	if err = dm.checkMaxMemory(e); err != nil {
 		return err
 	}
 
@@ -233,35 +262,46 @@ func (dm *DMap) putOnCluster(e *env) error {
 		if dm.config.ttlDuration.Seconds() != 0 && e.timeout.Seconds() == 0 {
 			e.timeout = dm.config.ttlDuration
 		}
-		if dm.config.evictionPolicy == config.LRUEviction {
-			if err = dm.setLRUEvictionStats(e); err != nil {
+		if err = dm.storeOnMapStore(e); err != nil {
+			return err
+		}
+		if dm.config.evictionPolicy == config.LRUEviction || dm.config.evictionPolicy == config.LFUEviction {
+			if err = dm.setEvictionStats(e); err != nil {
 				return err
 			}
 		}
 	}
 
+	trackMemoryUsage := dm.trackMemoryUsage(e)
 	if dm.s.config.ReplicaCount > config.MinimumReplicaCount {
 		switch dm.s.config.ReplicationMode {
 		case config.AsyncReplicationMode:
 			// Fire and forget mode. Calls PutBackup command in different goroutines
 			// and stores the key/value pair on local storage instance.
-			return dm.asyncPutOnCluster(e)
+			err = dm.asyncPutOnCluster(e)
 		case config.SyncReplicationMode:
 			// Quorum based replication.
-			return dm.syncPutOnCluster(e)
+			err = dm.syncPutOnCluster(e)
 		default:
-			return fmt.Errorf("invalid replication mode: %v", dm.s.config.ReplicationMode)
+			err = fmt.Errorf("invalid replication mode: %v", dm.s.config.ReplicationMode)
 		}
+	} else {
+		// single replica
+		err = dm.putOnFragment(e)
 	}
+	if err != nil {
+		return err
+	}
+	trackMemoryUsage()
 
-	// single replica
-	return dm.putOnFragment(e)
+	dm.notifyKeyspace(KeyspacePut, e.key, e.timestamp, e.value)
+	return nil
 }
 
 // put controls every write operation in Olric. It redirects the requests to its owner,
 // if the key belongs to another host.
 func (dm *DMap) put(e *env) error {
-	e.hkey = partitions.HKey(e.dmap, e.key)
+	e.hkey = dm.hkey(e.key)
 	member := dm.s.primary.PartitionByHKey(e.hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
 		// We are on the partition owner.
@@ -269,18 +309,19 @@ func (dm *DMap) put(e *env) error {
 	}
 
 	// Redirect to the partition owner.
+	defer dm.invalidateNearCache(e.key)
 	req := e.toReq(e.opcode)
-	_, err := dm.s.requestTo(member.String(), req)
+	_, err := dm.s.requestTo(e.ctx, member.String(), req)
 	return err
 }
 
-func (dm *DMap) prepareAndSerialize(opcode protocol.OpCode, key string, value interface{},
+func (dm *DMap) prepareAndSerialize(ctx context.Context, opcode protocol.OpCode, key string, value interface{},
 	timeout time.Duration, flags int16) (*env, error) {
 	val, err := dm.s.serializer.Marshal(value)
 	if err != nil {
 		return nil, err
 	}
-	return newEnv(opcode, dm.name, key, val, timeout, flags, partitions.PRIMARY), nil
+	return newEnv(ctx, opcode, dm.name, key, val, timeout, flags, partitions.PRIMARY), nil
 }
 
 // PutEx sets the value for the given key with TTL. It overwrites any previous
@@ -288,7 +329,12 @@ func (dm *DMap) prepareAndSerialize(opcode protocol.OpCode, key string, value in
 // is arbitrary. It is safe to modify the contents of the arguments after
 // Put returns but not before.
 func (dm *DMap) PutEx(key string, value interface{}, timeout time.Duration) error {
-	e, err := dm.prepareAndSerialize(protocol.OpPutEx, key, value, timeout, 0)
+	return dm.PutExContext(context.Background(), key, value, timeout)
+}
+
+// PutExContext is like PutEx but the given context cancels the network operations.
+func (dm *DMap) PutExContext(ctx context.Context, key string, value interface{}, timeout time.Duration) error {
+	e, err := dm.prepareAndSerialize(ctx, protocol.OpPutEx, key, value, timeout, 0)
 	if err != nil {
 		return err
 	}
@@ -300,7 +346,12 @@ func (dm *DMap) PutEx(key string, value interface{}, timeout time.Duration) erro
 // is arbitrary. It is safe to modify the contents of the arguments after
 // Put returns but not before.
 func (dm *DMap) Put(key string, value interface{}) error {
-	e, err := dm.prepareAndSerialize(protocol.OpPut, key, value, nilTimeout, 0)
+	return dm.PutContext(context.Background(), key, value)
+}
+
+// PutContext is like Put but the given context cancels the network operations.
+func (dm *DMap) PutContext(ctx context.Context, key string, value interface{}) error {
+	e, err := dm.prepareAndSerialize(ctx, protocol.OpPut, key, value, nilTimeout, 0)
 	if err != nil {
 		return err
 	}
@@ -319,7 +370,12 @@ func (dm *DMap) Put(key string, value interface{}) error {
 // IfFound: Only set the key if it already exist.
 // It returns ErrKeyNotFound if the key does not exist.
 func (dm *DMap) PutIf(key string, value interface{}, flags int16) error {
-	e, err := dm.prepareAndSerialize(protocol.OpPutIf, key, value, nilTimeout, flags)
+	return dm.PutIfContext(context.Background(), key, value, flags)
+}
+
+// PutIfContext is like PutIf but the given context cancels the network operations.
+func (dm *DMap) PutIfContext(ctx context.Context, key string, value interface{}, flags int16) error {
+	e, err := dm.prepareAndSerialize(ctx, protocol.OpPutIf, key, value, nilTimeout, flags)
 	if err != nil {
 		return err
 	}
@@ -338,7 +394,12 @@ func (dm *DMap) PutIf(key string, value interface{}, flags int16) error {
 // IfFound: Only set the key if it already exist.
 // It returns ErrKeyNotFound if the key does not exist.
 func (dm *DMap) PutIfEx(key string, value interface{}, timeout time.Duration, flags int16) error {
-	e, err := dm.prepareAndSerialize(protocol.OpPutIfEx, key, value, timeout, flags)
+	return dm.PutIfExContext(context.Background(), key, value, timeout, flags)
+}
+
+// PutIfExContext is like PutIfEx but the given context cancels the network operations.
+func (dm *DMap) PutIfExContext(ctx context.Context, key string, value interface{}, timeout time.Duration, flags int16) error {
+	e, err := dm.prepareAndSerialize(ctx, protocol.OpPutIfEx, key, value, timeout, flags)
 	if err != nil {
 		return err
 	}
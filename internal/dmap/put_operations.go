@@ -38,14 +38,14 @@ func (s *Service) putOperationCommon(w, r protocol.EncodeDecoder, f func(dm *DMa
 
 func (s *Service) putOperation(w, r protocol.EncodeDecoder) {
 	s.putOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
-		e := newEnvFromReq(r, partitions.PRIMARY)
+		e := dm.newEnvFromReq(r, partitions.PRIMARY)
 		return dm.put(e)
 	})
 }
 
 func (s *Service) putReplicaOperation(w, r protocol.EncodeDecoder) {
 	s.putOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) error {
-		e := newEnvFromReq(r, partitions.BACKUP)
+		e := dm.newEnvFromReq(r, partitions.BACKUP)
 		return dm.putOnReplicaFragment(e)
 	})
 }
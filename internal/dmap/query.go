@@ -16,8 +16,11 @@ package dmap
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"sync"
 
 	"github.com/buraksezer/olric/internal/protocol"
@@ -44,15 +47,19 @@ type queryResponse map[uint64][]byte
 
 // Cursor implements distributed query on DMaps.
 type Cursor struct {
-	dm     *DMap
-	query  query.M
-	ctx    context.Context
-	cancel context.CancelFunc
+	dm    *DMap
+	query query.M
+	// aggregates is the aggregation operators of the query. They're removed
+	// from query, so Range iterates over the matching entries.
+	aggregates query.M
+	parent     context.Context
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
 // Query runs a distributed query on a dmap instance.
-// Olric supports a very simple query DSL and now, it only scans keys. The query DSL has very
-// few keywords:
+// Olric supports a very simple query DSL to filter the entries by their keys and values.
+// The query DSL has very few keywords:
 //
 // $onKey: Runs the given query on keys or manages options on keys for a given query.
 //
@@ -94,27 +101,150 @@ type Cursor struct {
 // 	  },
 //   }
 //
-// Query function returns a cursor which has Range and Close methods. Please take look at the Range
-// function for further info.
+// Keywords for $onValue:
+//
+// $eq, $gt, $lt: Compares the value with the given one. Numbers are compared by value and
+// strings lexicographically.
+//
+// $in: Checks that the value is equal to one of the given values.
+//
+// $exists: Checks that the value or the field exists.
+//
+// The other keys in $onValue are field paths into the decoded maps, the elements are
+// separated by dots. The values are decoded by the configured serializer on the partition
+// owners:
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^user:",
+// 	  },
+// 	  "$onValue": query.M{
+// 		  "address.city": query.M{"$eq": "Istanbul"},
+// 		  "age":          query.M{"$gt": 30},
+// 	  },
+//   }
+//
+// $onKey can be omitted to run the predicates on all the keys.
+//
+// $aggregate: Computes the given aggregation operators over the matching entries. Call
+// Cursor.Aggregate to run it. The operators are computed on the partition owners, so the
+// values are not sent to the caller.
+//
+// Keywords for $aggregate:
+//
+// $count: Counts the matching entries.
+//
+// $sum, $min, $max, $avg: Computes the sum, the minimum, the maximum and the average of
+// the numeric values. The other values are ignored.
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^even:",
+// 	  },
+// 	  "$aggregate": query.M{
+// 		  "$count": true,
+// 		  "$sum":   true,
+// 	  },
+//   }
+//
+// $limit: Stops the query after the given number of entries. The partition owners stop
+// scanning after $limit matching entries. It can be used with the $count operator to count
+// up to a limit, the other aggregation operators don't support it.
+//
+//   query.M{
+// 	  "$onKey": query.M{
+// 		  "$regexMatch": "^even:",
+// 	  },
+// 	  "$limit": 10,
+//   }
+//
+// Query function returns a cursor which has Range, RangeEntries, Page, Aggregate and Close
+// methods. Please take look at the Range function for further info.
 func (dm *DMap) Query(q query.M) (*Cursor, error) {
+	return dm.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query but the given context cancels the network operations
+// of the cursor. Range returns the error of the context, if it's done.
+func (dm *DMap) QueryContext(parent context.Context, q query.M) (*Cursor, error) {
 	err := query.Validate(q)
 	if err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithCancel(context.Background())
+	// The workers encode the query in the background, the caller may modify
+	// it after this call.
+	q = copyQuery(q)
+	aggregates := query.Aggregates(q)
+	if aggregates != nil {
+		if query.Limit(q) > 0 && query.NeedsValues(aggregates) {
+			return nil, fmt.Errorf("%w: $limit can only be used with $count", query.ErrInvalidQuery)
+		}
+		q = withoutKeyword(q, "$aggregate")
+	}
+
+	ctx, cancel := context.WithCancel(parent)
 	return &Cursor{
-		dm:     dm,
-		query:  q,
-		ctx:    ctx,
-		cancel: cancel,
+		dm:         dm,
+		query:      q,
+		aggregates: aggregates,
+		parent:     parent,
+		ctx:        ctx,
+		cancel:     cancel,
 	}, nil
 }
 
+// withoutKeyword returns a copy of the query without the given keyword.
+func withoutKeyword(q query.M, keyword string) query.M {
+	tmp := make(query.M)
+	for k, v := range q {
+		if k != keyword {
+			tmp[k] = v
+		}
+	}
+	return tmp
+}
+
+// copyQuery returns a deep copy of the given query.
+func copyQuery(q query.M) query.M {
+	tmp := make(query.M, len(q))
+	for k, v := range q {
+		tmp[k] = copyQueryValue(v)
+	}
+	return tmp
+}
+
+func copyQueryValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case query.M:
+		return copyQuery(v)
+	case map[string]interface{}:
+		return map[string]interface{}(copyQuery(v))
+	case []interface{}:
+		tmp := make([]interface{}, len(v))
+		for i, item := range v {
+			tmp[i] = copyQueryValue(item)
+		}
+		return tmp
+	default:
+		return v
+	}
+}
+
 func (dm *DMap) runLocalQuery(partID uint64, q query.M) (queryResponse, error) {
 	p := newQueryPipeline(dm, partID)
 	return p.execute(q)
 }
 
+func (dm *DMap) runLocalAggregation(partID uint64, q query.M) (*query.Aggregation, error) {
+	p := newQueryPipeline(dm, partID)
+	p.aggregation = &query.Aggregation{}
+	p.aggregates = query.Aggregates(q)
+	if _, err := p.execute(q); err != nil {
+		return nil, err
+	}
+	return p.aggregation, nil
+}
+
 func (c *Cursor) reconcileResponses(responses []queryResponse) map[uint64]storage.Entry {
 	result := make(map[uint64]storage.Entry)
 	for _, response := range responses {
@@ -135,16 +265,22 @@ func (c *Cursor) reconcileResponses(responses []queryResponse) map[uint64]storag
 }
 
 func (c *Cursor) runQueryOnOwners(partID uint64) ([]storage.Entry, error) {
-	value, err := msgpack.Marshal(c.query)
+	q := c.query
+	owners := c.dm.s.primary.PartitionOwnersByID(partID)
+	if len(owners) > 1 {
+		// The first matches of an owner may be stale or deleted on the other
+		// owners. Don't let them stop the scan early.
+		q = withoutKeyword(q, "$limit")
+	}
+	value, err := msgpack.Marshal(q)
 	if err != nil {
 		return nil, err
 	}
 
-	owners := c.dm.s.primary.PartitionOwnersByID(partID)
 	var responses []queryResponse
 	for _, owner := range owners {
 		if owner.CompareByID(c.dm.s.rt.This()) {
-			response, err := c.dm.runLocalQuery(partID, c.query)
+			response, err := c.dm.runLocalQuery(partID, q)
 			if err != nil {
 				return nil, err
 			}
@@ -157,7 +293,7 @@ func (c *Cursor) runQueryOnOwners(partID uint64) ([]storage.Entry, error) {
 		req.SetExtra(protocol.LocalQueryExtra{
 			PartID: partID,
 		})
-		response, err := c.dm.s.requestTo(owner.String(), req)
+		response, err := c.dm.s.requestTo(c.parent, owner.String(), req)
 		if err != nil {
 			return nil, fmt.Errorf("query call is failed: %w", err)
 		}
@@ -172,6 +308,10 @@ func (c *Cursor) runQueryOnOwners(partID uint64) ([]storage.Entry, error) {
 
 	var result []storage.Entry
 	for _, entry := range c.reconcileResponses(responses) {
+		if isTombstone(entry) {
+			// The key has been deleted.
+			continue
+		}
 		result = append(result, entry)
 	}
 	return result, nil
@@ -220,8 +360,7 @@ func (c *Cursor) runQueryOnCluster(results chan []storage.Entry, errCh chan erro
 			case <-c.dm.s.ctx.Done():
 				// Server is gone.
 				return
-			default:
-				results <- responses
+			case results <- responses:
 			}
 		}(partID)
 	}
@@ -232,8 +371,36 @@ func (c *Cursor) runQueryOnCluster(results chan []storage.Entry, errCh chan erro
 // Range calls f sequentially for each key and value yielded from the cursor. If f returns false,
 // range stops the iteration.
 func (c *Cursor) Range(f func(key string, value interface{}) bool) error {
-	defer c.Close()
+	return c.rangeOnCluster(func(entry storage.Entry) (bool, error) {
+		value, err := c.dm.unmarshalValue(entry.Value())
+		if err != nil {
+			return false, err
+		}
+		return f(entry.Key(), value), nil
+	})
+}
+
+// RangeEntries is like Range but it yields the entries with their metadata.
+func (c *Cursor) RangeEntries(f func(e *Entry) bool) error {
+	return c.rangeOnCluster(func(entry storage.Entry) (bool, error) {
+		value, err := c.dm.unmarshalValue(entry.Value())
+		if err != nil {
+			return false, err
+		}
+		return f(&Entry{
+			Key:        entry.Key(),
+			Value:      value,
+			TTL:        entry.TTL(),
+			Timestamp:  entry.Timestamp(),
+			LastAccess: c.dm.lastAccessTime(entry.LastAccess()),
+			Version:    entry.Timestamp(),
+		}), nil
+	})
+}
 
+// rangeOnCluster calls f sequentially for each entry yielded from the cursor. It
+// stops the iteration after $limit entries.
+func (c *Cursor) rangeOnCluster(f func(entry storage.Entry) (bool, error)) error {
 	// Currently we have only 2 parallel query on the cluster. It's good enough for a smooth operation.
 	results := make(chan []storage.Entry, NumConcurrentWorkers)
 	errCh := make(chan error, 1)
@@ -241,22 +408,342 @@ func (c *Cursor) Range(f func(key string, value interface{}) bool) error {
 	c.dm.s.wg.Add(1)
 	go c.runQueryOnCluster(results, errCh)
 
+	defer func() {
+		// Stop the workers and wait for them, if the iteration stops early.
+		// runQueryOnCluster closes the results channel after they return.
+		c.Close()
+		for range results {
+		}
+	}()
+
+	limit := query.Limit(c.query)
+	var count int
 	for res := range results {
 		for _, entry := range res {
-			value, err := c.dm.unmarshalValue(entry.Value())
+			next, err := f(entry)
 			if err != nil {
 				return err
 			}
-			if !f(entry.Key(), value) {
+			if !next {
 				// User called "break" in this loop (Range)
 				return nil
 			}
+			count++
+			if limit > 0 && count >= limit {
+				return nil
+			}
 		}
 	}
-	return <-errCh
+	err := <-errCh
+	if c.parent.Err() != nil {
+		// The caller has given up, the results are incomplete.
+		return c.parent.Err()
+	}
+	return err
 }
 
 // Close cancels the underlying context and background goroutines stops running.
 func (c *Cursor) Close() {
 	c.cancel()
 }
+
+func (c *Cursor) aggregateOnOwners(partID uint64) (*query.Aggregation, error) {
+	owners := c.dm.s.primary.PartitionOwnersByID(partID)
+	if len(owners) != 1 {
+		// The previous owners may have stale versions of the entries. Fetch and
+		// reconcile them before aggregating. The owners are scanned in full, so
+		// $limit is applied after the reconciliation like a single owner does.
+		entries, err := c.runQueryOnOwners(partID)
+		if err != nil {
+			return nil, err
+		}
+		if limit := query.Limit(c.query); limit > 0 && len(entries) > limit {
+			entries = entries[:limit]
+		}
+		needsValues := query.NeedsValues(c.aggregates)
+		result := &query.Aggregation{}
+		for _, entry := range entries {
+			var value interface{}
+			if needsValues {
+				value, err = c.dm.unmarshalValue(entry.Value())
+				if err != nil {
+					return nil, err
+				}
+			}
+			result.Add(value)
+		}
+		return result, nil
+	}
+
+	q := query.M{"$aggregate": c.aggregates}
+	for keyword, value := range c.query {
+		q[keyword] = value
+	}
+	owner := owners[0]
+	if owner.CompareByID(c.dm.s.rt.This()) {
+		return c.dm.runLocalAggregation(partID, q)
+	}
+
+	value, err := msgpack.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	req := protocol.NewDMapMessage(protocol.OpLocalQuery)
+	req.SetDMap(c.dm.name)
+	req.SetValue(value)
+	req.SetExtra(protocol.LocalQueryExtra{
+		PartID: partID,
+	})
+	response, err := c.dm.s.requestTo(c.parent, owner.String(), req)
+	if err != nil {
+		return nil, fmt.Errorf("query call is failed: %w", err)
+	}
+	result := &query.Aggregation{}
+	err = msgpack.Unmarshal(response.Value(), result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Aggregate computes the aggregation operators of the query on the cluster. The
+// partial results of the partitions are merged on this node.
+func (c *Cursor) Aggregate() (*query.Aggregation, error) {
+	defer c.Close()
+
+	if c.aggregates == nil {
+		return nil, fmt.Errorf("%w: no aggregation operator", query.ErrInvalidQuery)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs error
+	result := &query.Aggregation{}
+
+	sem := semaphore.NewWeighted(NumConcurrentWorkers)
+	for partID := uint64(0); partID < c.dm.s.config.PartitionCount; partID++ {
+		if err := sem.Acquire(c.ctx, 1); err != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(id uint64) {
+			defer sem.Release(1)
+			defer wg.Done()
+
+			aggregation, err := c.aggregateOnOwners(id)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				c.Close() // Breaks the loop
+				return
+			}
+			result.Merge(aggregation)
+		}(partID)
+	}
+	wg.Wait()
+
+	if c.parent.Err() != nil {
+		// The caller has given up, the result is incomplete.
+		return nil, c.parent.Err()
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	// Every partition aggregates up to $limit entries, the merged count is
+	// clamped to the limit.
+	if limit := query.Limit(c.query); limit > 0 && result.Count > int64(limit) {
+		result.Count = int64(limit)
+	}
+	return result, nil
+}
+
+// localQueryPage is a page of a fragment, the entries have the smallest HKeys
+// after the given position.
+type localQueryPage struct {
+	Entries queryResponse
+	// Truncated is true, if there are more entries after the page.
+	Truncated bool
+}
+
+func (dm *DMap) runLocalQueryPage(partID, from uint64, limit int, q query.M) (*localQueryPage, error) {
+	p := newQueryPipeline(dm, partID)
+	p.page = &pageHeap{from: from, limit: limit}
+	if _, err := p.execute(q); err != nil {
+		return nil, err
+	}
+	page := &localQueryPage{
+		Entries:   make(queryResponse),
+		Truncated: p.page.truncated,
+	}
+	for _, item := range p.page.items {
+		page.Entries[item.hkey] = item.raw
+	}
+	return page, nil
+}
+
+// pageOnOwners returns at most limit entries with the smallest HKeys after from
+// in the given partition. It also returns the position of the next page. done
+// is true, if there is no entry left in the partition.
+func (c *Cursor) pageOnOwners(partID, from uint64, limit int) (result []storage.Entry, next uint64, done bool, err error) {
+	value, err := msgpack.Marshal(c.query)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	owners := c.dm.s.primary.PartitionOwnersByID(partID)
+	var pages []*localQueryPage
+	for _, owner := range owners {
+		if owner.CompareByID(c.dm.s.rt.This()) {
+			page, err := c.dm.runLocalQueryPage(partID, from, limit, c.query)
+			if err != nil {
+				return nil, 0, false, err
+			}
+			pages = append(pages, page)
+			continue
+		}
+		req := protocol.NewDMapMessage(protocol.OpLocalQueryPage)
+		req.SetDMap(c.dm.name)
+		req.SetValue(value)
+		req.SetExtra(protocol.LocalQueryPageExtra{
+			PartID: partID,
+			From:   from,
+			Limit:  uint64(limit),
+		})
+		response, err := c.dm.s.requestTo(c.parent, owner.String(), req)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("query call is failed: %w", err)
+		}
+		page := &localQueryPage{}
+		err = msgpack.Unmarshal(response.Value(), page)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		pages = append(pages, page)
+	}
+
+	// An owner returns the smallest HKeys of its fragment. The HKeys after the
+	// last one of a truncated page are not known, so they're left to the next page.
+	bound := uint64(math.MaxUint64)
+	done = true
+	for _, page := range pages {
+		if !page.Truncated {
+			continue
+		}
+		done = false
+		var last uint64
+		for hkey := range page.Entries {
+			if hkey > last {
+				last = hkey
+			}
+		}
+		if last < bound {
+			bound = last
+		}
+	}
+
+	var responses []queryResponse
+	for _, page := range pages {
+		response := make(queryResponse)
+		for hkey, raw := range page.Entries {
+			if hkey <= bound {
+				response[hkey] = raw
+			}
+		}
+		responses = append(responses, response)
+	}
+	for _, entry := range c.reconcileResponses(responses) {
+		if isTombstone(entry) {
+			// The key has been deleted.
+			continue
+		}
+		result = append(result, entry)
+	}
+	if bound == math.MaxUint64 {
+		// There is no HKey after the bound, the partition is exhausted.
+		return result, 0, true, nil
+	}
+	return result, bound + 1, done, nil
+}
+
+// page fills a page with at most limit entries, starting from the given
+// position. It returns the continuation token of the next page, the token is
+// empty if there is no entry left.
+func (c *Cursor) page(token string, limit int) ([]storage.Entry, string, error) {
+	if limit <= 0 {
+		return nil, "", neterrors.Wrap(neterrors.ErrInvalidArgument, "limit has to be positive")
+	}
+	partID, from, err := decodeQueryToken(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result []storage.Entry
+	for partID < c.dm.s.config.PartitionCount && len(result) < limit {
+		if err = c.parent.Err(); err != nil {
+			return nil, "", err
+		}
+		entries, next, done, err := c.pageOnOwners(partID, from, limit-len(result))
+		if err != nil {
+			return nil, "", err
+		}
+		result = append(result, entries...)
+		if done {
+			partID++
+			from = 0
+		} else {
+			from = next
+		}
+	}
+	if partID >= c.dm.s.config.PartitionCount {
+		return result, "", nil
+	}
+	return result, encodeQueryToken(partID, from), nil
+}
+
+// Page returns at most limit matching key/value pairs with a continuation token.
+// Pass the token to Page of a cursor with the same query to get the next page.
+// The first page is requested with an empty token, the returned token is empty
+// after the last page. The cursor keeps no state between the pages, so a page
+// can be requested by another cursor or another member.
+//
+// The entries are iterated in the order of the partitions and the HKeys in a
+// partition, so a key is returned at most once. The keys that are inserted
+// behind the position of the cursor are not returned.
+func (c *Cursor) Page(token string, limit int) (QueryResponse, string, error) {
+	entries, next, err := c.page(token, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	result := make(QueryResponse)
+	for _, entry := range entries {
+		value, err := c.dm.unmarshalValue(entry.Value())
+		if err != nil {
+			return nil, "", err
+		}
+		result[entry.Key()] = value
+	}
+	return result, next, nil
+}
+
+// encodeQueryToken encodes the position of a cursor: the partition ID and the
+// smallest HKey of the next page in the partition.
+func encodeQueryToken(partID, from uint64) string {
+	data := make([]byte, 16)
+	binary.BigEndian.PutUint64(data, partID)
+	binary.BigEndian.PutUint64(data[8:], from)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeQueryToken(token string) (partID, from uint64, err error) {
+	if token == "" {
+		return 0, 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) != 16 {
+		return 0, 0, neterrors.Wrap(neterrors.ErrInvalidArgument, "invalid continuation token")
+	}
+	return binary.BigEndian.Uint64(data), binary.BigEndian.Uint64(data[8:]), nil
+}
@@ -17,6 +17,7 @@ package dmap
 import (
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/buraksezer/olric/query"
 	"github.com/vmihailenco/msgpack"
 )
@@ -60,11 +61,28 @@ func (s *Service) localQueryOperation(w, r protocol.EncodeDecoder) {
 		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
 			req := r.(*protocol.DMapMessage)
 			partID := req.Extra().(protocol.LocalQueryExtra).PartID
+			if query.Aggregates(q) != nil {
+				return dm.runLocalAggregation(partID, q)
+			}
 			return dm.runLocalQuery(partID, q)
 		})
 }
 
 func (s *Service) queryOperation(w, r protocol.EncodeDecoder) {
+	s.queryOnPartition(w, r, func(entry storage.Entry) []byte {
+		return entry.Value()
+	})
+}
+
+// queryEntriesOperation is like queryOperation but it returns the encoded
+// entries with their metadata.
+func (s *Service) queryEntriesOperation(w, r protocol.EncodeDecoder) {
+	s.queryOnPartition(w, r, func(entry storage.Entry) []byte {
+		return entry.Encode()
+	})
+}
+
+func (s *Service) queryOnPartition(w, r protocol.EncodeDecoder, encode func(entry storage.Entry) []byte) {
 	s.queryOperationCommon(w, r,
 		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
 			req := r.(*protocol.DMapMessage)
@@ -78,6 +96,10 @@ func (s *Service) queryOperation(w, r protocol.EncodeDecoder) {
 			if partID >= s.config.PartitionCount {
 				return nil, ErrEndOfQuery
 			}
+			if c.aggregates != nil {
+				// A partial result is useless, so return the error as it is.
+				return c.aggregateOnOwners(partID)
+			}
 			responses, err := c.runQueryOnOwners(partID)
 			if err != nil {
 				return nil, ErrEndOfQuery
@@ -85,8 +107,49 @@ func (s *Service) queryOperation(w, r protocol.EncodeDecoder) {
 
 			data := make(QueryResponse)
 			for _, response := range responses {
-				data[response.Key()] = response.Value()
+				data[response.Key()] = encode(response)
 			}
 			return data, nil
 		})
 }
+
+func (s *Service) localQueryPageOperation(w, r protocol.EncodeDecoder) {
+	s.queryOperationCommon(w, r,
+		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
+			req := r.(*protocol.DMapMessage)
+			extra := req.Extra().(protocol.LocalQueryPageExtra)
+			return dm.runLocalQueryPage(extra.PartID, extra.From, int(extra.Limit), q)
+		})
+}
+
+// queryPage is a page of query results with the continuation token of the next page.
+type queryPage struct {
+	Entries map[string][]byte
+	Next    string
+}
+
+func (s *Service) queryPageOperation(w, r protocol.EncodeDecoder) {
+	s.queryOperationCommon(w, r,
+		func(dm *DMap, q query.M, r protocol.EncodeDecoder) (interface{}, error) {
+			req := r.(*protocol.DMapMessage)
+			c, err := dm.Query(q)
+			if err != nil {
+				return nil, err
+			}
+			defer c.Close()
+
+			limit := req.Extra().(protocol.QueryPageExtra).Limit
+			entries, next, err := c.page(req.Key(), int(limit))
+			if err != nil {
+				return nil, err
+			}
+			page := &queryPage{
+				Entries: make(map[string][]byte),
+				Next:    next,
+			}
+			for _, entry := range entries {
+				page.Entries[entry.Key()] = entry.Value()
+			}
+			return page, nil
+		})
+}
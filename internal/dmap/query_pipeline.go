@@ -15,8 +15,10 @@
 package dmap
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
+	"regexp"
 
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/buraksezer/olric/query"
@@ -26,6 +28,72 @@ type queryPipeline struct {
 	dm     *DMap
 	partID uint64
 	result queryResponse
+	// aggregation is computed instead of result, if the query has aggregation operators.
+	aggregation *query.Aggregation
+	aggregates  query.M
+	// predicates filters the entries by their values.
+	predicates query.M
+	// page is not nil, if the pipeline returns a page of the fragment. See runLocalQueryPage.
+	// The engines that implement storage.OrderedRanger fill the page without a full scan.
+	page *pageHeap
+	// limit stops the scan after the given number of matching entries, if it's not zero.
+	limit   int
+	matched int
+}
+
+// done returns true, if the scan has found enough entries.
+func (p *queryPipeline) done() bool {
+	return p.limit > 0 && p.matched >= p.limit
+}
+
+type pageItem struct {
+	hkey uint64
+	raw  []byte
+}
+
+// pageHeap keeps the entries with the smallest HKeys. It's a max-heap, the
+// root is evicted when a smaller HKey arrives.
+type pageHeap struct {
+	from      uint64
+	limit     int
+	items     []pageItem
+	truncated bool
+}
+
+func (h *pageHeap) Len() int           { return len(h.items) }
+func (h *pageHeap) Less(i, j int) bool { return h.items[i].hkey > h.items[j].hkey }
+func (h *pageHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *pageHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(pageItem))
+}
+
+func (h *pageHeap) Pop() interface{} {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// add adds the entry to the page, if it's among the smallest HKeys.
+func (h *pageHeap) add(hkey uint64, entry storage.Entry) {
+	if len(h.items) >= h.limit {
+		h.truncated = true
+		if hkey > h.items[0].hkey {
+			return
+		}
+	}
+	heap.Push(h, pageItem{hkey: hkey, raw: entry.Encode()})
+	if len(h.items) > h.limit {
+		heap.Pop(h)
+	}
+}
+
+func (p *queryPipeline) emit(hkey uint64, entry storage.Entry) {
+	if p.page != nil {
+		p.page.add(hkey, entry)
+		return
+	}
+	p.result[hkey] = entry.Encode()
 }
 
 func newQueryPipeline(dm *DMap, partID uint64) *queryPipeline {
@@ -53,11 +121,42 @@ func (p *queryPipeline) doOnKey(q query.M) error {
 	if !ok {
 		return fmt.Errorf("missing $regexMatch on $onKey")
 	}
+	if p.aggregation != nil {
+		return p.aggregate(f, expr)
+	}
 	nilValue, _ := p.dm.s.serializer.Marshal(nil)
 
-	return f.storage.RegexMatchOnKeys(expr, func(hkey uint64, entry storage.Entry) bool {
+	var matchErr error
+	visit := func(hkey uint64, entry storage.Entry) bool {
+		if p.done() {
+			return false
+		}
+		if p.page != nil && hkey < p.page.from {
+			return true
+		}
+		if isTombstone(entry) {
+			// Tombstones are required to reconcile the responses from the owners.
+			p.emit(hkey, entry)
+			return true
+		}
 		// Eliminate already expired k/v pairs
 		if !isKeyExpired(entry.TTL()) {
+			if p.predicates != nil {
+				var matched bool
+				matched, matchErr = p.match(entry)
+				if matchErr != nil {
+					return false
+				}
+				if !matched {
+					// The previous owners may have an older version that matches
+					// the predicates. Send a tombstone to shadow it.
+					tombstone := p.dm.engine.NewEntry()
+					tombstone.SetKey(entry.Key())
+					tombstone.SetTimestamp(entry.Timestamp())
+					p.emit(hkey, tombstone)
+					return true
+				}
+			}
 			options, ok := q["$options"].(query.M)
 			if ok {
 				onValue, ok := options["$onValue"].(query.M)
@@ -68,15 +167,95 @@ func (p *queryPipeline) doOnKey(q query.M) error {
 					}
 				}
 			}
-			p.result[hkey] = entry.Encode()
+			p.emit(hkey, entry)
+			p.matched++
 		}
-		return true
+		return !p.done()
+	}
+	if ranger, ok := f.storage.(storage.OrderedRanger); ok && p.page != nil {
+		err = p.rangePage(ranger, expr, visit)
+	} else {
+		err = f.storage.RegexMatchOnKeys(expr, visit)
+	}
+	if err != nil {
+		return err
+	}
+	return matchErr
+}
+
+// rangePage seeks to the beginning of the page and scans the fragment in HKey
+// order until the page is filled, instead of scanning the whole fragment.
+func (p *queryPipeline) rangePage(r storage.OrderedRanger, expr string, f func(uint64, storage.Entry) bool) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	r.RangeFrom(p.page.from, func(hkey uint64, entry storage.Entry) bool {
+		if !re.MatchString(entry.Key()) {
+			return true
+		}
+		if len(p.page.items) >= p.page.limit {
+			// There are more entries after the page.
+			p.page.truncated = true
+			return false
+		}
+		return f(hkey, entry)
 	})
+	return nil
+}
+
+// match evaluates the value predicates of the query on the given entry.
+func (p *queryPipeline) match(entry storage.Entry) (bool, error) {
+	value, err := p.dm.unmarshalValue(entry.Value())
+	if err != nil {
+		return false, err
+	}
+	return query.Match(p.predicates, value), nil
+}
+
+func (p *queryPipeline) aggregate(f *fragment, expr string) error {
+	needsValues := query.NeedsValues(p.aggregates) || p.predicates != nil
+	var aggErr error
+	err := f.storage.RegexMatchOnKeys(expr, func(hkey uint64, entry storage.Entry) bool {
+		if p.done() {
+			return false
+		}
+		if isTombstone(entry) || isKeyExpired(entry.TTL()) {
+			return true
+		}
+		var value interface{}
+		if needsValues {
+			value, aggErr = p.dm.unmarshalValue(entry.Value())
+			if aggErr != nil {
+				return false
+			}
+		}
+		if p.predicates != nil && !query.Match(p.predicates, value) {
+			return true
+		}
+		p.aggregation.Add(value)
+		p.matched++
+		return !p.done()
+	})
+	if err != nil {
+		return err
+	}
+	return aggErr
 }
 
 func (p *queryPipeline) execute(q query.M) (queryResponse, error) {
-	if onKey, ok := q["$onKey"]; ok {
-		if err := p.doOnKey(onKey.(query.M)); err != nil {
+	p.predicates = query.Predicates(q)
+	if p.page == nil {
+		// A page has its own limit.
+		p.limit = query.Limit(q)
+	}
+	onKey, ok := q["$onKey"].(query.M)
+	if !ok && p.predicates != nil {
+		// Evaluate the value predicates on all the keys.
+		onKey, ok = query.M{"$regexMatch": ""}, true
+	}
+	if ok {
+		if err := p.doOnKey(onKey); err != nil {
 			return nil, err
 		}
 	}
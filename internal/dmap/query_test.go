@@ -15,15 +15,23 @@
 package dmap
 
 import (
+	"container/heap"
+	"errors"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/kvstore/entry"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/buraksezer/olric/internal/transport"
+	"github.com/buraksezer/olric/pkg/neterrors"
 	"github.com/buraksezer/olric/query"
+	"github.com/buraksezer/olric/serializer"
 	"github.com/vmihailenco/msgpack"
 )
 
@@ -349,3 +357,520 @@ func TestDMap_QueryEndOfKeySpace(t *testing.T) {
 		t.Fatalf("Expected protocol.ErrEndOfQuery (%d). Got: %d", protocol.StatusErrEndOfQuery, resp.Status())
 	}
 }
+
+func TestDMap_QueryAggregateCluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	var key string
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			key = "even:" + testutil.ToKey(i)
+		} else {
+			key = "odd:" + testutil.ToKey(i)
+		}
+		err = dm1.Put(key, i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+	// Non-numeric values are counted but ignored by the other operators.
+	err = dm1.Put("even:name", "foobar")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm2, err := s2.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	q := query.M{
+		"$onKey": query.M{
+			"$regexMatch": "even:",
+		},
+		"$aggregate": query.M{
+			"$count": true,
+			"$sum":   true,
+			"$min":   true,
+			"$max":   true,
+			"$avg":   true,
+		},
+	}
+	c, err := dm2.Query(q)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	result, err := c.Aggregate()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	expected := query.Aggregation{Count: 51, Sum: 2450, Min: 0, Max: 98, Avg: 49, Numeric: 50}
+	if *result != expected {
+		t.Fatalf("Expected %+v. Got: %+v", expected, *result)
+	}
+
+	// Range ignores the aggregation operators.
+	c, err = dm2.Query(q)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	count := 0
+	err = c.Range(func(key string, value interface{}) bool {
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 51 {
+		t.Fatalf("Expected count is 51. Got: %d", count)
+	}
+}
+
+func TestDMap_QueryAggregateCount(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		err = dm.Delete(testutil.ToKey(i))
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	q, err := dm.Query(
+		query.M{
+			"$onKey": query.M{
+				"$regexMatch": "",
+			},
+			"$aggregate": query.M{
+				"$count": true,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	result, err := q.Aggregate()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if result.Count != 90 {
+		t.Fatalf("Expected count is 90. Got: %d", result.Count)
+	}
+	if result.Numeric != 0 {
+		t.Fatalf("Expected the values are not decoded. Got: %d numeric values", result.Numeric)
+	}
+}
+
+func TestDMap_QueryOnValueCluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	var services []*Service
+	for i := 0; i < 2; i++ {
+		c := testutil.NewConfig()
+		c.Serializer = serializer.NewMsgpackSerializer()
+		e := testcluster.NewEnvironment(c)
+		services = append(services, cluster.AddMember(e).(*Service))
+	}
+	defer cluster.Shutdown()
+
+	dm1, err := services[0].NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	cities := []string{"Istanbul", "Ankara", "Izmir"}
+	for i := 0; i < 90; i++ {
+		value := map[string]interface{}{
+			"age": i,
+			"address": map[string]interface{}{
+				"city": cities[i%3],
+			},
+		}
+		err = dm1.Put("user:"+testutil.ToKey(i), value)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+	err = dm1.Put("user:nobody", map[string]interface{}{"age": 100})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	dm2, err := services[1].NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	q, err := dm2.Query(
+		query.M{
+			"$onValue": query.M{
+				"address.city": query.M{"$in": []string{"Istanbul", "Izmir"}},
+				"age":          query.M{"$gt": 44},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	defer q.Close()
+
+	count := 0
+	err = q.Range(func(key string, value interface{}) bool {
+		count++
+		user := value.(map[string]interface{})
+		if user["address"].(map[string]interface{})["city"] == "Ankara" {
+			t.Fatalf("Unexpected value: %v", value)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	// 45 <= age < 90, two third of them
+	if count != 30 {
+		t.Fatalf("Expected count is 30. Got: %d", count)
+	}
+
+	q, err = dm2.Query(
+		query.M{
+			"$onValue": query.M{
+				"address": query.M{"$exists": false},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	defer q.Close()
+
+	count = 0
+	err = q.Range(func(key string, value interface{}) bool {
+		count++
+		if key != "user:nobody" {
+			t.Fatalf("Expected key is user:nobody. Got: %s", key)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count is 1. Got: %d", count)
+	}
+}
+
+func TestDMap_QueryOnValueAggregate(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	q, err := dm.Query(
+		query.M{
+			"$onValue": query.M{
+				"$lt": 10,
+			},
+			"$aggregate": query.M{
+				"$count": true,
+				"$sum":   true,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	result, err := q.Aggregate()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if result.Count != 10 {
+		t.Fatalf("Expected count is 10. Got: %d", result.Count)
+	}
+	if result.Sum != 45 {
+		t.Fatalf("Expected sum is 45. Got: %v", result.Sum)
+	}
+}
+
+func TestDMap_QueryPage(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		err = dm1.Put(testutil.ToKey(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	dm2, err := s2.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	q := query.M{"$onKey": query.M{"$regexMatch": ""}}
+
+	keys := make(map[string]struct{})
+	var token string
+	for {
+		// The cursors are stateless, every page is requested by a new cursor.
+		c, err := dm2.Query(q)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		page, next, err := c.Page(token, 37)
+		c.Close()
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if len(page) > 37 {
+			t.Fatalf("Expected at most 37 entries. Got: %d", len(page))
+		}
+		for key, value := range page {
+			if key != testutil.ToKey(value.(int)) {
+				t.Fatalf("Unexpected key/value pair: %s/%v", key, value)
+			}
+			if _, ok := keys[key]; ok {
+				t.Fatalf("Key returned more than once: %s", key)
+			}
+			keys[key] = struct{}{}
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if len(keys) != 1000 {
+		t.Fatalf("Expected key count is 1000. Got: %d", len(keys))
+	}
+}
+
+func TestDMap_QueryPage_Predicates(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	c, err := dm.Query(query.M{"$onValue": query.M{"$lt": 25}})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	defer c.Close()
+
+	count := 0
+	var token string
+	for {
+		page, next, err := c.Page(token, 10)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		count += len(page)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if count != 25 {
+		t.Fatalf("Expected count is 25. Got: %d", count)
+	}
+}
+
+func TestDMap_QueryPage_Invalid_Token(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	c, err := dm.Query(query.M{"$onKey": query.M{"$regexMatch": ""}})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	defer c.Close()
+
+	_, _, err = c.Page("foobar", 10)
+	if !errors.Is(err, neterrors.ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument. Got: %v", err)
+	}
+	_, _, err = c.Page("", 0)
+	if !errors.Is(err, neterrors.ErrInvalidArgument) {
+		t.Fatalf("Expected ErrInvalidArgument. Got: %v", err)
+	}
+}
+
+func TestDMap_pageHeap(t *testing.T) {
+	h := &pageHeap{from: 10, limit: 3}
+	for _, hkey := range []uint64{50, 20, 40, 10, 30} {
+		e := entry.New()
+		e.SetKey(strconv.FormatUint(hkey, 10))
+		h.add(hkey, e)
+	}
+	if !h.truncated {
+		t.Fatalf("Expected the page is truncated")
+	}
+	var hkeys []uint64
+	for h.Len() > 0 {
+		hkeys = append(hkeys, heap.Pop(h).(pageItem).hkey)
+	}
+	if !reflect.DeepEqual(hkeys, []uint64{30, 20, 10}) {
+		t.Fatalf("Expected the smallest HKeys. Got: %v", hkeys)
+	}
+}
+
+func TestDMap_QueryLimit(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = dm1.Put(testutil.ToKey(i), i)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	q := query.M{
+		"$onKey": query.M{"$regexMatch": ""},
+		"$limit": 10,
+	}
+	// The owners stop scanning after $limit entries.
+	for partID := uint64(0); partID < s1.config.PartitionCount; partID++ {
+		response, err := dm1.runLocalQuery(partID, q)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if len(response) > 10 {
+			t.Fatalf("Expected at most 10 entries. Got: %d", len(response))
+		}
+	}
+
+	dm2, err := s2.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	c, err := dm2.Query(q)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	count := 0
+	err = c.Range(func(key string, value interface{}) bool {
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 10 {
+		t.Fatalf("Expected count is 10. Got: %d", count)
+	}
+
+	q["$aggregate"] = query.M{"$count": true}
+	c, err = dm2.Query(q)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	result, err := c.Aggregate()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if result.Count != 10 {
+		t.Fatalf("Expected count is 10. Got: %d", result.Count)
+	}
+
+	q["$aggregate"] = query.M{"$count": true, "$sum": true}
+	_, err = dm2.Query(q)
+	if !errors.Is(err, query.ErrInvalidQuery) {
+		t.Fatalf("Expected ErrInvalidQuery. Got: %v", err)
+	}
+}
+
+func TestDMap_QueryRangeEntries(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		err = dm.PutEx(testutil.ToKey(i), i, time.Hour)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	c, err := dm.Query(query.M{"$onKey": query.M{"$regexMatch": ""}})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	count := 0
+	err = c.RangeEntries(func(e *Entry) bool {
+		count++
+		if e.Key != testutil.ToKey(e.Value.(int)) {
+			t.Fatalf("Unexpected key/value pair: %s/%v", e.Key, e.Value)
+		}
+		if e.TTL == 0 {
+			t.Fatalf("Expected TTL is set for %s", e.Key)
+		}
+		if e.Timestamp == 0 || e.LastAccess == 0 {
+			t.Fatalf("Expected timestamp and last access are set for %s", e.Key)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if count != 10 {
+		t.Fatalf("Expected count is 10. Got: %d", count)
+	}
+}
@@ -17,6 +17,7 @@ package dmap
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/hlc"
 	"github.com/buraksezer/olric/internal/locker"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/service"
@@ -42,7 +44,7 @@ type storageMap struct {
 }
 
 type Service struct {
-	sync.RWMutex // protects dmaps map
+	sync.RWMutex // protects dmaps and processors maps
 
 	log        *flog.Logger
 	config     *config.Config
@@ -52,9 +54,17 @@ type Service struct {
 	primary    *partitions.Partitions
 	backup     *partitions.Partitions
 	locker     *locker.Locker
+	clock      *hlc.Clock
 	dmaps      map[string]*DMap
+	processors map[string]EntryProcessor
+	// publisher and notifications are used by the keyspace notifications.
+	publisher     keyspacePublisher
+	notifications chan keyspaceNotification
+	// subscriber receives the invalidations for the near caches.
+	subscriber keyspaceSubscriber
 	operations map[protocol.OpCode]func(w, r protocol.EncodeDecoder)
 	storage    *storageMap
+	memory     *memoryBudget
 	wg         sync.WaitGroup
 	ctx        context.Context
 	cancel     context.CancelFunc
@@ -62,6 +72,9 @@ type Service struct {
 
 func NewService(e *environment.Environment) (service.Service, error) {
 	ctx, cancel := context.WithCancel(context.Background())
+	// The DTopic service is not available in all environments.
+	publisher, _ := e.Get("dtopic").(keyspacePublisher)
+	subscriber, _ := e.Get("dtopic").(keyspaceSubscriber)
 	return &Service{
 		config:     e.Get("config").(*config.Config),
 		serializer: e.Get("config").(*config.Config).Serializer,
@@ -71,14 +84,20 @@ func NewService(e *environment.Environment) (service.Service, error) {
 		primary:    e.Get("primary").(*partitions.Partitions),
 		backup:     e.Get("backup").(*partitions.Partitions),
 		locker:     e.Get("locker").(*locker.Locker),
+		clock:      hlc.New(),
 		storage: &storageMap{
 			engines: make(map[string]storage.Engine),
 			configs: make(map[string]map[string]interface{}),
 		},
-		dmaps:      make(map[string]*DMap),
-		operations: make(map[protocol.OpCode]func(w, r protocol.EncodeDecoder)),
-		ctx:        ctx,
-		cancel:     cancel,
+		memory:        newMemoryBudget(),
+		dmaps:         make(map[string]*DMap),
+		processors:    make(map[string]EntryProcessor),
+		publisher:     publisher,
+		notifications: make(chan keyspaceNotification, keyspaceNotificationBufferSize),
+		subscriber:    subscriber,
+		operations:    make(map[protocol.OpCode]func(w, r protocol.EncodeDecoder)),
+		ctx:           ctx,
+		cancel:        cancel,
 	}, nil
 }
 
@@ -119,22 +138,29 @@ func (s *Service) callCompactionOnStorage(f *fragment) {
 	}
 }
 
-func (s *Service) requestTo(addr string, req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
-	resp, err := s.client.RequestTo(addr, req)
+func (s *Service) requestTo(ctx context.Context, addr string, req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
+	resp, err := s.client.RequestToContext(ctx, addr, req)
 	if err != nil {
 		return nil, err
 	}
-	status := resp.Status()
-	if status == protocol.StatusOK {
-		return resp, nil
+	if err = statusToError(resp.Status(), resp.Value()); err != nil {
+		return nil, err
 	}
-	switch resp.Status() {
+	return resp, nil
+}
+
+// statusToError converts a status code to an error. value is the value of
+// the response.
+func statusToError(status protocol.StatusCode, value []byte) error {
+	switch status {
+	case protocol.StatusOK:
+		return nil
 	case protocol.StatusErrInternalFailure:
-		return nil, neterrors.Wrap(neterrors.ErrInternalFailure, string(resp.Value()))
+		return neterrors.Wrap(neterrors.ErrInternalFailure, string(value))
 	case protocol.StatusErrInvalidArgument:
-		return nil, neterrors.Wrap(neterrors.ErrInvalidArgument, string(resp.Value()))
+		return neterrors.Wrap(neterrors.ErrInvalidArgument, string(value))
 	}
-	return nil, neterrors.GetByCode(status)
+	return neterrors.GetByCode(status)
 }
 
 // Start starts the distributed map service.
@@ -148,6 +174,16 @@ func (s *Service) Start() error {
 	s.wg.Add(1)
 	go s.evictKeysAtBackground()
 
+	if s.config.DMaps.MaxMemory > 0 {
+		s.wg.Add(1)
+		go s.maxMemoryWorker()
+	}
+
+	if s.publisher != nil {
+		s.wg.Add(1)
+		go s.keyspaceNotificationWorker()
+	}
+
 	return nil
 }
 
@@ -168,7 +204,40 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 	case <-done:
 	}
-	return nil
+
+	err := s.snapshot()
+	if err != nil {
+		s.log.V(2).Printf("[ERROR] Failed to take a snapshot of DMaps: %v", err)
+	}
+	s.closeFragments()
+	return err
+}
+
+// closeFragments closes the storage engines of the fragments. Persistent storage
+// engines flush and release their files here.
+func (s *Service) closeFragments() {
+	closer := func(part *partitions.Partition) {
+		part.Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+
+			f := tmp.(*fragment)
+			f.Lock()
+			defer f.Unlock()
+
+			if err := f.Close(); err != nil {
+				s.log.V(3).Printf("[ERROR] Failed to close DMap fragment (kind: %s): %s on PartID: %d: %v",
+					part.Kind(), name, part.ID(), err)
+			}
+			return true
+		})
+	}
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		closer(s.primary.PartitionByID(partID))
+		closer(s.backup.PartitionByID(partID))
+	}
 }
 
 var _ service.Service = (*Service)(nil)
@@ -0,0 +1,237 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack"
+)
+
+// snapshotFileName is the name of the snapshot file in DMaps.SnapshotDir. The file
+// is a stream of msgpack encoded fragmentPacks, the payloads are exported by the
+// storage engines like the fragments that are moved to another member.
+const snapshotFileName = "dmap.snapshot"
+
+func (s *Service) snapshotPath() string {
+	return filepath.Join(s.config.DMaps.SnapshotDir, snapshotFileName)
+}
+
+func (s *Service) snapshotFragment(enc *msgpack.Encoder, part *partitions.Partition, name string, f *fragment) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.storage.Name() == config.DiskStorageEngine {
+		// The fragment is already on disk, it's opened again by Restore.
+		return nil
+	}
+
+	// The transfer iterator pops the exported tables. The entries are copied to
+	// a new engine instance, so the node can keep serving requests from the
+	// fragment in the meantime.
+	tmp, err := f.storage.Fork(nil)
+	if err != nil {
+		return err
+	}
+	if err = tmp.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+	}()
+	f.storage.Range(func(hkey uint64, entry storage.Entry) bool {
+		err = tmp.Put(hkey, entry)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	i := tmp.TransferIterator()
+	for i.Next() {
+		payload, err := i.Export()
+		if errors.Is(err, io.EOF) {
+			// All the remaining tables are recycled.
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fp := &fragmentPack{
+			PartID:  part.ID(),
+			Kind:    part.Kind(),
+			Name:    strings.TrimPrefix(name, "dmap."),
+			Payload: payload,
+		}
+		if err = enc.Encode(fp); err != nil {
+			return err
+		}
+		if err = i.Pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot dumps all DMap fragments on this node to the snapshot directory.
+func (s *Service) snapshot() error {
+	if s.config.DMaps.SnapshotDir == "" {
+		// Snapshots are disabled.
+		return nil
+	}
+
+	if err := os.MkdirAll(s.config.DMaps.SnapshotDir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first. A half-written snapshot should never
+	// be loaded.
+	tmp := s.snapshotPath() + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	w := bufio.NewWriter(file)
+	enc := msgpack.NewEncoder(w)
+
+	var total int
+	var snapshotErr error
+	dumper := func(part *partitions.Partition) {
+		part.Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+
+			err := s.snapshotFragment(enc, part, name.(string), tmp.(*fragment))
+			if err != nil {
+				snapshotErr = fmt.Errorf("failed to dump DMap fragment (kind: %s): %s on PartID: %d: %w",
+					part.Kind(), name, part.ID(), err)
+				return false
+			}
+			total++
+			return true
+		})
+	}
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		dumper(s.primary.PartitionByID(partID))
+		if snapshotErr != nil {
+			return snapshotErr
+		}
+		dumper(s.backup.PartitionByID(partID))
+		if snapshotErr != nil {
+			return snapshotErr
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.snapshotPath()); err != nil {
+		return err
+	}
+	s.log.V(2).Printf("[INFO] %d DMap fragment(s) have been dumped to %s", total, s.snapshotPath())
+	return nil
+}
+
+// Restore opens the fragments of the persistent storage engines and loads the
+// DMap fragments from the snapshot directory, if there is a snapshot. It should
+// be called before bootstrapping the node. The snapshot file is removed after
+// a successful restore.
+func (s *Service) Restore() error {
+	if err := s.openPersistentFragments(); err != nil {
+		return err
+	}
+
+	if s.config.DMaps.SnapshotDir == "" {
+		// Snapshots are disabled.
+		return nil
+	}
+
+	file, err := os.Open(s.snapshotPath())
+	if os.IsNotExist(err) {
+		// Nothing to restore
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var total int
+	dec := msgpack.NewDecoder(bufio.NewReader(file))
+	for {
+		fp := &fragmentPack{}
+		err = dec.Decode(fp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+
+		if fp.PartID >= s.config.PartitionCount {
+			return fmt.Errorf("invalid partition id in snapshot: %d", fp.PartID)
+		}
+		var part *partitions.Partition
+		if fp.Kind == partitions.PRIMARY {
+			part = s.primary.PartitionByID(fp.PartID)
+		} else {
+			part = s.backup.PartitionByID(fp.PartID)
+		}
+
+		dm, err := s.createDMap(fp.Name)
+		if err != nil {
+			return err
+		}
+		if err = dm.mergeFragments(part, fp); err != nil {
+			return fmt.Errorf("failed to restore DMap (kind: %s): %s on PartID: %d: %w",
+				fp.Kind, fp.Name, fp.PartID, err)
+		}
+		total++
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+	// The fragments are in memory now. Loading the same snapshot after a crash
+	// would bring back stale data.
+	if err = os.Remove(s.snapshotPath()); err != nil {
+		return err
+	}
+	s.log.V(2).Printf("[INFO] %d DMap fragment pack(s) have been restored from %s", total, s.snapshotPath())
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Snapshot(t *testing.T) {
+	snapshotDir := t.TempDir()
+
+	c := testutil.NewConfig()
+	c.DMaps.SnapshotDir = snapshotDir
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+	cluster.Shutdown()
+
+	_, err = os.Stat(filepath.Join(snapshotDir, snapshotFileName))
+	require.NoError(t, err)
+
+	c = testutil.NewConfig()
+	c.DMaps.SnapshotDir = snapshotDir
+	cluster = testcluster.New(NewService)
+	s = cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	require.NoError(t, s.Restore())
+
+	dm, err = s.NewDMap("mymap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		value, err := dm.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+
+	// The snapshot is consumed.
+	_, err = os.Stat(filepath.Join(snapshotDir, snapshotFileName))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDMap_Snapshot_Keeps_Fragments(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.SnapshotDir = t.TempDir()
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.snapshot())
+
+	// The node keeps serving the requests after taking a snapshot.
+	for i := 0; i < 100; i++ {
+		value, err := dm.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+	require.NoError(t, dm.Put(testutil.ToKey(100), testutil.ToVal(100)))
+	n, err := dm.Len()
+	require.NoError(t, err)
+	require.Equal(t, 101, n)
+}
+
+func TestDMap_Restore_NoSnapshot(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.SnapshotDir = t.TempDir()
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	require.NoError(t, s.Restore())
+}
@@ -0,0 +1,78 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// A tombstone is an entry without a value. Values are always serialized before
+// storing them, so a live entry never has an empty value. Tombstones take part
+// in version reconciliation like the live entries, a stale replica or a previous
+// owner cannot resurrect a deleted key.
+
+func isTombstone(entry storage.Entry) bool {
+	return len(entry.Value()) == 0
+}
+
+// putTombstone replaces the entry with a tombstone. It doesn't overwrite a newer
+// version of the key. It's not thread-safe.
+func (dm *DMap) putTombstone(f *fragment, hkey uint64, key string, timestamp int64) error {
+	current, err := f.storage.Get(hkey)
+	if err == nil && current.Timestamp() > timestamp {
+		// There is a newer version of the key.
+		return nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+
+	tombstone := f.storage.NewEntry()
+	tombstone.SetKey(key)
+	tombstone.SetTimestamp(timestamp)
+	err = f.put(hkey, tombstone)
+	if errors.Is(err, storage.ErrKeyTooLarge) {
+		err = ErrKeyTooLarge
+	}
+	return err
+}
+
+// isTombstoneStale returns true if the grace period of the tombstone is over.
+func (s *Service) isTombstoneStale(entry storage.Entry) bool {
+	deadline := entry.Timestamp() + s.config.DMaps.TombstoneGracePeriod.Nanoseconds()
+	return time.Now().UnixNano() >= deadline
+}
+
+// deleteStaleTombstones removes the tombstones that outlived the grace period. It
+// returns the number of removed tombstones. It's not thread-safe.
+func (s *Service) deleteStaleTombstones(f *fragment) (int, error) {
+	var hkeys []uint64
+	f.storage.Range(func(hkey uint64, entry storage.Entry) bool {
+		if isTombstone(entry) && s.isTombstoneStale(entry) {
+			hkeys = append(hkeys, hkey)
+		}
+		return true
+	})
+
+	for _, hkey := range hkeys {
+		if err := f.delete(hkey); err != nil {
+			return 0, err
+		}
+	}
+	return len(hkeys), nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Tombstone_Delete(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("mykey", "myvalue")
+	require.NoError(t, err)
+
+	err = dm.Delete("mykey")
+	require.NoError(t, err)
+
+	_, err = dm.Get("mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	hkey := partitions.HKey("mymap", "mykey")
+	f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.PRIMARY))
+	require.NoError(t, err)
+
+	entry, err := f.storage.Get(hkey)
+	require.NoError(t, err)
+	require.True(t, isTombstone(entry))
+
+	// A tombstone doesn't prevent the key from being created again.
+	err = dm.PutIf("mykey", "myvalue", IfNotFound)
+	require.NoError(t, err)
+
+	value, err := dm.Get("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+}
+
+func TestDMap_Tombstone_StaleReplica(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.ReadRepair = true
+	c1.ReplicaCount = 2
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.ReadRepair = true
+	c2.ReplicaCount = 2
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	key := "mykey"
+	err = dm1.Put(key, "myvalue")
+	require.NoError(t, err)
+
+	hkey := partitions.HKey("mymap", key)
+	owner, replica := s1, s2
+	if !s1.primary.PartitionByHKey(hkey).Owner().CompareByID(s1.rt.This()) {
+		owner, replica = s2, s1
+	}
+
+	// Delete the key only on the partition owner. The replica misses the delete.
+	dm, err := owner.NewDMap("mymap")
+	require.NoError(t, err)
+	f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.PRIMARY))
+	require.NoError(t, err)
+	f.Lock()
+	err = dm.putTombstone(f, hkey, key, owner.clock.Now())
+	f.Unlock()
+	require.NoError(t, err)
+
+	// The live version on the replica must not win.
+	_, err = dm1.Get(key)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Read-repair propagates the tombstone.
+	rdm, err := replica.NewDMap("mymap")
+	require.NoError(t, err)
+	rf, err := rdm.loadFragment(rdm.getPartitionByHKey(hkey, partitions.BACKUP))
+	require.NoError(t, err)
+	rf.RLock()
+	entry, err := rf.storage.Get(hkey)
+	rf.RUnlock()
+	require.NoError(t, err)
+	require.True(t, isTombstone(entry))
+}
+
+func TestDMap_Tombstone_GracePeriod(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.TombstoneGracePeriod = 100 * time.Millisecond
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		err = dm.Delete(testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	countFragments := func() int {
+		var count int
+		for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+			part := s.primary.PartitionByID(partID)
+			part.Map().Range(func(name, dm interface{}) bool { count++; return true })
+		}
+		return count
+	}
+
+	// The tombstones are still in the grace period.
+	s.deleteEmptyFragments()
+	require.NotEqual(t, 0, countFragments())
+
+	<-time.After(100 * time.Millisecond)
+	s.deleteEmptyFragments()
+	require.Equal(t, 0, countFragments())
+}
+
+func TestDMap_Tombstone_Length(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.MaxKeys = 70
+	c.DMaps.EvictionPolicy = config.LRUEviction
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 50; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+	for i := 0; i < 50; i++ {
+		err = dm.Delete(testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	var length int
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		length += part.Length()
+	}
+	require.Equal(t, 0, length)
+
+	// The tombstones don't trigger the eviction.
+	for i := 50; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+}
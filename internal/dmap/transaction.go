@@ -0,0 +1,373 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack"
+)
+
+// ErrTransactionConflict is returned by Exec when one of the watched keys has
+// been modified since it was watched.
+var ErrTransactionConflict = neterrors.New(protocol.StatusErrTransactionConflict, "transaction conflict")
+
+// txCommand is a prepared command of a transaction. A nil value deletes the key.
+type txCommand struct {
+	key   string
+	hkey  uint64
+	value []byte
+}
+
+// txHKey returns the hkey of a key in the transaction. All keys of a transaction
+// have to share the same partition, use hash tags to co-locate them.
+func (dm *DMap) txHKey(tx *protocol.Transaction) (uint64, error) {
+	var keys []string
+	for _, w := range tx.Watches {
+		keys = append(keys, w.Key)
+	}
+	for _, c := range tx.Commands {
+		keys = append(keys, c.Key)
+	}
+	if len(keys) == 0 {
+		return 0, neterrors.Wrap(neterrors.ErrInvalidArgument, "empty transaction")
+	}
+
+	hkey := dm.hkey(keys[0])
+	partID := dm.s.primary.PartitionIDByHKey(hkey)
+	for _, key := range keys[1:] {
+		if dm.s.primary.PartitionIDByHKey(dm.hkey(key)) != partID {
+			return 0, neterrors.Wrap(neterrors.ErrInvalidArgument,
+				fmt.Sprintf("keys of a transaction must be in the same partition: %s", key))
+		}
+	}
+	return hkey, nil
+}
+
+// currentValueOnFragment returns the value of the key on the fragment. It returns
+// nil if the key doesn't exist. It's not thread-safe.
+func currentValueOnFragment(f *fragment, hkey uint64) ([]byte, error) {
+	entry, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isTombstone(entry) || isKeyExpired(entry.TTL()) {
+		return nil, nil
+	}
+	return entry.Value(), nil
+}
+
+// prepareTransaction computes the values that will be written by the commands
+// without modifying the fragment. A failing command aborts the whole transaction
+// before anything is written. It's not thread-safe.
+func (dm *DMap) prepareTransaction(f *fragment, tx *protocol.Transaction) ([]txCommand, []protocol.BatchResult, error) {
+	// pending keeps the values written by the previous commands of the transaction.
+	pending := make(map[string][]byte)
+	commands := make([]txCommand, 0, len(tx.Commands))
+	results := make([]protocol.BatchResult, 0, len(tx.Commands))
+	for _, c := range tx.Commands {
+		cmd := txCommand{key: c.Key, hkey: dm.hkey(c.Key)}
+		switch c.Op {
+		case protocol.OpPut:
+			cmd.value = c.Value
+			results = append(results, newBatchResult(c.Key, nil, nil))
+		case protocol.OpDelete:
+			results = append(results, newBatchResult(c.Key, nil, nil))
+		case protocol.OpIncr:
+			var delta interface{}
+			if err := dm.s.serializer.Unmarshal(c.Value, &delta); err != nil {
+				return nil, nil, err
+			}
+			d, err := valueToInt(delta)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			current, ok := pending[c.Key]
+			if !ok {
+				current, err = currentValueOnFragment(f, cmd.hkey)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			var value int
+			if current != nil {
+				var tmp interface{}
+				if err = dm.s.serializer.Unmarshal(current, &tmp); err != nil {
+					return nil, nil, err
+				}
+				if value, err = valueToInt(tmp); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			cmd.value, err = dm.s.serializer.Marshal(value + d)
+			if err != nil {
+				return nil, nil, err
+			}
+			results = append(results, newBatchResult(c.Key, cmd.value, nil))
+		default:
+			err := neterrors.Wrap(neterrors.ErrInvalidArgument, fmt.Sprintf("invalid transaction command: %d", c.Op))
+			return nil, nil, err
+		}
+		pending[c.Key] = cmd.value
+		commands = append(commands, cmd)
+	}
+	return commands, results, nil
+}
+
+// txBackup is the state of a key before the transaction. entry is nil if the key
+// doesn't exist.
+type txBackup struct {
+	key   string
+	hkey  uint64
+	entry storage.Entry
+}
+
+// backupTransactionKeys saves the current state of the keys that are modified by
+// the commands. It's not thread-safe.
+func (dm *DMap) backupTransactionKeys(f *fragment, commands []txCommand) ([]txBackup, error) {
+	var backups []txBackup
+	seen := make(map[uint64]struct{})
+	for _, cmd := range commands {
+		if _, ok := seen[cmd.hkey]; ok {
+			continue
+		}
+		seen[cmd.hkey] = struct{}{}
+
+		b := txBackup{key: cmd.key, hkey: cmd.hkey}
+		entry, err := f.storage.Get(cmd.hkey)
+		if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return nil, err
+		}
+		if err == nil && !isTombstone(entry) && !isKeyExpired(entry.TTL()) {
+			// The value may point to the memory of the storage engine.
+			value := make([]byte, len(entry.Value()))
+			copy(value, entry.Value())
+			entry.SetValue(value)
+			b.entry = entry
+		}
+		backups = append(backups, b)
+	}
+	return backups, nil
+}
+
+// rollbackTransaction restores the keys that are touched by the applied commands.
+// The restored versions are stamped again, so they win against the versions that
+// have been replicated by the transaction. It's not thread-safe.
+func (dm *DMap) rollbackTransaction(ctx context.Context, f *fragment, backups []txBackup, touched map[uint64]struct{}) {
+	for _, b := range backups {
+		if _, ok := touched[b.hkey]; !ok {
+			continue
+		}
+
+		var err error
+		if b.entry == nil {
+			err = dm.deleteOnMapStore(ctx, f, b.key)
+			if err == nil {
+				var current []byte
+				current, err = currentValueOnFragment(f, b.hkey)
+				if err == nil && current != nil {
+					err = dm.deleteOnCluster(ctx, b.hkey, b.key, f, dm.s.clock.Now())
+				}
+			}
+		} else {
+			var timeout time.Duration
+			if b.entry.TTL() != 0 {
+				timeout = time.Duration(b.entry.TTL()-time.Now().UnixNano()/1000000) * time.Millisecond
+				if timeout <= 0 {
+					// The key has expired in the meantime.
+					timeout = time.Millisecond
+				}
+			}
+			e := newEnv(ctx, protocol.OpPut, dm.name, b.key, b.entry.Value(), timeout, 0, partitions.PRIMARY)
+			e.hkey = b.hkey
+			e.fragment = f
+			err = dm.putOnLockedFragment(e)
+		}
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to roll back key: %s on DMap: %s: %v", b.key, dm.name, err)
+		}
+	}
+}
+
+// applyTransaction applies the commands. If a command fails, the keys that are
+// touched by the transaction are restored.
+func (dm *DMap) applyTransaction(ctx context.Context, f *fragment, commands []txCommand) error {
+	backups, err := dm.backupTransactionKeys(f, commands)
+	if err != nil {
+		return err
+	}
+
+	touched := make(map[uint64]struct{})
+	for _, cmd := range commands {
+		// A failing command may be applied partially, on the MapStore or on some
+		// of the replicas.
+		touched[cmd.hkey] = struct{}{}
+		if err = dm.applyTransactionCommand(ctx, f, cmd); err != nil {
+			dm.rollbackTransaction(ctx, f, backups, touched)
+			return err
+		}
+	}
+	return nil
+}
+
+func (dm *DMap) applyTransactionCommand(ctx context.Context, f *fragment, cmd txCommand) error {
+	if cmd.value == nil {
+		if err := dm.deleteOnMapStore(ctx, f, cmd.key); err != nil {
+			return err
+		}
+		current, err := currentValueOnFragment(f, cmd.hkey)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			// DeleteMisses is the number of deletions reqs for missing keys
+			DeleteMisses.Increase(1)
+			return nil
+		}
+		timestamp := dm.s.clock.Now()
+		if err = dm.deleteOnCluster(ctx, cmd.hkey, cmd.key, f, timestamp); err != nil {
+			return err
+		}
+		dm.notifyKeyspace(KeyspaceDelete, cmd.key, timestamp, nil)
+		return nil
+	}
+
+	e := newEnv(ctx, protocol.OpPut, dm.name, cmd.key, cmd.value, nilTimeout, 0, partitions.PRIMARY)
+	e.hkey = cmd.hkey
+	e.fragment = f
+	return dm.putOnLockedFragment(e)
+}
+
+func (dm *DMap) lockTransactionKeys(tx *protocol.Transaction) func() {
+	// Incr and GetPut use the same fine grained locks. Acquire them in the same
+	// order to prevent deadlocks between the concurrent transactions.
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, c := range tx.Commands {
+		if _, ok := seen[c.Key]; ok {
+			continue
+		}
+		seen[c.Key] = struct{}{}
+		keys = append(keys, c.Key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		dm.s.locker.Lock(dm.name + key)
+	}
+	return func() {
+		for _, key := range keys {
+			err := dm.s.locker.Unlock(dm.name + key)
+			if err != nil {
+				dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dm.name, err)
+			}
+		}
+	}
+}
+
+func (dm *DMap) execOnCluster(ctx context.Context, hkey uint64, tx *protocol.Transaction) ([]protocol.BatchResult, error) {
+	unlock := dm.lockTransactionKeys(tx)
+	defer unlock()
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	for _, w := range tx.Watches {
+		current, err := versionOnFragment(f, dm.hkey(w.Key))
+		if err != nil {
+			return nil, err
+		}
+		if current != w.Version {
+			return nil, ErrTransactionConflict
+		}
+	}
+
+	commands, results, err := dm.prepareTransaction(f, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err = dm.applyTransaction(ctx, f, commands); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (dm *DMap) exec(ctx context.Context, tx *protocol.Transaction) ([]protocol.BatchResult, error) {
+	hkey, err := dm.txHKey(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		// We are on the partition owner.
+		return dm.execOnCluster(ctx, hkey, tx)
+	}
+
+	// Redirect to the partition owner.
+	defer func() {
+		for _, cmd := range tx.Commands {
+			dm.invalidateNearCache(cmd.Key)
+		}
+	}()
+	value, err := msgpack.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+	req := protocol.NewDMapMessage(protocol.OpExec)
+	req.SetDMap(dm.name)
+	req.SetValue(value)
+	resp, err := dm.s.requestTo(ctx, member.String(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []protocol.BatchResult
+	err = msgpack.Unmarshal(resp.Value(), &results)
+	return results, err
+}
+
+// Exec runs the commands of the transaction atomically on the partition owner,
+// if none of the watched keys has been modified since they were watched. It
+// returns ErrTransactionConflict otherwise. All keys of a transaction have to
+// be in the same partition. The results are returned in the order of the
+// commands, Value is the serialized new value for OpIncr. It's thread-safe.
+func (dm *DMap) Exec(tx *protocol.Transaction) ([]protocol.BatchResult, error) {
+	return dm.ExecContext(context.Background(), tx)
+}
+
+// ExecContext is like Exec but the given context cancels the network operations.
+func (dm *DMap) ExecContext(ctx context.Context, tx *protocol.Transaction) ([]protocol.BatchResult, error) {
+	return dm.exec(ctx, tx)
+}
@@ -0,0 +1,257 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTxCommand(t *testing.T, s *Service, op protocol.OpCode, key string, value interface{}) protocol.TxCommand {
+	cmd := protocol.TxCommand{Op: op, Key: key}
+	if value != nil {
+		data, err := s.serializer.Marshal(value)
+		require.NoError(t, err)
+		cmd.Value = data
+	}
+	return cmd
+}
+
+func TestDMap_Exec(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	c1.DMaps.Custom = map[string]config.DMap{"mymap": {HashTags: true}}
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	c2.DMaps.Custom = map[string]config.DMap{"mymap": {HashTags: true}}
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm1.Put("{account}:balance", 10)
+	require.NoError(t, err)
+	err = dm1.Put("{account}:obsolete", "value")
+	require.NoError(t, err)
+
+	entry, err := dm1.GetEntry("{account}:balance")
+	require.NoError(t, err)
+
+	tx := &protocol.Transaction{
+		Watches: []protocol.TxWatch{
+			{Key: "{account}:balance", Version: entry.Version},
+			{Key: "{account}:log", Version: 0},
+		},
+		Commands: []protocol.TxCommand{
+			newTxCommand(t, s1, protocol.OpIncr, "{account}:balance", 5),
+			newTxCommand(t, s1, protocol.OpIncr, "{account}:balance", 5),
+			newTxCommand(t, s1, protocol.OpPut, "{account}:log", "deposit"),
+			newTxCommand(t, s1, protocol.OpDelete, "{account}:obsolete", nil),
+		},
+	}
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+	results, err := dm2.Exec(tx)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	var latest interface{}
+	err = s2.serializer.Unmarshal(results[1].Value, &latest)
+	require.NoError(t, err)
+	require.Equal(t, 20, latest)
+
+	value, err := dm1.Get("{account}:balance")
+	require.NoError(t, err)
+	require.Equal(t, 20, value)
+
+	value, err = dm1.Get("{account}:log")
+	require.NoError(t, err)
+	require.Equal(t, "deposit", value)
+
+	_, err = dm1.Get("{account}:obsolete")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Exec_Conflict(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.HashTags = true
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("{tx}:a", "value-1")
+	require.NoError(t, err)
+
+	entry, err := dm.GetEntry("{tx}:a")
+	require.NoError(t, err)
+
+	// Modify the watched key before committing the transaction.
+	err = dm.Put("{tx}:a", "value-2")
+	require.NoError(t, err)
+
+	tx := &protocol.Transaction{
+		Watches: []protocol.TxWatch{{Key: "{tx}:a", Version: entry.Version}},
+		Commands: []protocol.TxCommand{
+			newTxCommand(t, s, protocol.OpPut, "{tx}:a", "value-3"),
+			newTxCommand(t, s, protocol.OpPut, "{tx}:b", "value-3"),
+		},
+	}
+	_, err = dm.Exec(tx)
+	require.ErrorIs(t, err, ErrTransactionConflict)
+
+	value, err := dm.Get("{tx}:a")
+	require.NoError(t, err)
+	require.Equal(t, "value-2", value)
+
+	_, err = dm.Get("{tx}:b")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Exec_Abort(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.HashTags = true
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("{tx}:string", "value")
+	require.NoError(t, err)
+
+	// Incr fails on a string, nothing is written.
+	tx := &protocol.Transaction{
+		Commands: []protocol.TxCommand{
+			newTxCommand(t, s, protocol.OpPut, "{tx}:a", "value"),
+			newTxCommand(t, s, protocol.OpIncr, "{tx}:string", 1),
+		},
+	}
+	_, err = dm.Exec(tx)
+	require.Error(t, err)
+
+	_, err = dm.Get("{tx}:a")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Exec_DifferentPartitions(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	var keys []string
+	partID := s.primary.PartitionIDByHKey(dm.hkey(testutil.ToKey(0)))
+	for i := 1; i < 100; i++ {
+		key := testutil.ToKey(i)
+		if s.primary.PartitionIDByHKey(dm.hkey(key)) != partID {
+			keys = append(keys, testutil.ToKey(0), key)
+			break
+		}
+	}
+	require.Len(t, keys, 2)
+
+	tx := &protocol.Transaction{
+		Commands: []protocol.TxCommand{
+			newTxCommand(t, s, protocol.OpPut, keys[0], "value"),
+			newTxCommand(t, s, protocol.OpPut, keys[1], "value"),
+		},
+	}
+	_, err = dm.Exec(tx)
+	require.Error(t, err)
+}
+
+type failingMapStore struct {
+	*testMapStore
+	failKey string
+}
+
+func (s *failingMapStore) Store(ctx context.Context, key string, value interface{}) error {
+	if key == s.failKey {
+		return errors.New("backing store is down")
+	}
+	return s.testMapStore.Store(ctx, key, value)
+}
+
+func TestDMap_Exec_Rollback(t *testing.T) {
+	ms := &failingMapStore{testMapStore: newTestMapStore(), failKey: "{tx}:c"}
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mymap": {HashTags: true, Store: ms},
+	}
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("{tx}:a", "a-1")
+	require.NoError(t, err)
+	err = dm.Put("{tx}:d", "d-1")
+	require.NoError(t, err)
+
+	// The last command fails after the previous ones have been applied.
+	tx := &protocol.Transaction{
+		Commands: []protocol.TxCommand{
+			newTxCommand(t, s, protocol.OpPut, "{tx}:a", "a-2"),
+			newTxCommand(t, s, protocol.OpPut, "{tx}:b", "b-1"),
+			newTxCommand(t, s, protocol.OpDelete, "{tx}:d", nil),
+			newTxCommand(t, s, protocol.OpPut, "{tx}:c", "c-1"),
+		},
+	}
+	_, err = dm.Exec(tx)
+	require.Error(t, err)
+
+	value, err := dm.Get("{tx}:a")
+	require.NoError(t, err)
+	require.Equal(t, "a-1", value)
+
+	_, err = dm.Get("{tx}:b")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	value, err = dm.Get("{tx}:d")
+	require.NoError(t, err)
+	require.Equal(t, "d-1", value)
+
+	_, err = dm.Get("{tx}:c")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	value, ok := ms.get("{tx}:a")
+	require.True(t, ok)
+	require.Equal(t, "a-1", value)
+	_, ok = ms.get("{tx}:b")
+	require.False(t, ok)
+	value, ok = ms.get("{tx}:d")
+	require.True(t, ok)
+	require.Equal(t, "d-1", value)
+}
@@ -0,0 +1,215 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack"
+)
+
+// liveTTL returns the TTL of the key on the fragment. It returns ErrKeyNotFound,
+// if the key doesn't exist, it's deleted, expired or idle. It's not thread-safe.
+func (dm *DMap) liveTTL(f *fragment, hkey uint64) (int64, error) {
+	ttl, err := f.storage.GetTTL(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if isKeyExpired(ttl) || dm.isKeyIdleOnFragment(hkey, f) {
+		return 0, ErrKeyNotFound
+	}
+	if ttl == 0 {
+		// Tombstones have no TTL.
+		entry, err := f.storage.Get(hkey)
+		if err != nil {
+			return 0, err
+		}
+		if isTombstone(entry) {
+			return 0, ErrKeyNotFound
+		}
+	}
+	return ttl, nil
+}
+
+// remainingTTL converts a TTL in milliseconds to the remaining time. It's zero,
+// if the key has no expiry.
+func remainingTTL(ttl int64) time.Duration {
+	if ttl == 0 {
+		return 0
+	}
+	remaining := time.Duration(ttl)*time.Millisecond - time.Duration(time.Now().UnixNano())
+	if remaining <= 0 {
+		// isKeyExpired uses millisecond precision.
+		return time.Nanosecond
+	}
+	return remaining
+}
+
+// ttlOnCluster returns the remaining TTL of the key. It's called on the partition owner.
+func (dm *DMap) ttlOnCluster(ctx context.Context, hkey uint64, key string) (time.Duration, error) {
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if err == nil {
+		f.RLock()
+		var ttl int64
+		ttl, err = dm.liveTTL(f, hkey)
+		f.RUnlock()
+		if err == nil {
+			return remainingTTL(ttl), nil
+		}
+	}
+	if err != nil && !errors.Is(err, ErrKeyNotFound) && !errors.Is(err, errFragmentNotFound) {
+		return 0, err
+	}
+
+	if len(dm.s.primary.PartitionOwnersByHKey(hkey)) == 1 {
+		return 0, ErrKeyNotFound
+	}
+	// The key may be on a previous owner during rebalancing.
+	entry, err := dm.getOnCluster(ctx, hkey, key)
+	if err != nil {
+		return 0, err
+	}
+	return remainingTTL(entry.TTL()), nil
+}
+
+func (dm *DMap) ttl(ctx context.Context, key string) (time.Duration, error) {
+	hkey := dm.hkey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.ttlOnCluster(ctx, hkey, key)
+	}
+
+	// Redirect to the partition owner
+	req := protocol.NewDMapMessage(protocol.OpTTL)
+	req.SetDMap(dm.name)
+	req.SetKey(key)
+	resp, err := dm.s.requestTo(ctx, member.String(), req)
+	if err != nil {
+		return 0, err
+	}
+	var ttl int64
+	err = msgpack.Unmarshal(resp.Value(), &ttl)
+	return time.Duration(ttl), err
+}
+
+// getEx gets the entry and updates its expiry on the partition owner.
+func (dm *DMap) getEx(ctx context.Context, key string, timeout time.Duration) (storage.Entry, error) {
+	hkey := dm.hkey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if !member.CompareByName(dm.s.rt.This()) {
+		// Redirect to the partition owner
+		defer dm.invalidateNearCache(key)
+		req := protocol.NewDMapMessage(protocol.OpGetEx)
+		req.SetDMap(dm.name)
+		req.SetKey(key)
+		req.SetExtra(protocol.GetExExtra{
+			TTL: timeout.Nanoseconds(),
+		})
+		resp, err := dm.s.requestTo(ctx, member.String(), req)
+		if err != nil {
+			return nil, err
+		}
+		entry := dm.engine.NewEntry()
+		entry.Decode(resp.Value())
+		return entry, nil
+	}
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the entry and update its expiry under the same lock, so a concurrent
+	// write cannot slip between them.
+	f.Lock()
+	defer f.Unlock()
+
+	if _, err = dm.liveTTL(f, hkey); err != nil {
+		return nil, err
+	}
+	entry, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &env{
+		ctx:      ctx,
+		dmap:     dm.name,
+		key:      key,
+		hkey:     hkey,
+		timeout:  timeout,
+		fragment: f,
+	}
+	if err = dm.expireOnLockedFragment(e); err != nil {
+		return nil, err
+	}
+	entry.SetTTL(timeoutToTTL(timeout))
+	return entry, nil
+}
+
+// TTL returns the remaining time to live of the given key. It returns zero, if the
+// key has no expiry. It returns ErrKeyNotFound if the DB does not contain the key.
+// The value is not read.
+func (dm *DMap) TTL(key string) (time.Duration, error) {
+	return dm.TTLContext(context.Background(), key)
+}
+
+// TTLContext is like TTL but the given context cancels the network operations.
+func (dm *DMap) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	return dm.ttl(ctx, key)
+}
+
+// Persist removes the expiry of the given key. It returns ErrKeyNotFound if the DB
+// does not contain the key. It's thread-safe.
+func (dm *DMap) Persist(key string) error {
+	return dm.PersistContext(context.Background(), key)
+}
+
+// PersistContext is like Persist but the given context cancels the network operations.
+func (dm *DMap) PersistContext(ctx context.Context, key string) error {
+	// A zero timeout removes the expiry.
+	return dm.ExpireContext(ctx, key, 0)
+}
+
+// GetEx gets the value for the given key and updates its expiry in one round trip.
+// A zero timeout removes the expiry, like Persist. It returns ErrKeyNotFound if the
+// DB does not contain the key.
+func (dm *DMap) GetEx(key string, timeout time.Duration) (interface{}, error) {
+	return dm.GetExContext(context.Background(), key, timeout)
+}
+
+// GetExContext is like GetEx but the given context cancels the network operations.
+func (dm *DMap) GetExContext(ctx context.Context, key string, timeout time.Duration) (interface{}, error) {
+	entry, err := dm.getEx(ctx, key, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return dm.unmarshalValue(entry.Value())
+}
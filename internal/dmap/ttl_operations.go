@@ -0,0 +1,55 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/neterrors"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack"
+)
+
+func (s *Service) ttlOperation(w, r protocol.EncodeDecoder) {
+	req := r.(*protocol.DMapMessage)
+	dm, err := s.getOrCreateDMap(req.DMap())
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+
+	ttl, err := dm.ttl(context.Background(), req.Key())
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+	value, err := msgpack.Marshal(ttl.Nanoseconds())
+	if err != nil {
+		neterrors.ErrorResponse(w, err)
+		return
+	}
+	w.SetStatus(protocol.StatusOK)
+	w.SetValue(value)
+}
+
+func (s *Service) getExOperation(w, r protocol.EncodeDecoder) {
+	s.getOperationCommon(w, r, func(dm *DMap, r protocol.EncodeDecoder) (storage.Entry, error) {
+		req := r.(*protocol.DMapMessage)
+		ttl := req.Extra().(protocol.GetExExtra).TTL
+		return dm.getEx(context.Background(), req.Key(), time.Duration(ttl))
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_TTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.PutEx(testutil.ToKey(i), testutil.ToVal(i), time.Hour))
+		require.NoError(t, dm1.Put(testutil.ToKey(i+10), testutil.ToVal(i+10)))
+	}
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		for i := 0; i < 10; i++ {
+			ttl, err := dm.TTL(testutil.ToKey(i))
+			require.NoError(t, err)
+			require.True(t, ttl > 59*time.Minute && ttl <= time.Hour, "unexpected TTL: %v", ttl)
+
+			ttl, err = dm.TTL(testutil.ToKey(i + 10))
+			require.NoError(t, err)
+			require.Equal(t, time.Duration(0), ttl)
+		}
+	}
+
+	_, err = dm2.TTL("missing-key")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Tombstones have no TTL.
+	require.NoError(t, dm1.Delete(testutil.ToKey(10)))
+	_, err = dm2.TTL(testutil.ToKey(10))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Persist(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.PutEx(testutil.ToKey(i), testutil.ToVal(i), 50*time.Millisecond))
+		require.NoError(t, dm2.Persist(testutil.ToKey(i)))
+	}
+	require.NoError(t, dm1.PutEx("expired-key", "value", time.Millisecond))
+
+	<-time.After(100 * time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm1.Get(testutil.ToKey(i))
+		require.NoError(t, err)
+
+		ttl, err := dm1.TTL(testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, time.Duration(0), ttl)
+	}
+
+	// An expired key cannot be revived.
+	require.ErrorIs(t, dm2.Persist("expired-key"), ErrKeyNotFound)
+	require.ErrorIs(t, dm2.Persist("missing-key"), ErrKeyNotFound)
+}
+
+func TestDMap_GetEx(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.PutEx(testutil.ToKey(i), testutil.ToVal(i), time.Hour))
+
+		value, err := dm2.GetEx(testutil.ToKey(i), 50*time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+
+	// A zero timeout removes the expiry.
+	value, err := dm2.GetEx(testutil.ToKey(0), 0)
+	require.NoError(t, err)
+	require.Equal(t, testutil.ToVal(0), value)
+
+	<-time.After(100 * time.Millisecond)
+
+	_, err = dm1.Get(testutil.ToKey(0))
+	require.NoError(t, err)
+	for i := 1; i < 10; i++ {
+		_, err = dm1.Get(testutil.ToKey(i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+
+	_, err = dm2.GetEx("missing-key", time.Second)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// A deleted key cannot be revived.
+	require.NoError(t, dm1.Delete(testutil.ToKey(0)))
+	_, err = dm2.GetEx(testutil.ToKey(0), time.Second)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -0,0 +1,268 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+)
+
+// maxWriteBehindBackoff is the upper limit of the delay between the retries of
+// a failed batch, if WriteDelay is shorter than that.
+const maxWriteBehindBackoff = time.Minute
+
+// writeBehindEntry is a pending write on a key. A nil value deletes the key.
+type writeBehindEntry struct {
+	Key       string
+	Value     []byte
+	Timestamp int64
+}
+
+// writeBehindQueue keeps the pending writes of a primary fragment. It moves
+// along with the fragment during rebalancing. It's thread-safe.
+type writeBehindQueue struct {
+	mtx      sync.Mutex
+	entries  map[string]writeBehindEntry
+	failures uint
+	retryAt  time.Time
+}
+
+func newWriteBehindQueue() *writeBehindQueue {
+	return &writeBehindQueue{entries: make(map[string]writeBehindEntry)}
+}
+
+// addLocked coalesces the writes on the same key, the latest one wins.
+func (q *writeBehindQueue) addLocked(entry writeBehindEntry) {
+	current, ok := q.entries[entry.Key]
+	if ok && current.Timestamp > entry.Timestamp {
+		return
+	}
+	q.entries[entry.Key] = entry
+}
+
+func (q *writeBehindQueue) add(entries ...writeBehindEntry) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for _, entry := range entries {
+		q.addLocked(entry)
+	}
+}
+
+// get returns the pending write on the given key, if there is any.
+func (q *writeBehindQueue) get(key string) (writeBehindEntry, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	entry, ok := q.entries[key]
+	return entry, ok
+}
+
+func (q *writeBehindQueue) length() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return len(q.entries)
+}
+
+// snapshot returns a copy of the pending writes.
+func (q *writeBehindQueue) snapshot() []writeBehindEntry {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	entries := make([]writeBehindEntry, 0, len(q.entries))
+	for _, entry := range q.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// remove removes the given writes, if they have not been overwritten in the meantime.
+func (q *writeBehindQueue) remove(entries []writeBehindEntry) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for _, entry := range entries {
+		current, ok := q.entries[entry.Key]
+		if ok && current.Timestamp == entry.Timestamp {
+			delete(q.entries, entry.Key)
+		}
+	}
+}
+
+// take removes and returns at most n writes. It returns nil during the backoff
+// period of a failed batch, unless force is true.
+func (q *writeBehindQueue) take(n int, force bool) []writeBehindEntry {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if !force && time.Now().Before(q.retryAt) {
+		return nil
+	}
+
+	var entries []writeBehindEntry
+	for key, entry := range q.entries {
+		if len(entries) >= n {
+			break
+		}
+		entries = append(entries, entry)
+		delete(q.entries, key)
+	}
+	return entries
+}
+
+// retry puts a failed batch back and delays the next attempt exponentially.
+func (q *writeBehindQueue) retry(entries []writeBehindEntry, delay time.Duration) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for _, entry := range entries {
+		q.addLocked(entry)
+	}
+
+	backoff := delay << q.failures
+	if backoff < maxWriteBehindBackoff {
+		q.failures++
+	} else {
+		backoff = maxWriteBehindBackoff
+	}
+	if backoff < delay {
+		backoff = delay
+	}
+	q.retryAt = time.Now().Add(backoff)
+}
+
+func (q *writeBehindQueue) reset() {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.failures = 0
+	q.retryAt = time.Time{}
+}
+
+func (dm *DMap) isWriteBehindEnabled() bool {
+	return dm.config.store != nil && dm.config.writeDelay > 0
+}
+
+// storeBatch flushes a batch of pending writes to the MapStore.
+func (dm *DMap) storeBatch(ctx context.Context, batch []writeBehindEntry) error {
+	values := make(map[string]interface{})
+	var deleted []string
+	for _, entry := range batch {
+		if entry.Value == nil {
+			deleted = append(deleted, entry.Key)
+			continue
+		}
+		value, err := dm.unmarshalValue(entry.Value)
+		if err != nil {
+			return err
+		}
+		values[entry.Key] = value
+	}
+
+	if bs, ok := dm.config.store.(config.BatchMapStore); ok {
+		if len(values) > 0 {
+			if err := bs.StoreAll(ctx, values); err != nil {
+				return err
+			}
+		}
+		if len(deleted) > 0 {
+			return bs.DeleteAll(ctx, deleted)
+		}
+		return nil
+	}
+
+	for key, value := range values {
+		if err := dm.config.store.Store(ctx, key, value); err != nil {
+			return err
+		}
+	}
+	for _, key := range deleted {
+		if err := dm.config.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dm *DMap) flushWriteBehindQueue(ctx context.Context, part *partitions.Partition, q *writeBehindQueue, force bool) {
+	for {
+		batch := q.take(dm.config.writeBatchSize, force)
+		if len(batch) == 0 {
+			return
+		}
+		if err := dm.storeBatch(ctx, batch); err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to flush %d pending write(s) on DMap: %s: %v", len(batch), dm.name, err)
+			dm.retryWriteBehindBatch(part, q, batch)
+			return
+		}
+		q.reset()
+	}
+}
+
+// retryWriteBehindBatch puts a failed batch back into the current queue of the
+// partition. The fragment may have been replaced while the batch was in flight,
+// the queue of a detached fragment is never flushed again.
+func (dm *DMap) retryWriteBehindBatch(part *partitions.Partition, q *writeBehindQueue, batch []writeBehindEntry) {
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to load DMap fragment: %s on PartID: %d: %v", dm.name, part.ID(), err)
+	} else if f.writeBehind != nil {
+		q = f.writeBehind
+	}
+	q.retry(batch, dm.config.writeDelay)
+}
+
+// flushWriteBehind flushes the pending writes of the primary fragments on this node.
+func (dm *DMap) flushWriteBehind(ctx context.Context, force bool) {
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		part := dm.s.primary.PartitionByID(partID)
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to load DMap fragment: %s on PartID: %d: %v", dm.name, partID, err)
+			continue
+		}
+		if f.writeBehind == nil {
+			continue
+		}
+		dm.flushWriteBehindQueue(ctx, part, f.writeBehind, force)
+	}
+}
+
+func (dm *DMap) writeBehindWorker() {
+	defer dm.s.wg.Done()
+
+	ticker := time.NewTicker(dm.config.writeDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			dm.flushWriteBehind(dm.s.ctx, false)
+		case <-dm.s.ctx.Done():
+			// The last attempt to persist the pending writes before leaving.
+			dm.flushWriteBehind(context.Background(), true)
+			return
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newWriteBehindConfig(ms *testMapStore, delay time.Duration) *config.Config {
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mymap": {Store: ms, WriteDelay: delay},
+	}
+	return c
+}
+
+func pendingWrites(t *testing.T, s *Service) map[string]writeBehindEntry {
+	dm, err := s.getDMap("mymap")
+	require.NoError(t, err)
+
+	pending := make(map[string]writeBehindEntry)
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		f, err := dm.loadFragment(s.primary.PartitionByID(partID))
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		require.NoError(t, err)
+		for _, entry := range f.writeBehind.snapshot() {
+			pending[entry.Key] = entry
+		}
+	}
+	return pending
+}
+
+func TestDMap_WriteBehind(t *testing.T) {
+	ms := newTestMapStore()
+	ms.m["obsolete"] = "value"
+
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(newWriteBehindConfig(ms, 100*time.Millisecond))).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put("mykey", i)
+		require.NoError(t, err)
+	}
+	err = dm.Delete("obsolete")
+	require.NoError(t, err)
+
+	// The writes are acknowledged before they are persisted.
+	_, ok := ms.get("mykey")
+	require.False(t, ok)
+
+	require.Eventually(t, func() bool {
+		value, ok := ms.get("mykey")
+		_, found := ms.get("obsolete")
+		return ok && value == 9 && !found
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The repeated writes have been coalesced.
+	require.Equal(t, int32(1), atomic.LoadInt32(&ms.stores))
+}
+
+func TestDMap_WriteBehind_Retry(t *testing.T) {
+	ms := newTestMapStore()
+	ms.setFail(true)
+
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(newWriteBehindConfig(ms, 50*time.Millisecond))).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	err = dm.Put("mykey", "myvalue")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&ms.stores) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, pendingWrites(t, s), 1)
+
+	ms.setFail(false)
+	require.Eventually(t, func() bool {
+		value, ok := ms.get("mykey")
+		return ok && value == "myvalue"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, pendingWrites(t, s), 0)
+}
+
+func TestDMap_WriteBehind_Move(t *testing.T) {
+	ms := newTestMapStore()
+
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(testcluster.NewEnvironment(newWriteBehindConfig(ms, time.Hour))).(*Service)
+
+	dm, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = dm.Put(testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+	require.Len(t, pendingWrites(t, s1), 100)
+
+	// The balancer moves the pending writes along with the partitions.
+	s2 := cluster.AddMember(testcluster.NewEnvironment(newWriteBehindConfig(ms, time.Hour))).(*Service)
+
+	p1 := pendingWrites(t, s1)
+	p2 := pendingWrites(t, s2)
+	require.NotEmpty(t, p2)
+	require.Equal(t, 100, len(p1)+len(p2))
+	for key := range p2 {
+		_, ok := p1[key]
+		require.False(t, ok)
+
+		owner := s2.primary.PartitionByHKey(dm.hkey(key)).Owner()
+		require.True(t, owner.CompareByName(s2.rt.This()))
+	}
+
+	// The pending writes are flushed before leaving.
+	cluster.Shutdown()
+	for i := 0; i < 100; i++ {
+		value, ok := ms.get(testutil.ToKey(i))
+		require.True(t, ok)
+		require.Equal(t, i, value)
+	}
+}
+
+func TestDMap_WriteBehind_Pending_Delete(t *testing.T) {
+	ms := newTestMapStore()
+	ms.m["mykey"] = "old"
+
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mymap": {Loader: ms, Store: ms, WriteDelay: time.Hour},
+	}
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	value, err := dm.Get("mykey")
+	require.NoError(t, err)
+	require.Equal(t, "old", value)
+
+	// The delete is not flushed yet, the loader must not revive the key.
+	require.NoError(t, dm.Delete("mykey"))
+	_, err = dm.Get("mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, int32(1), atomic.LoadInt32(&ms.loads))
+}
+
+func TestDMap_WriteBehind_Retry_Replaced_Fragment(t *testing.T) {
+	ms := newTestMapStore()
+
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(newWriteBehindConfig(ms, time.Hour))).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+	require.NoError(t, dm.Put("mykey", "myvalue"))
+
+	part := dm.getPartitionByHKey(dm.hkey("mykey"), partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	require.NoError(t, err)
+	batch := f.writeBehind.take(dm.config.writeBatchSize, true)
+	require.Len(t, batch, 1)
+
+	// The fragment is replaced while the batch is in flight.
+	part.Map().Delete(dm.fragmentName)
+	dm.retryWriteBehindBatch(part, f.writeBehind, batch)
+
+	pending := pendingWrites(t, s)
+	require.Len(t, pending, 1)
+	require.Contains(t, pending, "mykey")
+}
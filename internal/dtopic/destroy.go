@@ -15,6 +15,7 @@
 package dtopic
 
 import (
+	"context"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/neterrors"
@@ -40,7 +41,7 @@ func (s *Service) destroyDTopicOnCluster(topic string) error {
 			}
 			req := protocol.NewDTopicMessage(protocol.OpDestroyDTopicInternal)
 			req.SetDTopic(topic)
-			_, err := s.requestTo(member.String(), req)
+			_, err := s.requestTo(context.Background(), member.String(), req)
 			if err != nil {
 				s.log.V(2).Printf("[ERROR] Failed to call Destroy on %s, topic: %s : %v", member, topic, err)
 				return err
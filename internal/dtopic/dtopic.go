@@ -15,9 +15,9 @@
 package dtopic
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/neterrors"
@@ -112,12 +112,12 @@ func (s *Service) NewDTopic(name string, concurrency int, flag int16) (*DTopic,
 
 // Publish publishes the given message to listeners of the topic. Message order and delivery are not guaranteed.
 func (d *DTopic) Publish(msg interface{}) error {
-	tm := &Message{
-		Message:       msg,
-		PublisherAddr: d.s.rt.This().String(),
-		PublishedAt:   time.Now().UnixNano(),
-	}
-	return d.s.publishDTopicMessage(d.name, tm)
+	return d.PublishContext(context.Background(), msg)
+}
+
+// PublishContext is like Publish but the given context cancels the network operations.
+func (d *DTopic) PublishContext(ctx context.Context, msg interface{}) error {
+	return d.s.Publish(ctx, d.name, msg)
 }
 
 // AddListener adds a new listener for the topic. Returns a registration ID or a non-nil error.
@@ -15,6 +15,7 @@
 package dtopic
 
 import (
+	"context"
 	"errors"
 	"runtime"
 	"time"
@@ -44,7 +45,7 @@ func (s *Service) publishMessageOperation(w, r protocol.EncodeDecoder) {
 	w.SetStatus(protocol.StatusOK)
 }
 
-func (s *Service) publishDTopicMessageToAddr(member discovery.Member, topic string, msg *Message, sem *semaphore.Weighted) error {
+func (s *Service) publishDTopicMessageToAddr(ctx context.Context, member discovery.Member, topic string, msg *Message, sem *semaphore.Weighted) error {
 	defer sem.Release(1)
 
 	if member.CompareByID(s.rt.This()) {
@@ -68,7 +69,7 @@ func (s *Service) publishDTopicMessageToAddr(member discovery.Member, topic stri
 	req := protocol.NewDTopicMessage(protocol.OpPublishDTopicMessage)
 	req.SetDTopic(topic)
 	req.SetValue(data)
-	_, err = s.requestTo(member.String(), req)
+	_, err = s.requestTo(ctx, member.String(), req)
 	if err != nil {
 		s.log.V(2).Printf("[ERROR] Failed to publish message to %s: %v", member, err)
 		return err
@@ -76,7 +77,7 @@ func (s *Service) publishDTopicMessageToAddr(member discovery.Member, topic stri
 	return nil
 }
 
-func (s *Service) publishDTopicMessage(topic string, msg *Message) error {
+func (s *Service) publishDTopicMessage(ctx context.Context, topic string, msg *Message) error {
 	s.rt.Members().RLock()
 	defer s.rt.Members().RUnlock()
 
@@ -100,7 +101,7 @@ func (s *Service) publishDTopicMessage(topic string, msg *Message) error {
 				s.log.V(3).Printf("[ERROR] Failed to acquire semaphore: %v", err)
 				return err
 			}
-			return s.publishDTopicMessageToAddr(member, topic, msg, sem)
+			return s.publishDTopicMessageToAddr(ctx, member, topic, msg, sem)
 		})
 		return true
 	})
@@ -113,6 +114,25 @@ func (s *Service) publishDTopicMessage(topic string, msg *Message) error {
 	return g.Wait()
 }
 
+// Publish publishes a message to the given topic. It's used by the other services
+// to publish messages without creating a DTopic.
+func (s *Service) Publish(ctx context.Context, topic string, msg interface{}) error {
+	tm := &Message{
+		Message:       msg,
+		PublisherAddr: s.rt.This().String(),
+		PublishedAt:   time.Now().UnixNano(),
+	}
+	return s.publishDTopicMessage(ctx, topic, tm)
+}
+
+// Subscribe adds a listener to the given topic on this node. It's used by the
+// other services to receive messages without creating a DTopic.
+func (s *Service) Subscribe(topic string, f func(msg interface{})) (uint64, error) {
+	return s.dispatcher.addListener(topic, 0, func(m Message) {
+		f(m.Message)
+	})
+}
+
 func (s *Service) exPublishOperation(w, r protocol.EncodeDecoder) {
 	req := r.(*protocol.DTopicMessage)
 	msg, err := s.unmarshalValue(req.Value())
@@ -125,7 +145,7 @@ func (s *Service) exPublishOperation(w, r protocol.EncodeDecoder) {
 		PublisherAddr: "",
 		PublishedAt:   time.Now().UnixNano(),
 	}
-	err = s.publishDTopicMessage(req.DTopic(), tm)
+	err = s.publishDTopicMessage(context.Background(), req.DTopic(), tm)
 	if err != nil {
 		neterrors.ErrorResponse(w, err)
 		return
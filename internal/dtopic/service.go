@@ -90,8 +90,8 @@ func (s *Service) unmarshalValue(raw []byte) (interface{}, error) {
 	return value, nil
 }
 
-func (s *Service) requestTo(addr string, req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
-	resp, err := s.client.RequestTo(addr, req)
+func (s *Service) requestTo(ctx context.Context, addr string, req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
+	resp, err := s.client.RequestToContext(ctx, addr, req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,83 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hlc implements a hybrid logical clock.
+//
+// A timestamp is an int64 in the same domain as time.Now().UnixNano(). The clock
+// follows the wall clock while it moves forward. If the wall clock lags behind the
+// latest timestamp that is generated or received, the clock increments the latest
+// timestamp by one. So the timestamps of causally related events are always in
+// order, even if the wall clocks of the members are skewed.
+package hlc
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a hybrid logical clock. It's thread-safe.
+type Clock struct {
+	mu   sync.Mutex
+	last int64
+	wall func() int64
+}
+
+// New returns a new Clock.
+func New() *Clock {
+	return &Clock{
+		wall: func() int64 {
+			return time.Now().UnixNano()
+		},
+	}
+}
+
+// tick is not thread-safe.
+func (c *Clock) tick() int64 {
+	pt := c.wall()
+	if pt > c.last {
+		c.last = pt
+	} else {
+		c.last++
+	}
+	return c.last
+}
+
+// Now returns a timestamp for a local event. It's always greater than the
+// previous timestamps.
+func (c *Clock) Now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.tick()
+}
+
+// Update merges a timestamp received from another member into the clock and
+// returns a timestamp that is greater than both of them.
+func (c *Clock) Update(remote int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if remote > c.last {
+		c.last = remote
+	}
+	return c.tick()
+}
+
+// Last returns the latest timestamp without advancing the clock.
+func (c *Clock) Last() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.last
+}
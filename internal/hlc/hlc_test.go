@@ -0,0 +1,90 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hlc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock_Now(t *testing.T) {
+	c := New()
+	start := time.Now().UnixNano()
+
+	var last int64
+	for i := 0; i < 1000; i++ {
+		ts := c.Now()
+		require.Greater(t, ts, last)
+		last = ts
+	}
+	require.GreaterOrEqual(t, last, start)
+}
+
+func TestClock_Now_WallClockGoesBack(t *testing.T) {
+	c := New()
+	wall := int64(1000)
+	c.wall = func() int64 { return wall }
+
+	require.Equal(t, int64(1000), c.Now())
+
+	wall = 500
+	require.Equal(t, int64(1001), c.Now())
+	require.Equal(t, int64(1002), c.Now())
+
+	wall = 2000
+	require.Equal(t, int64(2000), c.Now())
+}
+
+func TestClock_Update(t *testing.T) {
+	c := New()
+	wall := int64(1000)
+	c.wall = func() int64 { return wall }
+
+	// A member with a fast clock
+	require.Equal(t, int64(5001), c.Update(5000))
+	require.Equal(t, int64(5002), c.Now())
+
+	// An older timestamp doesn't move the clock back.
+	require.Equal(t, int64(5003), c.Update(10))
+	require.Equal(t, int64(5003), c.Last())
+
+	wall = 6000
+	require.Equal(t, int64(6000), c.Update(5500))
+}
+
+func TestClock_Concurrent(t *testing.T) {
+	c := New()
+
+	var mu sync.Mutex
+	seen := make(map[int64]struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ts := c.Now()
+				mu.Lock()
+				seen[ts] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, seen, 1000)
+}
@@ -37,11 +37,17 @@ const (
 
 // KVStore implements an in-memory storage engine.
 type KVStore struct {
-	tables []*table.Table
-	config *storage.Config
+	tables        []*table.Table
+	config        *storage.Config
+	accessCounter bool
+	ordered       orderedIndex
 }
 
-var _ storage.Engine = (*KVStore)(nil)
+var (
+	_ storage.Engine            = (*KVStore)(nil)
+	_ storage.LastAccessUpdater = (*KVStore)(nil)
+	_ storage.OrderedRanger     = (*KVStore)(nil)
+)
 
 func DefaultConfig() *storage.Config {
 	options := storage.NewConfig(nil)
@@ -60,8 +66,10 @@ func (k *KVStore) makeTable() error {
 		return err
 	}
 
-	head := k.tables[len(k.tables)-1]
-	head.SetState(table.ReadOnlyState)
+	if len(k.tables) != 0 {
+		head := k.tables[len(k.tables)-1]
+		head.SetState(table.ReadOnlyState)
+	}
 
 	for i, t := range k.tables {
 		if t.State() == table.RecycledState {
@@ -72,11 +80,18 @@ func (k *KVStore) makeTable() error {
 		}
 	}
 
-	current := table.New(size.(uint32))
-	k.tables = append(k.tables, current)
+	k.tables = append(k.tables, k.newTable(size.(uint32)))
 	return nil
 }
 
+func (k *KVStore) newTable(size uint32) *table.Table {
+	t := table.New(size)
+	if k.accessCounter {
+		t.EnableAccessCounter()
+	}
+	return t
+}
+
 func (k *KVStore) SetLogger(_ *log.Logger) {}
 
 func (k *KVStore) Start() error {
@@ -98,12 +113,20 @@ func (k *KVStore) Fork(c *storage.Config) (storage.Engine, error) {
 	child := &KVStore{
 		config: c,
 	}
-	t := table.New(size.(uint32))
-	child.tables = append(child.tables, t)
+	if raw, err := c.Get("accessCounter"); err == nil {
+		child.accessCounter, _ = raw.(bool)
+	}
+	child.tables = append(child.tables, child.newTable(size.(uint32)))
 	return child, nil
 }
 
 func (k *KVStore) AppendTable(t *table.Table) {
+	if k.accessCounter {
+		t.EnableAccessCounter()
+	}
+	k.ordered.mtx.Lock()
+	k.ordered.stale = true
+	k.ordered.mtx.Unlock()
 	k.tables = append(k.tables, t)
 }
 
@@ -117,6 +140,7 @@ func (k *KVStore) NewEntry() storage.Entry {
 
 // PutRaw sets the raw value for the given key.
 func (k *KVStore) PutRaw(hkey uint64, value []byte) error {
+	k.markOrderedIndex(hkey)
 	if len(k.tables) == 0 {
 		if err := k.makeTable(); err != nil {
 			return err
@@ -142,11 +166,12 @@ func (k *KVStore) PutRaw(hkey uint64, value []byte) error {
 		break
 	}
 
-	return nil
+	return k.deleteStaleVersions(hkey)
 }
 
 // Put sets the value for the given key. It overwrites any previous value for that key
 func (k *KVStore) Put(hkey uint64, value storage.Entry) error {
+	k.markOrderedIndex(hkey)
 	if len(k.tables) == 0 {
 		if err := k.makeTable(); err != nil {
 			return err
@@ -173,6 +198,21 @@ func (k *KVStore) Put(hkey uint64, value storage.Entry) error {
 		break
 	}
 
+	return k.deleteStaleVersions(hkey)
+}
+
+// deleteStaleVersions removes the previous versions of the given key from the older
+// tables. The latest version is always stored in the last table.
+func (k *KVStore) deleteStaleVersions(hkey uint64) error {
+	for i := 0; i < len(k.tables)-1; i++ {
+		err := k.tables[i].Delete(hkey)
+		if errors.Is(err, table.ErrHKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -322,6 +362,23 @@ func (k *KVStore) UpdateTTL(hkey uint64, data storage.Entry) error {
 	return storage.ErrKeyNotFound
 }
 
+// UpdateLastAccess sets LastAccess of the given key. It's only useful if the
+// accessCounter option is set, the reads overwrite it otherwise.
+func (k *KVStore) UpdateLastAccess(hkey uint64, lastAccess int64) error {
+	// Scan available tables by starting the last added table.
+	for i := len(k.tables) - 1; i >= 0; i-- {
+		t := k.tables[i]
+		err := t.UpdateLastAccess(hkey, lastAccess)
+		if errors.Is(err, table.ErrHKeyNotFound) {
+			// Try out the other tables.
+			continue
+		}
+		return err
+	}
+	// Nothing here.
+	return storage.ErrKeyNotFound
+}
+
 // Stats is a function which provides memory allocation and garbage ratio of a storage instance.
 func (k *KVStore) Stats() storage.Stats {
 	stats := storage.Stats{
@@ -185,6 +185,35 @@ func TestKVStore_ExportImport(t *testing.T) {
 	}
 }
 
+func TestKVStore_Put_Overwrite(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("tableSize", uint32(100)) // Force the engine to create new tables.
+	s, err := testKVStore(c)
+	require.NoError(t, err)
+
+	hkey := xxhash.Sum64([]byte(bkey(1)))
+	for i := 0; i < 10; i++ {
+		e := entry.New()
+		e.SetKey(bkey(1))
+		e.SetValue(bval(i))
+		e.SetTimestamp(time.Now().UnixNano())
+		err = s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(s.(*KVStore).tables), 1)
+	require.Equal(t, 1, s.Stats().Length)
+
+	e, err := s.Get(hkey)
+	require.NoError(t, err)
+	require.Equal(t, bval(9), e.Value())
+
+	// Previous versions must not come back after deleting the key.
+	err = s.Delete(hkey)
+	require.NoError(t, err)
+	_, err = s.Get(hkey)
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+}
+
 func TestKVStore_Stats_Length(t *testing.T) {
 	s, err := testKVStore(nil)
 	require.NoError(t, err)
@@ -496,3 +525,46 @@ func TestKVStore_CloseDestroy(t *testing.T) {
 	require.NoError(t, s.Close())
 	require.NoError(t, s.Destroy())
 }
+
+func TestKVStore_RangeFrom(t *testing.T) {
+	s, err := testKVStore(nil)
+	require.NoError(t, err)
+	kv := s.(*KVStore)
+
+	put := func(i int) uint64 {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue(bval(i))
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		require.NoError(t, kv.Put(hkey, e))
+		return hkey
+	}
+	rangeFrom := func(from uint64) []uint64 {
+		var hkeys []uint64
+		kv.RangeFrom(from, func(hkey uint64, e storage.Entry) bool {
+			require.Equal(t, hkey, xxhash.Sum64([]byte(e.Key())))
+			hkeys = append(hkeys, hkey)
+			return true
+		})
+		return hkeys
+	}
+
+	for i := 0; i < 100; i++ {
+		put(i)
+	}
+	hkeys := rangeFrom(0)
+	require.Len(t, hkeys, 100)
+	for i := 1; i < len(hkeys); i++ {
+		require.Less(t, hkeys[i-1], hkeys[i])
+	}
+	require.Equal(t, hkeys[50:], rangeFrom(hkeys[50]))
+	require.Equal(t, hkeys[51:], rangeFrom(hkeys[50]+1))
+
+	// The new keys are added and the deleted keys are skipped.
+	require.NoError(t, kv.Delete(hkeys[50]))
+	hkey := put(100)
+	result := rangeFrom(0)
+	require.Len(t, result, 100)
+	require.Contains(t, result, hkey)
+	require.NotContains(t, result, hkeys[50])
+}
@@ -0,0 +1,81 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// orderedIndex keeps the HKeys in ascending order for RangeFrom. It's built by
+// the first RangeFrom call and rebuilt after a new key is added, so the writes
+// cost nothing until the paged queries are used. The deleted keys are skipped
+// until the next rebuild.
+type orderedIndex struct {
+	mtx   sync.Mutex
+	hkeys []uint64
+	stale bool
+}
+
+// markOrderedIndex marks the ordered index as stale, if the given HKey is a new key.
+// It has to be called before storing the key.
+func (k *KVStore) markOrderedIndex(hkey uint64) {
+	k.ordered.mtx.Lock()
+	defer k.ordered.mtx.Unlock()
+
+	if k.ordered.hkeys != nil && !k.ordered.stale && !k.Check(hkey) {
+		k.ordered.stale = true
+	}
+}
+
+func (k *KVStore) orderedHKeys() []uint64 {
+	k.ordered.mtx.Lock()
+	defer k.ordered.mtx.Unlock()
+
+	if k.ordered.hkeys != nil && !k.ordered.stale {
+		return k.ordered.hkeys
+	}
+
+	hkeys := make([]uint64, 0, k.Stats().Length)
+	for _, t := range k.tables {
+		t.RangeHKeys(func(hkey uint64) bool {
+			hkeys = append(hkeys, hkey)
+			return true
+		})
+	}
+	sort.Slice(hkeys, func(i, j int) bool { return hkeys[i] < hkeys[j] })
+	// The readers may still use the previous slice, never modify it in place.
+	k.ordered.hkeys = hkeys
+	k.ordered.stale = false
+	return hkeys
+}
+
+// RangeFrom calls f on the entries in ascending HKey order, starting from the given HKey.
+func (k *KVStore) RangeFrom(from uint64, f func(hkey uint64, e storage.Entry) bool) {
+	hkeys := k.orderedHKeys()
+	i := sort.Search(len(hkeys), func(i int) bool { return hkeys[i] >= from })
+	for ; i < len(hkeys); i++ {
+		e, err := k.Get(hkeys[i])
+		if err != nil {
+			// The key has been deleted after the index was built.
+			continue
+		}
+		if !f(hkeys[i], e) {
+			break
+		}
+	}
+}
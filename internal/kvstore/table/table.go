@@ -56,6 +56,10 @@ type Table struct {
 	state      State
 	hkeys      map[uint64]uint32
 	memory     []byte
+
+	// accessCounter means that LastAccess keeps an opaque access counter. It's
+	// only set by Put and UpdateLastAccess.
+	accessCounter bool
 }
 
 func New(size uint32) *Table {
@@ -84,6 +88,19 @@ func (t *Table) State() State {
 	return t.state
 }
 
+// EnableAccessCounter makes the table keep LastAccess of the entries as it's
+// given. The reads don't update it anymore.
+func (t *Table) EnableAccessCounter() {
+	t.accessCounter = true
+}
+
+func (t *Table) lastAccess(value storage.Entry) int64 {
+	if t.accessCounter {
+		return value.LastAccess()
+	}
+	return time.Now().UnixNano()
+}
+
 func (t *Table) PutRaw(hkey uint64, value []byte) error {
 	// Check empty space on allocated memory area.
 	inuse := uint32(len(value))
@@ -143,7 +160,7 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	t.offset += 8
 
 	// Set the last access. It's 8 bytes.
-	binary.BigEndian.PutUint64(t.memory[t.offset:], uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint64(t.memory[t.offset:], uint64(t.lastAccess(value)))
 	t.offset += 8
 
 	// Set the value length. It's 4 bytes.
@@ -253,8 +270,10 @@ func (t *Table) Get(hkey uint64) (storage.Entry, error) {
 	offset += 8
 
 	e.SetLastAccess(int64(binary.BigEndian.Uint64(t.memory[offset : offset+8])))
-	// Update the last access field
-	binary.BigEndian.PutUint64(t.memory[offset:], uint64(time.Now().UnixNano()))
+	if !t.accessCounter {
+		// Update the last access field
+		binary.BigEndian.PutUint64(t.memory[offset:], uint64(time.Now().UnixNano()))
+	}
 	offset += 8
 
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
@@ -320,9 +339,28 @@ func (t *Table) UpdateTTL(hkey uint64, value storage.Entry) error {
 
 	offset += 8
 
-	// Update the last access field
-	binary.BigEndian.PutUint64(t.memory[offset:], uint64(time.Now().UnixNano()))
+	if !t.accessCounter {
+		// Update the last access field
+		binary.BigEndian.PutUint64(t.memory[offset:], uint64(time.Now().UnixNano()))
+	}
+
+	return nil
+}
+
+// UpdateLastAccess sets LastAccess of an entry.
+func (t *Table) UpdateLastAccess(hkey uint64, lastAccess int64) error {
+	offset, ok := t.hkeys[hkey]
+	if !ok {
+		return ErrHKeyNotFound
+	}
+
+	klen := uint32(t.memory[offset])
+	offset++       // Key length
+	offset += klen // Key's itself
+	offset += 8    // TTL
+	offset += 8    // Timestamp
 
+	binary.BigEndian.PutUint64(t.memory[offset:], uint64(lastAccess))
 	return nil
 }
 
@@ -341,6 +379,15 @@ func (t *Table) Stats() Stats {
 	}
 }
 
+// RangeHKeys calls f on the HKeys in the table. It doesn't read the entries.
+func (t *Table) RangeHKeys(f func(hkey uint64) bool) {
+	for hkey := range t.hkeys {
+		if !f(hkey) {
+			break
+		}
+	}
+}
+
 func (t *Table) Range(f func(hkey uint64, e storage.Entry) bool) {
 	for hkey := range t.hkeys {
 		e, err := t.Get(hkey)
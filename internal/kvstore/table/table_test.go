@@ -203,6 +203,29 @@ func TestTable_UpdateTTL_Update_LastAccess(t *testing.T) {
 	require.Greater(t, lastAccessTwo, lastAccessOne)
 }
 
+func TestTable_UpdateLastAccess_AccessCounter(t *testing.T) {
+	tb, e := setupTable()
+	tb.EnableAccessCounter()
+	e.SetLastAccess(5)
+
+	err := tb.Put(hkey, e)
+	require.NoError(t, err)
+
+	err = tb.UpdateLastAccess(hkey, 6)
+	require.NoError(t, err)
+
+	// Reads don't update the access counter.
+	for i := 0; i < 2; i++ {
+		value, err := tb.Get(hkey)
+		require.NoError(t, err)
+		require.Equal(t, int64(6), value.LastAccess())
+		require.Equal(t, e.Value(), value.Value())
+	}
+
+	err = tb.UpdateLastAccess(hkey+1, 6)
+	require.ErrorIs(t, err, ErrHKeyNotFound)
+}
+
 func TestTable_State(t *testing.T) {
 	tb, _ := setupTable()
 	require.Equal(t, ReadWriteState, tb.State())
@@ -0,0 +1,189 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package nearcache implements a bounded local cache for the entries of a DMap. It's
+used by the members and the Golang client to cache the entries that are owned by
+the other members.
+*/
+package nearcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+)
+
+type item struct {
+	key      string
+	value    interface{}
+	size     int
+	expireAt int64
+}
+
+// Load tracks the concurrent fetches of a key. A key that's invalidated during
+// a fetch is not cached with the fetched value.
+type Load struct {
+	refs  int
+	stale bool
+}
+
+// NearCache is a bounded cache with TTL and LRU or FIFO eviction. It's thread-safe.
+type NearCache struct {
+	mtx sync.Mutex
+
+	config *config.NearCache
+	items  map[string]*list.Element
+	order  *list.List
+	loads  map[string]*Load
+	inuse  int
+}
+
+// New returns a new NearCache. The configuration has to be sanitized.
+func New(c *config.NearCache) *NearCache {
+	return &NearCache{
+		config: c,
+		items:  make(map[string]*list.Element),
+		order:  list.New(),
+		loads:  make(map[string]*Load),
+	}
+}
+
+// Get returns the cached value for the given key.
+func (n *NearCache) Get(key string) (interface{}, bool) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	elem, ok := n.items[key]
+	if !ok {
+		return nil, false
+	}
+	i := elem.Value.(*item)
+	if i.expireAt != 0 && i.expireAt <= time.Now().UnixNano() {
+		n.removeLocked(elem)
+		return nil, false
+	}
+	if n.config.EvictionPolicy == config.LRUEviction {
+		n.order.MoveToFront(elem)
+	}
+	return i.value, true
+}
+
+// Begin registers a fetch for the given key. The returned Load has to be passed
+// to Finish, even if the fetch fails.
+func (n *NearCache) Begin(key string) *Load {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	l, ok := n.loads[key]
+	if !ok {
+		l = &Load{}
+		n.loads[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// Finish caches the fetched value, if the key has not been invalidated since
+// Begin. value is nil, if the fetch has failed. size is the approximate size of
+// the value in bytes. expireAt is the expiry of the entry in nanoseconds, zero
+// means no expiry.
+func (n *NearCache) Finish(key string, l *Load, value interface{}, size int, expireAt int64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	l.refs--
+	if l.refs == 0 {
+		delete(n.loads, key)
+	}
+	if value == nil || l.stale {
+		return
+	}
+
+	if n.config.TTL > 0 {
+		ttl := time.Now().Add(n.config.TTL).UnixNano()
+		if expireAt == 0 || ttl < expireAt {
+			expireAt = ttl
+		}
+	}
+
+	size += len(key)
+	if n.config.MaxInuse > 0 && size > n.config.MaxInuse {
+		// It never fits.
+		return
+	}
+	if elem, ok := n.items[key]; ok {
+		n.removeLocked(elem)
+	}
+	for n.order.Len() > 0 && n.isFull(size) {
+		n.removeLocked(n.order.Back())
+	}
+	n.items[key] = n.order.PushFront(&item{
+		key:      key,
+		value:    value,
+		size:     size,
+		expireAt: expireAt,
+	})
+	n.inuse += size
+}
+
+func (n *NearCache) isFull(size int) bool {
+	if n.config.MaxKeys > 0 && n.order.Len() >= n.config.MaxKeys {
+		return true
+	}
+	return n.config.MaxInuse > 0 && n.inuse+size > n.config.MaxInuse
+}
+
+func (n *NearCache) removeLocked(elem *list.Element) {
+	i := elem.Value.(*item)
+	n.order.Remove(elem)
+	delete(n.items, i.key)
+	n.inuse -= i.size
+}
+
+// Invalidate removes the given key from the cache.
+func (n *NearCache) Invalidate(key string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if l, ok := n.loads[key]; ok {
+		l.stale = true
+	}
+	if elem, ok := n.items[key]; ok {
+		n.removeLocked(elem)
+	}
+}
+
+// Purge removes all the keys from the cache.
+func (n *NearCache) Purge() {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for _, l := range n.loads {
+		l.stale = true
+	}
+	n.items = make(map[string]*list.Element)
+	n.order.Init()
+	n.inuse = 0
+}
+
+// Len returns the number of keys in the cache.
+func (n *NearCache) Len() int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.order.Len()
+}
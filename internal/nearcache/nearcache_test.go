@@ -0,0 +1,113 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nearcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+)
+
+func newNearCache(t *testing.T, c *config.NearCache) *NearCache {
+	if err := c.Sanitize(); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	return New(c)
+}
+
+func (n *NearCache) set(key string, value []byte, expireAt int64) {
+	n.Finish(key, n.Begin(key), value, len(value), expireAt)
+}
+
+func TestNearCache_LRU(t *testing.T) {
+	n := newNearCache(t, &config.NearCache{
+		MaxKeys:        2,
+		EvictionPolicy: config.LRUEviction,
+	})
+	n.set("a", []byte("a"), 0)
+	n.set("b", []byte("b"), 0)
+	if _, ok := n.Get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+
+	// b is the least recently used key.
+	n.set("c", []byte("c"), 0)
+	if _, ok := n.Get("b"); ok {
+		t.Fatalf("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := n.Get(key); !ok {
+			t.Fatalf("Expected %s to be cached", key)
+		}
+	}
+}
+
+func TestNearCache_MaxInuse(t *testing.T) {
+	n := newNearCache(t, &config.NearCache{MaxInuse: 20})
+	n.set("a", make([]byte, 9), 0)
+	n.set("b", make([]byte, 9), 0)
+	// The oldest key is evicted.
+	n.set("c", make([]byte, 9), 0)
+	if n.Len() != 2 {
+		t.Fatalf("Expected 2 keys. Got: %d", n.Len())
+	}
+	if _, ok := n.Get("a"); ok {
+		t.Fatalf("Expected a to be evicted")
+	}
+
+	// It's larger than the cache.
+	n.set("d", make([]byte, 20), 0)
+	if _, ok := n.Get("d"); ok {
+		t.Fatalf("Expected d not to be cached")
+	}
+}
+
+func TestNearCache_TTL(t *testing.T) {
+	n := newNearCache(t, &config.NearCache{TTL: time.Millisecond})
+	n.set("a", []byte("a"), 0)
+	n.set("b", []byte("b"), time.Now().Add(-time.Millisecond).UnixNano())
+	if _, ok := n.Get("b"); ok {
+		t.Fatalf("Expected b to be expired")
+	}
+
+	<-time.After(5 * time.Millisecond)
+	if _, ok := n.Get("a"); ok {
+		t.Fatalf("Expected a to be expired")
+	}
+	if n.Len() != 0 {
+		t.Fatalf("Expected no keys. Got: %d", n.Len())
+	}
+}
+
+func TestNearCache_Invalidate_During_Load(t *testing.T) {
+	n := newNearCache(t, &config.NearCache{})
+	l := n.Begin("a")
+	n.Invalidate("a")
+	n.Finish("a", l, []byte("a"), 1, 0)
+	if _, ok := n.Get("a"); ok {
+		t.Fatalf("Expected a not to be cached")
+	}
+
+	l = n.Begin("b")
+	n.Purge()
+	n.Finish("b", l, []byte("b"), 1, 0)
+	if _, ok := n.Get("b"); ok {
+		t.Fatalf("Expected b not to be cached")
+	}
+	if len(n.loads) != 0 {
+		t.Fatalf("Expected no loads. Got: %d", len(n.loads))
+	}
+}
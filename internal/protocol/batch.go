@@ -0,0 +1,31 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+// BatchEntry is an item of an OpBatch request. The value of an OpBatch message
+// is a msgpack encoded list of BatchEntry. Value is only used by OpPut.
+type BatchEntry struct {
+	Key   string
+	Value []byte
+}
+
+// BatchResult is the result for a key in an OpBatch response. The value of the
+// response is a msgpack encoded list of BatchResult. Value is the encoded entry
+// for OpGet. It's the error message if Status is not StatusOK.
+type BatchResult struct {
+	Key    string
+	Status StatusCode
+	Value  []byte
+}
@@ -72,6 +72,28 @@ type ExpireExtra struct {
 	Timestamp int64
 }
 
+// GetExExtra defines extra values for this operation.
+type GetExExtra struct {
+	TTL int64
+}
+
+// DeleteExtra defines extra values for this operation.
+type DeleteExtra struct {
+	Timestamp int64
+}
+
+// CompareAndSwapExtra defines extra values for this operation.
+type CompareAndSwapExtra struct {
+	Version   int64
+	Timestamp int64
+}
+
+// BatchExtra defines extra values for this operation. Op is one of OpGet, OpPut
+// and OpDelete.
+type BatchExtra struct {
+	Op OpCode
+}
+
 // UpdateRoutingExtra defines extra values for this operation.
 type UpdateRoutingExtra struct {
 	CoordinatorID uint64
@@ -85,6 +107,35 @@ type QueryExtra struct {
 	PartID uint64
 }
 
+// QueryPageExtra defines extra values for this operation. The continuation
+// token is sent as the key of the message.
+type QueryPageExtra struct {
+	Limit uint64
+}
+
+// LocalQueryPageExtra defines extra values for this operation.
+type LocalQueryPageExtra struct {
+	PartID uint64
+	From   uint64
+	Limit  uint64
+}
+
+// ExpireByQueryExtra defines extra values for this operation.
+type ExpireByQueryExtra struct {
+	TTL int64
+}
+
+// LocalExpireByQueryExtra defines extra values for this operation.
+type LocalExpireByQueryExtra struct {
+	PartID uint64
+	TTL    int64
+}
+
+// LocalLenExtra defines extra values for this operation.
+type LocalLenExtra struct {
+	PartID uint64
+}
+
 type StreamCreatedExtra struct {
 	StreamID uint64
 }
@@ -144,6 +195,14 @@ func loadExtras(raw []byte, op OpCode) (interface{}, error) {
 		extra := ExpireExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
 		return extra, err
+	case OpGetEx:
+		extra := GetExExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpDeletePrev, OpDeleteReplica:
+		extra := DeleteExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
 	case OpPutIfEx, OpPutIfExReplica:
 		extra := PutIfExExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
@@ -152,6 +211,14 @@ func loadExtras(raw []byte, op OpCode) (interface{}, error) {
 		extra := PutIfExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
 		return extra, err
+	case OpCompareAndSwap:
+		extra := CompareAndSwapExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpBatch:
+		extra := BatchExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
 	case OpUpdateRouting:
 		extra := UpdateRoutingExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
@@ -160,10 +227,34 @@ func loadExtras(raw []byte, op OpCode) (interface{}, error) {
 		extra := LocalQueryExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
 		return extra, err
-	case OpQuery:
+	case OpQuery, OpQueryEntries:
 		extra := QueryExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
 		return extra, err
+	case OpQueryPage:
+		extra := QueryPageExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpLocalQueryPage:
+		extra := LocalQueryPageExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpLocalDeleteByQuery:
+		extra := LocalQueryExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpExpireByQuery:
+		extra := ExpireByQueryExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpLocalExpireByQuery:
+		extra := LocalExpireByQueryExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
+	case OpLocalLen:
+		extra := LocalLenExtra{}
+		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
+		return extra, err
 	case OpStreamCreated:
 		extra := StreamCreatedExtra{}
 		err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &extra)
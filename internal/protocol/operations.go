@@ -62,26 +62,44 @@ const (
 	OpStreamPing            // 42
 	OpStreamPong            // 43
 	OpLockLease             // 44
+	OpCompareAndSwap        // 45
+	OpBatch                 // 46
+	OpExec                  // 47
+	OpExecuteOnKey          // 48
+	OpQueryPage             // 49
+	OpLocalQueryPage        // 50
+	OpQueryEntries          // 51
+	OpDeleteByQuery         // 52
+	OpLocalDeleteByQuery    // 53
+	OpExpireByQuery         // 54
+	OpLocalExpireByQuery    // 55
+	OpLen                   // 56
+	OpLocalLen              // 57
+	OpTTL                   // 58
+	OpGetEx                 // 59
 )
 
 type StatusCode uint8
 
 // Status Codes
 const (
-	StatusOK                  = StatusCode(iota) + 1
-	StatusErrInternalFailure  // 2
-	StatusErrKeyNotFound      // 3
-	StatusErrNoSuchLock       // 4
-	StatusErrLockNotAcquired  // 5
-	StatusErrWriteQuorum      // 6
-	StatusErrReadQuorum       // 7
-	StatusErrOperationTimeout // 8
-	StatusErrKeyFound         // 9
-	StatusErrClusterQuorum    // 10
-	StatusErrUnknownOperation // 11
-	StatusErrEndOfQuery       // 12
-	StatusErrServerGone       // 13
-	StatusErrInvalidArgument  // 14
-	StatusErrKeyTooLarge      // 15
-	StatusErrNotImplemented   // 16
+	StatusOK                     = StatusCode(iota) + 1
+	StatusErrInternalFailure     // 2
+	StatusErrKeyNotFound         // 3
+	StatusErrNoSuchLock          // 4
+	StatusErrLockNotAcquired     // 5
+	StatusErrWriteQuorum         // 6
+	StatusErrReadQuorum          // 7
+	StatusErrOperationTimeout    // 8
+	StatusErrKeyFound            // 9
+	StatusErrClusterQuorum       // 10
+	StatusErrUnknownOperation    // 11
+	StatusErrEndOfQuery          // 12
+	StatusErrServerGone          // 13
+	StatusErrInvalidArgument     // 14
+	StatusErrKeyTooLarge         // 15
+	StatusErrNotImplemented      // 16
+	StatusErrVersionMismatch     // 17
+	StatusErrTransactionConflict // 18
+	StatusErrOutOfMemory         // 19
 )
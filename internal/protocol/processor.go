@@ -0,0 +1,24 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+// EntryProcessorCall is the msgpack encoded value of an OpExecuteOnKey request.
+// Name is the registered name of the entry processor, Args is the serialized
+// argument. The response value is the serialized new value of the key, it's
+// empty if the entry processor has deleted the key.
+type EntryProcessorCall struct {
+	Name string
+	Args []byte
+}
@@ -0,0 +1,39 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+// TxWatch is a watched key of a transaction. Version is the version of the key
+// when it was watched. Zero version means that the key didn't exist.
+type TxWatch struct {
+	Key     string
+	Version int64
+}
+
+// TxCommand is a queued command of a transaction. Op is one of OpPut, OpDelete
+// and OpIncr. Value is the serialized value for OpPut and the serialized delta
+// for OpIncr.
+type TxCommand struct {
+	Op    OpCode
+	Key   string
+	Value []byte
+}
+
+// Transaction is the msgpack encoded value of an OpExec request. The response
+// is a msgpack encoded list of BatchResult, one item per command. Value is the
+// serialized new value for OpIncr.
+type Transaction struct {
+	Watches  []TxWatch
+	Commands []TxCommand
+}
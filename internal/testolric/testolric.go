@@ -39,7 +39,9 @@ func (o *TestOlric) Olric() *olric.Olric {
 	return o.db
 }
 
-func New(t *testing.T) (*TestOlric, error) {
+// New starts a new Olric node for tests. options modify the configuration
+// before the node is started.
+func New(t *testing.T, options ...func(*config.Config)) (*TestOlric, error) {
 	port, err := testutil.GetFreePort()
 	if err != nil {
 		return nil, err
@@ -62,6 +64,9 @@ func New(t *testing.T) (*TestOlric, error) {
 	cfg.StorageEngines = sc
 	cfg.MemberlistConfig = mc
 	cfg.PartitionCount = 7
+	for _, option := range options {
+		option(cfg)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cfg.Started = func() {
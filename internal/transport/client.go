@@ -20,6 +20,7 @@ import (
 	"net"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/buraksezer/connpool"
 	"github.com/buraksezer/olric/config"
@@ -110,21 +111,16 @@ func (c *Client) pool(addr string) (connpool.Pool, error) {
 	return p, nil
 }
 
-func (c *Client) conn(addr string) (net.Conn, error) {
+func (c *Client) conn(ctx context.Context, addr string) (net.Conn, error) {
 	p, err := c.pool(addr)
 	if err != nil {
 		return nil, err
 	}
 
-	var (
-		ctx    context.Context
-		cancel context.CancelFunc
-	)
 	if c.config.PoolTimeout > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), c.config.PoolTimeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.PoolTimeout)
 		defer cancel()
-	} else {
-		ctx = context.Background()
 	}
 
 	conn, err := p.Get(ctx)
@@ -170,10 +166,52 @@ func (c *Client) teardownConn(rawConn net.Conn, dead bool) {
 	}
 }
 
+// watchContext interrupts the blocking I/O on the connection when the context
+// is done. The returned function stops watching and reports whether the I/O has
+// been interrupted. It has to be called before putting the connection back.
+func watchContext(ctx context.Context, conn net.Conn) func() bool {
+	if ctx.Done() == nil {
+		// The context is never canceled.
+		return func() bool { return false }
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	var interrupted bool
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			// Make the pending Read and Write calls return immediately.
+			interrupted = true
+			_ = conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	return func() bool {
+		close(stop)
+		<-done
+		return interrupted
+	}
+}
+
 // RequestTo initiates a request-response cycle to given host.
 func (c *Client) RequestTo(addr string, req protocol.EncodeDecoder) (protocol.EncodeDecoder, error) {
-	conn, err := c.conn(addr)
+	return c.RequestToContext(context.Background(), addr, req)
+}
+
+// RequestToContext initiates a request-response cycle to given host. It returns
+// the context's error, if the context is done before the response is received.
+func (c *Client) RequestToContext(ctx context.Context, addr string, req protocol.EncodeDecoder) (resp protocol.EncodeDecoder, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn, err := c.conn(ctx, addr)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, err
 	}
 	var dead bool
@@ -182,6 +220,15 @@ func (c *Client) RequestTo(addr string, req protocol.EncodeDecoder) (protocol.En
 		c.teardownConn(conn, dead)
 	}()
 
+	stopWatching := watchContext(ctx, conn)
+	defer func() {
+		if stopWatching() {
+			// The deadline of the connection has been changed. Don't reuse it.
+			dead = true
+			resp, err = nil, ctx.Err()
+		}
+	}()
+
 	buf := bufferPool.Get()
 	defer bufferPool.Put(buf)
 
@@ -210,7 +257,7 @@ func (c *Client) RequestTo(addr string, req protocol.EncodeDecoder) (protocol.En
 	ReadBytesTotal.Increase(protocol.HeaderLength + int64(h.MessageLength))
 
 	// Response is a shortcut to create a response message for the request.
-	resp := req.Response(buf)
+	resp = req.Response(buf)
 	err = resp.Decode()
 	if err != nil {
 		return nil, err
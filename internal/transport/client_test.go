@@ -16,7 +16,9 @@ package transport
 
 import (
 	"bytes"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/protocol"
@@ -77,3 +79,61 @@ func TestClient_Request(t *testing.T) {
 		}
 	})
 }
+
+func TestClient_RequestToContext(t *testing.T) {
+	s := newServer(t, func(w, r protocol.EncodeDecoder) {
+		if r.(*protocol.DMapMessage).Key() == "slow" {
+			<-time.After(time.Second)
+		}
+		w.SetStatus(protocol.StatusOK)
+	})
+
+	<-s.StartedCtx.Done()
+
+	cc := &config.Client{
+		MaxConn: 10,
+	}
+	err := cc.Sanitize()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	c := NewClient(cc)
+	addr := s.listener.Addr().String()
+
+	t.Run("Canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req := protocol.NewDMapMessage(protocol.OpPut)
+		_, err := c.RequestToContext(ctx, addr, req)
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled. Got: %v", err)
+		}
+	})
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		req := protocol.NewDMapMessage(protocol.OpPut)
+		req.SetKey("slow")
+		start := time.Now()
+		_, err := c.RequestToContext(ctx, addr, req)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("Expected context.DeadlineExceeded. Got: %v", err)
+		}
+		if time.Since(start) >= time.Second {
+			t.Fatalf("RequestToContext has not been interrupted")
+		}
+
+		// The interrupted connection is not reused.
+		req = protocol.NewDMapMessage(protocol.OpPut)
+		resp, err := c.RequestTo(addr, req)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if resp.Status() != protocol.StatusOK {
+			t.Fatalf("Expected status: %d. Got: %d", protocol.StatusOK, resp.Status())
+		}
+	})
+}
@@ -111,7 +111,7 @@ func TestConnWithTimeout_Disabled(t *testing.T) {
 	}
 
 	c := NewClient(cc)
-	conn, err := c.conn(s.listener.Addr().String())
+	conn, err := c.conn(context.Background(), s.listener.Addr().String())
 	if err != nil {
 		t.Fatalf("Expected nil. Got: %v", err)
 	}
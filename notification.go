@@ -0,0 +1,33 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import "github.com/buraksezer/olric/internal/dmap"
+
+// Operations of the keyspace notifications. A notification is a map with key,
+// op, timestamp and value fields. value is only set for KeyspacePut.
+const (
+	KeyspacePut    = dmap.KeyspacePut
+	KeyspaceDelete = dmap.KeyspaceDelete
+	KeyspaceExpire = dmap.KeyspaceExpire
+	KeyspaceEvict  = dmap.KeyspaceEvict
+)
+
+// KeyspaceTopic returns the name of the DTopic that receives the keyspace
+// notifications of the given DMap. KeyspaceNotifications has to be enabled
+// in the DMap configuration.
+func KeyspaceTopic(dmapName string) string {
+	return dmap.KeyspaceTopic(dmapName)
+}
@@ -169,6 +169,8 @@ func initializeServices(db *Olric) error {
 		return err
 	}
 	db.dtopic = dt.(*dtopic.Service)
+	// DMap service publishes the keyspace notifications via DTopic service.
+	db.env.Set("dtopic", db.dtopic)
 
 	dm, err := dmap.NewService(db.env)
 	if err != nil {
@@ -358,6 +360,11 @@ func (db *Olric) Start() error {
 		return errGr.Wait()
 	}
 
+	// Open the persistent DMap fragments and load the latest snapshot before bootstrapping the node.
+	if err := db.dmap.Restore(); err != nil {
+		return err
+	}
+
 	// Balancer works periodically to balance partition data across the cluster.
 	if err := db.balancer.Start(); err != nil {
 		return err
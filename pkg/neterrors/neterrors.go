@@ -85,6 +85,16 @@ func GetByCode(code protocol.StatusCode) error {
 	return err
 }
 
+// StatusCodeOf returns the status code of the NetError in err's chain. It
+// returns StatusErrInternalFailure if there is no NetError in the chain.
+func StatusCodeOf(err error) protocol.StatusCode {
+	var netErr *NetError
+	if errors.As(err, &netErr) {
+		return netErr.StatusCode()
+	}
+	return protocol.StatusErrInternalFailure
+}
+
 func toByte(err interface{}) []byte {
 	switch val := err.(type) {
 	case string:
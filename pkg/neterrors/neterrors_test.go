@@ -122,3 +122,15 @@ func TestNetErrors_GetByCode(t *testing.T) {
 		t.Fatalf("Expected: %s. Got: %v", message, err)
 	}
 }
+
+func TestNetError_StatusCodeOf(t *testing.T) {
+	err := Wrap(ErrUnknownOperation, "buggy client")
+	if StatusCodeOf(err) != protocol.StatusErrUnknownOperation {
+		t.Fatalf("Expected %v. Got: %v", protocol.StatusErrUnknownOperation, StatusCodeOf(err))
+	}
+
+	err = errors.New("an ordinary error")
+	if StatusCodeOf(err) != protocol.StatusErrInternalFailure {
+		t.Fatalf("Expected %v. Got: %v", protocol.StatusErrInternalFailure, StatusCodeOf(err))
+	}
+}
@@ -105,3 +105,27 @@ type Engine interface {
 	// It should not be possible to reuse a destroyed storage engine.
 	Destroy() error
 }
+
+// LastAccessUpdater is an optional interface that can be implemented by a storage
+// engine to support the LFU eviction policy.
+//
+// LFU keeps a decaying access counter in the LastAccess field of the entries
+// instead of the last access time, so the entry layout doesn't change. It sets
+// the "accessCounter" option of the engine configuration to true. While the
+// option is set, the engine has to store LastAccess of the given entry on Put
+// and must not update it on reads.
+type LastAccessUpdater interface {
+	// UpdateLastAccess sets LastAccess of an entry. It returns ErrKeyNotFound,
+	// if the key doesn't exist.
+	UpdateLastAccess(uint64, int64) error
+}
+
+// OrderedRanger is an optional interface that can be implemented by a storage
+// engine to iterate over the entries in HKey order. The paged queries use it to
+// seek to the beginning of a page instead of scanning the whole engine.
+type OrderedRanger interface {
+	// RangeFrom calls the given function on the entries in ascending HKey
+	// order, starting from the given HKey. The iteration stops if the
+	// function returns false.
+	RangeFrom(uint64, func(uint64, Entry) bool)
+}
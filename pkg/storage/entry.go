@@ -39,7 +39,8 @@ type Entry interface {
 	// SetTimestamp sets the current timestamp to an entry.
 	SetTimestamp(int64)
 
-	// Timestamp returns the current timestamp for an entry.
+	// Timestamp returns the current timestamp for an entry. It's generated by a
+	// hybrid logical clock and used to find the latest version of an entry.
 	Timestamp() int64
 
 	SetLastAccess(int64)
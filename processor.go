@@ -0,0 +1,67 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/dmap"
+)
+
+// EntryProcessor modifies an entry atomically on the partition owner. entry is
+// nil if the key doesn't exist. The returned value is stored as the new value
+// of the key, returning nil deletes the key. An error aborts the operation
+// without modifying the key. A panic is recovered on the partition owner and
+// returned as an error.
+type EntryProcessor func(entry *Entry, args interface{}) (interface{}, error)
+
+// RegisterEntryProcessor registers an entry processor with the given name. The
+// entry processors run on the partition owners, so the same entry processors
+// have to be registered on every member of the cluster.
+func (db *Olric) RegisterEntryProcessor(name string, fn EntryProcessor) error {
+	var wrapper dmap.EntryProcessor
+	if fn != nil {
+		wrapper = func(entry *dmap.Entry, args interface{}) (interface{}, error) {
+			if entry == nil {
+				return fn(nil, args)
+			}
+			return fn(&Entry{
+				Key:       entry.Key,
+				Value:     entry.Value,
+				TTL:       entry.TTL,
+				Timestamp: entry.Timestamp,
+				Version:   entry.Version,
+			}, args)
+		}
+	}
+	return convertDMapError(db.dmap.RegisterEntryProcessor(name, wrapper))
+}
+
+// Execute runs the entry processor that is registered with the given name on
+// the key, under the lock of the key on the partition owner. The new value is
+// replicated like Put. It returns the new value of the key, nil if the entry
+// processor has deleted the key. It's thread-safe.
+func (dm *DMap) Execute(key, name string, args interface{}) (interface{}, error) {
+	return dm.ExecuteContext(context.Background(), key, name, args)
+}
+
+// ExecuteContext is like Execute but the given context cancels the network operations.
+func (dm *DMap) ExecuteContext(ctx context.Context, key, name string, args interface{}) (interface{}, error) {
+	value, err := dm.dm.ExecuteContext(ctx, key, name, args)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return value, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+// Aggregation is the result of the aggregation operators of a query:
+//
+//	  query.M{
+//		  "$onKey": query.M{
+//			  "$regexMatch": "^even:",
+//		  },
+//		  "$aggregate": query.M{
+//			  "$count": true,
+//			  "$sum":   true,
+//		  },
+//	  }
+//
+// Count is the number of the matching entries. $sum, $min, $max and $avg are
+// computed over the numeric values and the other values are ignored. The values
+// are not decoded if none of them is given.
+type Aggregation struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+	Avg   float64
+
+	// Numeric is the number of the numeric values.
+	Numeric int64
+}
+
+// Aggregates returns the aggregation operators of the query. It returns nil, if
+// there is no aggregation operator.
+func Aggregates(q M) M {
+	ops, ok := q["$aggregate"].(M)
+	if !ok || len(ops) == 0 {
+		return nil
+	}
+	return ops
+}
+
+// NeedsValues returns true, if any of the given operators is computed over the values.
+func NeedsValues(ops M) bool {
+	for _, op := range []string{"$sum", "$min", "$max", "$avg"} {
+		if enabled, _ := ops[op].(bool); enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds a matching entry to the aggregation. value is ignored, if it's not numeric.
+func (a *Aggregation) Add(value interface{}) {
+	a.Count++
+	v, ok := toFloat64(value)
+	if !ok {
+		return
+	}
+	if a.Numeric == 0 || v < a.Min {
+		a.Min = v
+	}
+	if a.Numeric == 0 || v > a.Max {
+		a.Max = v
+	}
+	a.Numeric++
+	a.Sum += v
+	a.Avg = a.Sum / float64(a.Numeric)
+}
+
+// Merge merges a partial aggregation into a.
+func (a *Aggregation) Merge(b *Aggregation) {
+	a.Count += b.Count
+	if b.Numeric == 0 {
+		return
+	}
+	if a.Numeric == 0 || b.Min < a.Min {
+		a.Min = b.Min
+	}
+	if a.Numeric == 0 || b.Max > a.Max {
+		a.Max = b.Max
+	}
+	a.Numeric += b.Numeric
+	a.Sum += b.Sum
+	a.Avg = a.Sum / float64(a.Numeric)
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2018-2021 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"reflect"
+	"strings"
+)
+
+func isPredicate(keyword string) bool {
+	switch keyword {
+	case "$eq", "$gt", "$lt", "$in", "$exists":
+		return true
+	}
+	return false
+}
+
+// Predicates returns the value predicates of the query. It returns nil, if
+// $onValue has no predicate.
+//
+// The predicates are evaluated on the decoded values:
+//
+//	query.M{
+//		"$onValue": query.M{
+//			"$gt": 10,
+//		},
+//	}
+//
+// The keys without $ prefix are the field paths into the decoded maps. The
+// path elements are separated by dots:
+//
+//	query.M{
+//		"$onValue": query.M{
+//			"address.city": query.M{"$in": []string{"Istanbul", "Ankara"}},
+//			"age":          query.M{"$gt": 30},
+//		},
+//	}
+func Predicates(q M) M {
+	onValue, ok := q["$onValue"].(M)
+	if !ok {
+		return nil
+	}
+	for keyword := range onValue {
+		if isPredicate(keyword) || !strings.HasPrefix(keyword, "$") {
+			return onValue
+		}
+	}
+	return nil
+}
+
+// Match reports whether the value satisfies all the given predicates.
+func Match(predicates M, value interface{}) bool {
+	for keyword, arg := range predicates {
+		if isPredicate(keyword) {
+			if !evaluate(keyword, arg, value, value != nil) {
+				return false
+			}
+			continue
+		}
+		if strings.HasPrefix(keyword, "$") {
+			// $ignore
+			continue
+		}
+
+		field, exists := lookup(value, keyword)
+		for predicate, arg := range arg.(M) {
+			if !evaluate(predicate, arg, field, exists) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func evaluate(predicate string, arg, value interface{}, exists bool) bool {
+	if predicate == "$exists" {
+		return exists == arg.(bool)
+	}
+	if !exists {
+		return false
+	}
+
+	switch predicate {
+	case "$eq":
+		return equal(value, arg)
+	case "$gt":
+		res, ok := compare(value, arg)
+		return ok && res > 0
+	case "$lt":
+		res, ok := compare(value, arg)
+		return ok && res < 0
+	case "$in":
+		items := reflect.ValueOf(arg)
+		for i := 0; i < items.Len(); i++ {
+			if equal(value, items.Index(i).Interface()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookup finds the field at the given path in the decoded maps.
+func lookup(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		var ok bool
+		switch m := value.(type) {
+		case map[string]interface{}:
+			value, ok = m[key]
+		case M:
+			value, ok = m[key]
+		case map[interface{}]interface{}:
+			value, ok = m[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func equal(a, b interface{}) bool {
+	x, aIsNumber := toFloat64(a)
+	y, bIsNumber := toFloat64(b)
+	if aIsNumber || bIsNumber {
+		// Numbers are compared by value, the serializers may decode them
+		// with different types.
+		return aIsNumber && bIsNumber && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compare(a, b interface{}) (int, bool) {
+	x, aIsNumber := toFloat64(a)
+	y, bIsNumber := toFloat64(b)
+	if aIsNumber && bIsNumber {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	s1, aIsString := a.(string)
+	s2, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(s1, s2), true
+	}
+	return 0, false
+}
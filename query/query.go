@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/vmihailenco/msgpack"
 )
@@ -38,7 +39,7 @@ func Validate(q M) error {
 	}
 	for keyword, value := range q {
 		switch keyword {
-		case "$onKey", "$onValue", "$options":
+		case "$onKey", "$onValue", "$options", "$aggregate":
 			val, ok := value.(M)
 			if !ok {
 				return fmt.Errorf("wrong type for %s: %s, needs query.M",
@@ -54,14 +55,50 @@ func Validate(q M) error {
 				return fmt.Errorf("wrong type for %s: %s, needs string",
 					keyword, reflect.TypeOf(value))
 			}
-		case "$ignore":
+		case "$ignore", "$count", "$sum", "$min", "$max", "$avg", "$exists":
 			_, ok := value.(bool)
 			if !ok {
 				return fmt.Errorf("wrong type for %s: %s, needs bool",
 					keyword, reflect.TypeOf(value))
 			}
+		case "$eq":
+		case "$gt", "$lt":
+			_, isNumber := toFloat64(value)
+			_, isString := value.(string)
+			if !isNumber && !isString {
+				return fmt.Errorf("wrong type for %s: %s, needs a number or string",
+					keyword, reflect.TypeOf(value))
+			}
+		case "$limit":
+			limit, ok := toFloat64(value)
+			if !ok || limit < 1 || limit != float64(int64(limit)) {
+				return fmt.Errorf("wrong value for %s: %v, needs a positive integer",
+					keyword, value)
+			}
+		case "$in":
+			if value == nil || reflect.TypeOf(value).Kind() != reflect.Slice {
+				return fmt.Errorf("wrong type for %s: %s, needs a slice",
+					keyword, reflect.TypeOf(value))
+			}
 		default:
-			return fmt.Errorf("invalid keyword: %s", keyword)
+			if strings.HasPrefix(keyword, "$") {
+				return fmt.Errorf("invalid keyword: %s", keyword)
+			}
+			// A field path in $onValue
+			val, ok := value.(M)
+			if !ok {
+				return fmt.Errorf("wrong type for field %s: %s, needs query.M",
+					keyword, reflect.TypeOf(value))
+			}
+			for predicate := range val {
+				if !isPredicate(predicate) {
+					return fmt.Errorf("invalid keyword for field %s: %s", keyword, predicate)
+				}
+			}
+			err := Validate(val)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -70,17 +107,29 @@ func Validate(q M) error {
 func buildQuery(q M) M {
 	for keyword, value := range q {
 		switch keyword {
-		case "$onKey", "$onValue", "$options":
+		case "$onKey", "$onValue", "$options", "$aggregate":
 			q[keyword] = buildQuery(value.(map[string]interface{}))
 		case "$regexMatch":
 			q[keyword] = value.(string)
-		case "$ignore":
+		case "$ignore", "$count", "$sum", "$min", "$max", "$avg", "$exists":
 			q[keyword] = value.(bool)
+		default:
+			if val, ok := value.(map[string]interface{}); ok && !strings.HasPrefix(keyword, "$") {
+				// A field path in $onValue
+				q[keyword] = buildQuery(val)
+			}
 		}
 	}
 	return q
 }
 
+// Limit returns the maximum number of the entries to return. It returns zero, if
+// the query has no $limit.
+func Limit(q M) int {
+	limit, _ := toFloat64(q["$limit"])
+	return int(limit)
+}
+
 // FromByte generates a query from a byte slice.
 func FromByte(data []byte) (M, error) {
 	var q M
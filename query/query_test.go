@@ -88,3 +88,152 @@ func TestQuery_Validate(t *testing.T) {
 		t.Fatalf("Expected nil. Got: %v", err)
 	}
 }
+
+func TestQuery_Validate_Aggregate(t *testing.T) {
+	q1 := M{
+		"$onKey": M{
+			"$regexMatch": "even:",
+		},
+		"$aggregate": M{
+			"$count": true,
+			"$avg":   true,
+		},
+	}
+	if err := Validate(q1); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	q2 := M{
+		"$aggregate": M{
+			"$count": "yes",
+		},
+	}
+	if err := Validate(q2); err == nil {
+		t.Fatalf("Expected an error about data type. Got nil")
+	}
+}
+
+func TestQuery_Aggregation(t *testing.T) {
+	a := &Aggregation{}
+	a.Add(10)
+	a.Add(int64(-2))
+	a.Add("foobar")
+
+	b := &Aggregation{}
+	b.Add(4.5)
+	b.Add(nil)
+
+	a.Merge(b)
+	a.Merge(&Aggregation{})
+
+	expected := Aggregation{Count: 5, Sum: 12.5, Min: -2, Max: 10, Avg: 12.5 / 3, Numeric: 3}
+	if *a != expected {
+		t.Fatalf("Expected %+v. Got: %+v", expected, *a)
+	}
+}
+
+func TestQuery_Validate_Predicates(t *testing.T) {
+	q1 := M{
+		"$onValue": M{
+			"$in":          []int{1, 2, 3},
+			"address.city": M{"$eq": "Istanbul", "$exists": true},
+			"age":          M{"$gt": 30, "$lt": 40.5},
+		},
+	}
+	if err := Validate(q1); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	invalid := []M{
+		{"$onValue": M{"$gt": true}},
+		{"$onValue": M{"$in": 10}},
+		{"$onValue": M{"age": 10}},
+		{"$onValue": M{"age": M{"$regexMatch": ""}}},
+		{"$onValue": M{"$foobar": 10}},
+	}
+	for _, q := range invalid {
+		if err := Validate(q); err == nil {
+			t.Fatalf("Expected an error for %v. Got nil", q)
+		}
+	}
+}
+
+func TestQuery_FromByte_Predicates(t *testing.T) {
+	q := M{
+		"$onValue": M{
+			"age": M{"$gt": 30},
+		},
+	}
+	data, err := msgpack.Marshal(q)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %s", err)
+	}
+	qb, err := FromByte(data)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %s", err)
+	}
+	if err = Validate(qb); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if !Match(Predicates(qb), map[string]interface{}{"age": int8(31)}) {
+		t.Fatalf("Expected the value matches")
+	}
+}
+
+func TestQuery_Match(t *testing.T) {
+	value := map[string]interface{}{
+		"name": "foobar",
+		"age":  uint8(35),
+		"address": map[interface{}]interface{}{
+			"city": "Istanbul",
+		},
+	}
+	tests := []struct {
+		predicates M
+		expected   bool
+	}{
+		{M{"age": M{"$eq": 35}}, true},
+		{M{"age": M{"$gt": 35.5}}, false},
+		{M{"age": M{"$gt": 30, "$lt": 40}}, true},
+		{M{"name": M{"$lt": "zoo"}}, true},
+		{M{"name": M{"$gt": 10}}, false},
+		{M{"address.city": M{"$in": []string{"Ankara", "Istanbul"}}}, true},
+		{M{"address.city": M{"$in": []interface{}{"Ankara"}}}, false},
+		{M{"address.zip": M{"$exists": false}}, true},
+		{M{"address.zip": M{"$eq": nil}}, false},
+		{M{"name.first": M{"$exists": true}}, false},
+		{M{"$exists": true}, true},
+		{M{"$eq": 35}, false},
+	}
+	for _, test := range tests {
+		if res := Match(test.predicates, value); res != test.expected {
+			t.Fatalf("Expected %v for %v. Got: %v", test.expected, test.predicates, res)
+		}
+	}
+
+	if !Match(M{"$in": []int{1, 2, 3}}, int64(2)) {
+		t.Fatalf("Expected the value matches")
+	}
+	if Match(M{"$exists": true}, nil) {
+		t.Fatalf("Expected the value doesn't match")
+	}
+}
+
+func TestQuery_Limit(t *testing.T) {
+	q := M{"$onKey": M{"$regexMatch": ""}, "$limit": uint8(10)}
+	if err := Validate(q); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if limit := Limit(q); limit != 10 {
+		t.Fatalf("Expected limit is 10. Got: %d", limit)
+	}
+	if limit := Limit(M{}); limit != 0 {
+		t.Fatalf("Expected limit is 0. Got: %d", limit)
+	}
+
+	for _, limit := range []interface{}{0, -1, 1.5, "10"} {
+		if err := Validate(M{"$limit": limit}); err == nil {
+			t.Fatalf("Expected an error for $limit: %v. Got nil", limit)
+		}
+	}
+}